@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// AccountIDMetadataKey is the metadata key the account id is set under by
+// AccountIDAnnotator
+const AccountIDMetadataKey = "x-account-id"
+
+// AccountIDAnnotator returns a gateway annotator, passed to runtime.WithMetadata,
+// setting the account id of the token of the Authorization header under
+// AccountIDMetadataKey. The metadata is set empty when the request has no
+// token or an invalid one, so that the value of an X-Account-ID header copied
+// by the gateway is never taken for the account id, and the gRPC service then
+// rejects or allows the request as it would without the annotator.
+func AccountIDAnnotator(keyfunc jwt.Keyfunc, opts ...Option) func(context.Context, *http.Request) metadata.MD {
+	return func(ctx context.Context, req *http.Request) metadata.MD {
+		if req == nil {
+			return metadata.Pairs(AccountIDMetadataKey, "")
+		}
+		auth := req.Header.Get(AuthorizationHeader)
+		if auth == "" {
+			return metadata.Pairs(AccountIDMetadataKey, "")
+		}
+		accountID, err := GetAccountID(metadata.NewIncomingContext(ctx, metadata.Pairs(AuthorizationHeader, auth)), keyfunc, opts...)
+		if err != nil {
+			return metadata.Pairs(AccountIDMetadataKey, "")
+		}
+		return metadata.Pairs(AccountIDMetadataKey, accountID)
+	}
+}
+
+// AccountIDMetadataInterceptor returns grpc.UnaryServerInterceptor which stores
+// the account id set by AccountIDAnnotator in the context for the handlers, see
+// AccountIDFromContext, without parsing the token again. As any client can set
+// the metadata, it must only be used when the network guarantees that the
+// requests come from the gateway.
+func AccountIDMetadataInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(accountIDMetadataContext(ctx), req)
+	}
+}
+
+// AccountIDMetadataStreamInterceptor is the streaming counterpart of
+// AccountIDMetadataInterceptor
+func AccountIDMetadataStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := grpc_middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = accountIDMetadataContext(stream.Context())
+		return handler(srv, wrapped)
+	}
+}
+
+// accountIDMetadataContext stores the last value of the metadata key, the one
+// of the annotator follows the headers copied by the gateway. An empty last
+// value, set by the annotator for the requests without valid token, stores
+// none.
+func accountIDMetadataContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get(AccountIDMetadataKey); len(vals) > 0 && vals[len(vals)-1] != "" {
+		return NewContextWithAccountID(ctx, vals[len(vals)-1])
+	}
+	return ctx
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/armezit/atlas-app-toolkit/gateway"
+	mock_transport "github.com/armezit/atlas-app-toolkit/mocks/transport"
+)
+
+func TestAccountIDAnnotator(t *testing.T) {
+	annotator := AccountIDAnnotator(HMACKeyfunc([]byte(TestSecret)))
+	token := makeToken(jwt.MapClaims{MultiTenancyField: testAccountID}, t)
+	otherToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{MultiTenancyField: testAccountID}).SignedString([]byte("other-secret"))
+
+	for name, tc := range map[string]struct {
+		header   string
+		expected metadata.MD
+	}{
+		"account id":    {header: DefaultTokenType + " " + token, expected: metadata.Pairs(AccountIDMetadataKey, testAccountID)},
+		"missing token": {expected: metadata.Pairs(AccountIDMetadataKey, "")},
+		"invalid token": {header: DefaultTokenType + " invalid", expected: metadata.Pairs(AccountIDMetadataKey, "")},
+		"wrong secret":  {header: DefaultTokenType + " " + otherToken, expected: metadata.Pairs(AccountIDMetadataKey, "")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/objects", nil)
+			if tc.header != "" {
+				req.Header.Set(AuthorizationHeader, tc.header)
+			}
+			assert.Equal(t, tc.expected, annotator(context.Background(), req))
+		})
+	}
+}
+
+func TestAccountIDMetadataInterceptor(t *testing.T) {
+	interceptor := AccountIDMetadataInterceptor()
+	for name, tc := range map[string]struct {
+		md        metadata.MD
+		accountID string
+	}{
+		// the value of the annotator follows the one copied from the headers
+		"annotated": {md: metadata.Pairs(AccountIDMetadataKey, "from-header", AccountIDMetadataKey, testAccountID), accountID: testAccountID},
+		"missing":   {md: metadata.Pairs()},
+		// the annotator found no valid token
+		"not annotated": {md: metadata.Pairs(AccountIDMetadataKey, "from-header", AccountIDMetadataKey, "")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				accountID, ok := AccountIDFromContext(ctx)
+				assert.Equal(t, tc.accountID != "", ok)
+				assert.Equal(t, tc.accountID, accountID)
+				return nil, nil
+			}
+			_, err := interceptor(metadata.NewIncomingContext(context.Background(), tc.md), nil, &grpc.UnaryServerInfo{FullMethod: testFullMethod}, handler)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAccountIDAnnotator_ForgedHeader(t *testing.T) {
+	mux := gateway.NewServeMux(gateway.WithMuxAnnotators(AccountIDAnnotator(HMACKeyfunc([]byte(TestSecret)))))
+	interceptor := AccountIDMetadataInterceptor()
+	token := makeToken(jwt.MapClaims{MultiTenancyField: testAccountID}, t)
+
+	for name, tc := range map[string]struct {
+		header    string
+		accountID string
+	}{
+		"no token":      {},
+		"invalid token": {header: DefaultTokenType + " invalid"},
+		"valid token":   {header: DefaultTokenType + " " + token, accountID: testAccountID},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/objects", nil)
+			req.Header.Set("X-Account-ID", "forged")
+			if tc.header != "" {
+				req.Header.Set(AuthorizationHeader, tc.header)
+			}
+			ctx, err := runtime.AnnotateContext(context.Background(), mux, req, testFullMethod)
+			if !assert.NoError(t, err) {
+				return
+			}
+			md, _ := metadata.FromOutgoingContext(ctx)
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				accountID, ok := AccountIDFromContext(ctx)
+				assert.Equal(t, tc.accountID != "", ok)
+				assert.Equal(t, tc.accountID, accountID)
+				return nil, nil
+			}
+			_, err = interceptor(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: testFullMethod}, handler)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAccountIDMetadataStreamInterceptor(t *testing.T) {
+	interceptor := AccountIDMetadataStreamInterceptor()
+	ctx := metadata.NewIncomingContext(mock_transport.DummyContextWithServerTransportStream(), metadata.Pairs(AccountIDMetadataKey, testAccountID))
+
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		accountID, _ := AccountIDFromContext(stream.Context())
+		assert.Equal(t, testAccountID, accountID)
+		return nil
+	}
+	assert.NoError(t, interceptor(testRequest{}, mock_transport.NewMockServerStream(ctx), &grpc.StreamServerInfo{FullMethod: testFullMethod}, handler))
+}
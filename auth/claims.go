@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// RolesClaim is the claim of the token holding the roles of the subject
+const RolesClaim = "roles"
+
+var (
+	// ErrInvalidToken is returned by the claim getters when the token is
+	// missing from the context or cannot be parsed or verified
+	ErrInvalidToken = errors.New("unable to get a valid token from context")
+	// ErrMissingClaim is returned by the claim getters when the token does
+	// not have the claim
+	ErrMissingClaim = errors.New("unable to find claim in token")
+	// ErrInvalidClaimType is returned by the typed claim getters when the
+	// claim does not have the expected type
+	ErrInvalidClaimType = errors.New("unexpected claim type")
+)
+
+// GetClaim gets the bearer token from a context, like GetAccountID, and
+// returns the given claim as decoded from the JSON payload
+func GetClaim(ctx context.Context, keyfunc jwt.Keyfunc, claim string, opts ...Option) (interface{}, error) {
+	claims, err := getClaims(ctx, DefaultTokenType, keyfunc, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	value, ok := claims[claim]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrMissingClaim, claim)
+	}
+	return value, nil
+}
+
+// GetStringClaim returns the given string claim of the bearer token
+func GetStringClaim(ctx context.Context, keyfunc jwt.Keyfunc, claim string, opts ...Option) (string, error) {
+	value, err := GetClaim(ctx, keyfunc, claim, opts...)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %q is not a string", ErrInvalidClaimType, claim)
+	}
+	return s, nil
+}
+
+// GetStringSliceClaim returns the given claim of the bearer token as a slice
+// of strings, the claim must be an array of strings or a single string
+func GetStringSliceClaim(ctx context.Context, keyfunc jwt.Keyfunc, claim string, opts ...Option) ([]string, error) {
+	value, err := GetClaim(ctx, keyfunc, claim, opts...)
+	if err != nil {
+		return nil, err
+	}
+	res, ok := stringSlice(value)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not an array of strings", ErrInvalidClaimType, claim)
+	}
+	return res, nil
+}
+
+// stringSlice converts an array of strings or a single string claim
+func stringSlice(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			res = append(res, s)
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+// ParseClaims gets the bearer token from a context, like GetAccountID, and
+// returns its claims, without the checks of a Validator. The error matches
+// ErrInvalidClaimType with errors.Is when one of the common claims does not
+// have the expected type
+func ParseClaims(ctx context.Context, keyfunc jwt.Keyfunc, opts ...Option) (*Claims, error) {
+	o := newOptions(opts)
+	raw, err := getClaims(ctx, DefaultTokenType, keyfunc, o)
+	if err != nil {
+		return nil, err
+	}
+	return newClaims(raw, o)
+}
+
+// newClaims returns the typed view of the claims
+func newClaims(raw jwt.MapClaims, o *options) (*Claims, error) {
+	var err error
+	claims := &Claims{Raw: raw}
+
+	paths := o.accountIDPaths
+	if len(paths) == 0 {
+		paths = multiTenancyVariants
+	}
+	for _, path := range paths {
+		if val, ok := lookupClaimPath(raw, path); ok {
+			claims.AccountID = fmt.Sprint(val)
+			break
+		}
+	}
+	for claim, dst := range map[string]*string{"sub": &claims.Subject, "iss": &claims.Issuer} {
+		if val, ok := raw[claim]; ok {
+			if *dst, ok = val.(string); !ok {
+				return nil, fmt.Errorf("%w: %q is not a string", ErrInvalidClaimType, claim)
+			}
+		}
+	}
+	for claim, dst := range map[string]*[]string{"aud": &claims.Audience, RolesClaim: &claims.Roles} {
+		if val, ok := raw[claim]; ok {
+			if *dst, ok = stringSlice(val); !ok {
+				return nil, fmt.Errorf("%w: %q is not an array of strings", ErrInvalidClaimType, claim)
+			}
+		}
+	}
+	if val, ok := raw["exp"]; ok {
+		var sec float64
+		switch v := val.(type) {
+		case float64:
+			sec = v
+		case json.Number:
+			if sec, err = v.Float64(); err != nil {
+				return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidClaimType, "exp")
+			}
+		default:
+			return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidClaimType, "exp")
+		}
+		claims.ExpiresAt = time.Unix(int64(sec), 0)
+	}
+	return claims, nil
+}
+
+func getClaims(ctx context.Context, tokenType string, keyfunc jwt.Keyfunc, o *options) (jwt.MapClaims, error) {
+	token, err := getToken(ctx, tokenType, keyfunc, o)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errInvalidAssertion
+	}
+	if err := o.missingClaim(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
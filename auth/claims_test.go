@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+func TestGetClaims(t *testing.T) {
+	token := makeToken(jwt.MapClaims{
+		"sub":   "user-1",
+		"email": "user@example.com",
+		"roles": []string{"admin", "viewer"},
+		"scope": "read",
+		"level": 3,
+	}, t)
+	ctx := contextWithToken(token, DefaultTokenType)
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	if value, err := GetClaim(ctx, keyfunc, "level"); err != nil || value != float64(3) {
+		t.Errorf("Invalid claim: %v, %v - expected %v", value, err, 3)
+	}
+	if value, err := GetStringClaim(ctx, keyfunc, "email"); err != nil || value != "user@example.com" {
+		t.Errorf("Invalid claim: %v, %v - expected %v", value, err, "user@example.com")
+	}
+	for claim, expected := range map[string][]string{"roles": {"admin", "viewer"}, "scope": {"read"}} {
+		if value, err := GetStringSliceClaim(ctx, keyfunc, claim); err != nil || !reflect.DeepEqual(value, expected) {
+			t.Errorf("Invalid claim %q: %v, %v - expected %v", claim, value, err, expected)
+		}
+	}
+}
+
+func TestGetClaims_Errors(t *testing.T) {
+	token := makeToken(jwt.MapClaims{"sub": "user-1", "level": 3}, t)
+	ctx := contextWithToken(token, DefaultTokenType)
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	for name, tc := range map[string]struct {
+		get      func() error
+		expected error
+	}{
+		"missing claim": {
+			get:      func() error { _, err := GetClaim(ctx, keyfunc, "email"); return err },
+			expected: ErrMissingClaim,
+		},
+		"no token": {
+			get:      func() error { _, err := GetClaim(context.Background(), keyfunc, "sub"); return err },
+			expected: ErrInvalidToken,
+		},
+		"invalid signature": {
+			get:      func() error { _, err := GetClaim(ctx, HMACKeyfunc([]byte("other-secret")), "sub"); return err },
+			expected: ErrInvalidToken,
+		},
+		"not a string": {
+			get:      func() error { _, err := GetStringClaim(ctx, keyfunc, "level"); return err },
+			expected: ErrInvalidClaimType,
+		},
+		"not a slice": {
+			get:      func() error { _, err := GetStringSliceClaim(ctx, keyfunc, "level"); return err },
+			expected: ErrInvalidClaimType,
+		},
+	} {
+		if err := tc.get(); !errors.Is(err, tc.expected) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.expected)
+		}
+	}
+}
+
+func TestParseClaims(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	token := makeToken(jwt.MapClaims{
+		"sub":             "user-1",
+		"iss":             "issuer-a",
+		"aud":             "svc-a",
+		"exp":             exp,
+		MultiTenancyField: testAccountID,
+		RolesClaim:        []string{"admin", "viewer"},
+		"email":           "user@example.com",
+	}, t)
+	ctx := contextWithToken(token, DefaultTokenType)
+
+	claims, err := ParseClaims(ctx, HMACKeyfunc([]byte(TestSecret)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claims.AccountID != testAccountID || claims.Subject != "user-1" || claims.Issuer != "issuer-a" {
+		t.Errorf("Invalid claims: %+v", claims)
+	}
+	if !reflect.DeepEqual(claims.Audience, []string{"svc-a"}) || !reflect.DeepEqual(claims.Roles, []string{"admin", "viewer"}) {
+		t.Errorf("Invalid claims: audience %v, roles %v", claims.Audience, claims.Roles)
+	}
+	if claims.ExpiresAt.Unix() != exp {
+		t.Errorf("Invalid expiry: %v - expected %v", claims.ExpiresAt, time.Unix(exp, 0))
+	}
+	if claims.Raw["email"] != "user@example.com" {
+		t.Errorf("Invalid raw claim: %v - expected %v", claims.Raw["email"], "user@example.com")
+	}
+	// the validation is the one of the Validator, not of jwt.MapClaims
+	if _, ok := interface{}(claims).(jwt.Claims); ok {
+		t.Errorf("Unexpected jwt.Claims implementation: %T", claims)
+	}
+}
+
+func TestParseClaims_Errors(t *testing.T) {
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+	for name, tc := range map[string]struct {
+		claims   jwt.MapClaims
+		expected error
+	}{
+		"expired":        {claims: jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(-time.Hour).Unix()}, expected: ErrInvalidToken},
+		"invalid roles":  {claims: jwt.MapClaims{"sub": "user-1", RolesClaim: 3}, expected: ErrInvalidClaimType},
+		"invalid issuer": {claims: jwt.MapClaims{"iss": []string{"issuer-a"}}, expected: ErrInvalidClaimType},
+	} {
+		ctx := contextWithToken(makeToken(tc.claims, t), DefaultTokenType)
+		if _, err := ParseClaims(ctx, keyfunc); !errors.Is(err, tc.expected) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.expected)
+		}
+	}
+	if _, err := ParseClaims(context.Background(), keyfunc); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Invalid error value (no token): %v - expected %v", err, ErrInvalidToken)
+	}
+}
@@ -13,7 +13,7 @@ import (
 func OutgoingContext(ctx context.Context) context.Context {
 	keys := []string{
 		AuthorizationHeader,
-		requestid.DefaultRequestIDKey,
+		requestid.MetadataKey(),
 		gateway.XForwardedFor,
 	}
 
@@ -40,3 +40,13 @@ func OutgoingContextWithCustomParams(ctx context.Context, keys ...string) contex
 
 	return metadata.NewOutgoingContext(ctx, resultMD)
 }
+
+// WithOutgoingAccountID appends the account id to the outgoing metadata under
+// MultiTenancyField, for the calls issued without an inbound request, e.g. by
+// background jobs, that have no token to forward. The server reads it with
+// GetAccountID and WithAccountIDHeader(MultiTenancyField), only when the call
+// has no token. As OutgoingContext replaces the outgoing metadata, it must be
+// called afterwards.
+func WithOutgoingAccountID(ctx context.Context, accountID string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, MultiTenancyField, accountID)
+}
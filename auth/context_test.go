@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithOutgoingAccountID(t *testing.T) {
+	ctx := WithOutgoingAccountID(context.Background(), "id-abc-123")
+	md, _ := metadata.FromOutgoingContext(ctx)
+	// the server receives the outgoing metadata as incoming metadata
+	ctx = metadata.NewIncomingContext(context.Background(), md)
+
+	accountID, err := GetAccountID(ctx, nil, WithAccountIDHeader(MultiTenancyField))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if accountID != "id-abc-123" {
+		t.Errorf("Invalid AccountID: %v - expected %v", accountID, "id-abc-123")
+	}
+
+	// the metadata is only a fallback by default
+	if _, err := GetAccountID(ctx, nil); !errors.Is(err, ErrNoToken) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrNoToken)
+	}
+}
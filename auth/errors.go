@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoToken is returned by GetAccountID when the request has no token
+	ErrNoToken = errMissingToken
+	// ErrMalformedToken is returned by GetAccountID when the token cannot be
+	// parsed or verified, the returned error wraps the cause
+	ErrMalformedToken = errors.New("unable to parse token")
+	// ErrMissingTenant is returned by GetAccountID when the token does not
+	// have the account id claims
+	ErrMissingTenant = errMissingField
+)
+
+// tokenError is returned when the token of a request cannot be used, it
+// matches its kind, ErrNoToken or ErrMalformedToken, as well as
+// ErrInvalidToken with errors.Is
+type tokenError struct {
+	kind  error
+	cause error
+	// message replaces the one of the kind
+	message string
+}
+
+func noTokenError() error {
+	return &tokenError{kind: ErrNoToken}
+}
+
+func malformedTokenError(cause error) error {
+	return &tokenError{kind: ErrMalformedToken, cause: cause}
+}
+
+// keepMessage gives a token error the message the getters returned for every
+// failure before the errors were typed, for the log-based dashboards matching
+// it
+func keepMessage(err, message error) error {
+	var te *tokenError
+	if !errors.As(err, &te) {
+		return err
+	}
+	return &tokenError{kind: te.kind, cause: te.cause, message: message.Error()}
+}
+
+func (e *tokenError) Error() string {
+	msg := e.kind.Error()
+	if e.message != "" {
+		msg = e.message
+	}
+	if e.cause == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s: %v", msg, e.cause)
+}
+
+func (e *tokenError) Is(target error) bool {
+	return target == e.kind || target == ErrInvalidToken
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.cause
+}
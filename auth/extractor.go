@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// AccountIDExtractor extracts the account id of a request from its incoming
+// context, e.g. from a token of a format other than JWT. The errors should
+// wrap ErrNoToken, ErrMalformedToken or ErrMissingTenant, as those of
+// GetAccountID, for the callers to tell them apart.
+type AccountIDExtractor interface {
+	ExtractAccountID(ctx context.Context) (string, error)
+}
+
+// AccountIDExtractorFunc is a function implementing AccountIDExtractor
+type AccountIDExtractorFunc func(ctx context.Context) (string, error)
+
+// ExtractAccountID calls f(ctx)
+func (f AccountIDExtractorFunc) ExtractAccountID(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// JWTAccountIDExtractor returns the default AccountIDExtractor, which reads
+// the account id of the JWT with GetAccountID
+func JWTAccountIDExtractor(keyfunc jwt.Keyfunc, opts ...Option) AccountIDExtractor {
+	return AccountIDExtractorFunc(func(ctx context.Context) (string, error) {
+		return GetAccountID(ctx, keyfunc, opts...)
+	})
+}
@@ -10,17 +10,20 @@ import (
 )
 
 // LogrusUnaryServerInterceptor returns grpc.UnaryServerInterceptor which populates request-scoped logrus logger with account_id field
+// The parsed token is cached in the context for the interceptors down the chain (see WithTokenCache)
 func LogrusUnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = WithTokenCache(ctx)
 		addAccountIDToLogger(ctx)
 		return handler(ctx, req)
 	}
 }
 
 // LogrusStreamServerInterceptor returns grpc.StreamServerInterceptor which populates request-scoped logrus logger with account_id field
+// The parsed token is cached in the context for the interceptors down the chain (see WithTokenCache)
 func LogrusStreamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
-		ctx := stream.Context()
+		ctx := WithTokenCache(stream.Context())
 		addAccountIDToLogger(ctx)
 		wrapped := grpc_middleware.WrapServerStream(stream)
 		wrapped.WrappedContext = ctx
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+)
+
+const (
+	// DefaultIntrospectionAccountIDField is the default field of the
+	// introspection response holding the account id
+	DefaultIntrospectionAccountIDField = MultiTenancyField
+	// DefaultIntrospectionTTL is the default duration the active tokens are
+	// cached for, shortened to their expiry
+	DefaultIntrospectionTTL = time.Minute
+	// DefaultIntrospectionTimeout is the default timeout of a call to the
+	// introspection endpoint
+	DefaultIntrospectionTimeout = 5 * time.Second
+
+	// introspectionCacheLimit bounds the number of cached tokens, the expired
+	// ones are evicted when it is reached
+	introspectionCacheLimit = 10000
+)
+
+// IntrospectionOption is a type of function that alters the configuration of
+// the extractor returned by NewIntrospectionExtractor
+type IntrospectionOption func(*introspector)
+
+// WithIntrospectionHTTPClient sets the client used to call the introspection
+// endpoint
+func WithIntrospectionHTTPClient(client *http.Client) IntrospectionOption {
+	return func(i *introspector) {
+		i.client = client
+	}
+}
+
+// WithIntrospectionTimeout sets the timeout of a call to the introspection
+// endpoint. Defaults to DefaultIntrospectionTimeout
+func WithIntrospectionTimeout(d time.Duration) IntrospectionOption {
+	return func(i *introspector) {
+		i.timeout = d
+	}
+}
+
+// WithIntrospectionTTL sets the duration the active tokens are cached for, the
+// expiry of a token shortens it. A zero TTL disables the cache. Defaults to
+// DefaultIntrospectionTTL
+func WithIntrospectionTTL(ttl time.Duration) IntrospectionOption {
+	return func(i *introspector) {
+		i.ttl = ttl
+	}
+}
+
+// WithIntrospectionAccountIDField sets the field of the introspection response
+// holding the account id. Defaults to DefaultIntrospectionAccountIDField
+func WithIntrospectionAccountIDField(field string) IntrospectionOption {
+	return func(i *introspector) {
+		i.field = field
+	}
+}
+
+type introspection struct {
+	accountID string
+	expires   time.Time
+}
+
+type introspector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	timeout      time.Duration
+	ttl          time.Duration
+	field        string
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]introspection
+}
+
+// NewIntrospectionExtractor returns an AccountIDExtractor for the opaque
+// tokens, which are validated by the OAuth 2.0 token introspection endpoint
+// of RFC 7662. The bearer token of the request is posted to the endpoint with
+// the client credentials, and the account id is read from the response of an
+// active token. An inactive token yields ErrMissingTenant. The active tokens
+// are cached, until their exp or the TTL set by WithIntrospectionTTL, so that
+// the endpoint is not called on every request. It is safe for concurrent use,
+// e.g. with WithAccountIDExtractor.
+func NewIntrospectionExtractor(endpoint, clientID, clientSecret string, opts ...IntrospectionOption) AccountIDExtractor {
+	i := &introspector{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       http.DefaultClient,
+		timeout:      DefaultIntrospectionTimeout,
+		ttl:          DefaultIntrospectionTTL,
+		field:        DefaultIntrospectionAccountIDField,
+		cache:        make(map[[sha256.Size]byte]introspection),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+func (i *introspector) ExtractAccountID(ctx context.Context) (string, error) {
+	if !hasAuthorizationHeader(ctx) {
+		return "", noTokenError()
+	}
+	token, err := grpc_auth.AuthFromMD(ctx, DefaultTokenType)
+	if err != nil {
+		return "", malformedTokenError(err)
+	}
+
+	// the tokens are credentials, only their hash is kept
+	key := sha256.Sum256([]byte(token))
+	if accountID, ok := i.lookup(key); ok {
+		return accountID, nil
+	}
+
+	accountID, exp, err := i.introspect(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	i.store(key, accountID, exp)
+	return accountID, nil
+}
+
+func (i *introspector) lookup(key [sha256.Size]byte) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entry, ok := i.cache[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expires) {
+		delete(i.cache, key)
+		return "", false
+	}
+	return entry.accountID, true
+}
+
+func (i *introspector) store(key [sha256.Size]byte, accountID string, exp time.Time) {
+	if i.ttl <= 0 {
+		return
+	}
+	expires := time.Now().Add(i.ttl)
+	if !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.cache) >= introspectionCacheLimit {
+		now := time.Now()
+		for k, entry := range i.cache {
+			if now.After(entry.expires) {
+				delete(i.cache, k)
+			}
+		}
+		// too many live tokens, start over rather than grow unbounded
+		if len(i.cache) >= introspectionCacheLimit {
+			i.cache = make(map[[sha256.Size]byte]introspection)
+		}
+	}
+	i.cache[key] = introspection{accountID: accountID, expires: expires}
+}
+
+// introspect returns the account id and the expiry, zero when unknown, of an
+// active token
+func (i *introspector) introspect(ctx context.Context, token string) (string, time.Time, error) {
+	if i.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.timeout)
+		defer cancel()
+	}
+
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to introspect the token: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to introspect the token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("unable to introspect the token: unexpected status %s", resp.Status)
+	}
+
+	var fields map[string]interface{}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to decode the introspection response: %v", err)
+	}
+	if active, _ := fields["active"].(bool); !active {
+		return "", time.Time{}, fmt.Errorf("%w: inactive token", ErrMissingTenant)
+	}
+
+	var accountID string
+	switch v := fields[i.field].(type) {
+	case string:
+		accountID = v
+	case json.Number:
+		accountID = v.String()
+	}
+	if accountID == "" {
+		return "", time.Time{}, fmt.Errorf("%w: %q", ErrMissingTenant, i.field)
+	}
+
+	var exp time.Time
+	if n, ok := fields["exp"].(json.Number); ok {
+		if sec, err := n.Int64(); err == nil {
+			exp = time.Unix(sec, 0)
+		}
+	}
+	return accountID, exp, nil
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testIntrospectionServer answers with the response of the posted token
+type testIntrospectionServer struct {
+	*httptest.Server
+	responses map[string]map[string]interface{}
+	calls     int32
+}
+
+func newTestIntrospectionServer(t *testing.T, responses map[string]map[string]interface{}) *testIntrospectionServer {
+	s := &testIntrospectionServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.calls, 1)
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "s3cr3t" {
+			t.Errorf("Invalid client credentials: %q %q", id, secret)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.PostFormValue("token_type_hint") != "access_token" {
+			t.Errorf("Invalid introspection request: %s %v", r.Method, r.PostForm)
+		}
+		resp, ok := s.responses[r.PostFormValue("token")]
+		if !ok {
+			resp = map[string]interface{}{"active": false}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return s
+}
+
+func TestIntrospectionExtractor(t *testing.T) {
+	srv := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"opaque":  {"active": true, MultiTenancyField: testAccountID},
+		"numeric": {"active": true, "org": 1234567890},
+		"no-acc":  {"active": true},
+	})
+	defer srv.Close()
+
+	extractor := NewIntrospectionExtractor(srv.URL, "client", "s3cr3t")
+	for name, tc := range map[string]struct {
+		ctx       context.Context
+		extractor AccountIDExtractor
+		accountID string
+		err       error
+	}{
+		"active":     {ctx: contextWithToken("opaque", DefaultTokenType), extractor: extractor, accountID: testAccountID},
+		"inactive":   {ctx: contextWithToken("revoked", DefaultTokenType), extractor: extractor, err: ErrMissingTenant},
+		"no account": {ctx: contextWithToken("no-acc", DefaultTokenType), extractor: extractor, err: ErrMissingTenant},
+		"no token":   {ctx: context.Background(), extractor: extractor, err: ErrNoToken},
+		"token type": {ctx: contextWithToken("opaque", "Basic"), extractor: extractor, err: ErrMalformedToken},
+		"field": {
+			ctx:       contextWithToken("numeric", DefaultTokenType),
+			extractor: NewIntrospectionExtractor(srv.URL, "client", "s3cr3t", WithIntrospectionAccountIDField("org")),
+			accountID: "1234567890",
+		},
+	} {
+		accountID, err := tc.extractor.ExtractAccountID(tc.ctx)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: invalid error value: %v - expected %v", name, err, tc.err)
+		}
+		if accountID != tc.accountID {
+			t.Errorf("%s: invalid AccountID: %q - expected %q", name, accountID, tc.accountID)
+		}
+	}
+}
+
+func TestIntrospectionExtractor_Cache(t *testing.T) {
+	srv := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"opaque":   {"active": true, MultiTenancyField: testAccountID},
+		"expiring": {"active": true, MultiTenancyField: testAccountID, "exp": time.Now().Add(-time.Second).Unix()},
+	})
+	defer srv.Close()
+
+	extractor := NewIntrospectionExtractor(srv.URL, "client", "s3cr3t")
+	for i := 0; i < 3; i++ {
+		extractor.ExtractAccountID(contextWithToken("opaque", DefaultTokenType))
+		extractor.ExtractAccountID(contextWithToken("revoked", DefaultTokenType))
+	}
+	// only the active tokens are cached
+	if srv.calls != 4 {
+		t.Errorf("Invalid number of calls: %d - expected 4", srv.calls)
+	}
+
+	// the cache does not outlive the exp of the token
+	atomic.StoreInt32(&srv.calls, 0)
+	extractor.ExtractAccountID(contextWithToken("expiring", DefaultTokenType))
+	extractor.ExtractAccountID(contextWithToken("expiring", DefaultTokenType))
+	if srv.calls != 2 {
+		t.Errorf("Invalid number of calls: %d - expected 2", srv.calls)
+	}
+
+	atomic.StoreInt32(&srv.calls, 0)
+	extractor = NewIntrospectionExtractor(srv.URL, "client", "s3cr3t", WithIntrospectionTTL(0))
+	extractor.ExtractAccountID(contextWithToken("opaque", DefaultTokenType))
+	extractor.ExtractAccountID(contextWithToken("opaque", DefaultTokenType))
+	if srv.calls != 2 {
+		t.Errorf("Invalid number of calls: %d - expected 2", srv.calls)
+	}
+}
+
+func TestIntrospectionExtractor_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	extractor := NewIntrospectionExtractor(srv.URL, "client", "s3cr3t", WithIntrospectionTimeout(10*time.Millisecond), WithIntrospectionHTTPClient(srv.Client()))
+	if _, err := extractor.ExtractAccountID(contextWithToken("opaque", DefaultTokenType)); err == nil {
+		t.Errorf("Expected the introspection to time out")
+	}
+}
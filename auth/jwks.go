@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// DefaultJWKSTTL is the default duration the fetched keys are cached for
+	DefaultJWKSTTL = time.Hour
+	// DefaultJWKSMinRefreshInterval is the default minimum duration between
+	// two fetches triggered by an unknown key id or a failed fetch
+	DefaultJWKSMinRefreshInterval = 10 * time.Second
+)
+
+var errUnknownKeyID = errors.New("unable to find the token key id in the JWKS")
+
+// JWKSOption is a type of function that alters the configuration of the
+// keyfunc returned by NewJWKSKeyfunc
+type JWKSOption func(*jwks)
+
+// WithJWKSTTL sets the duration the fetched keys are cached for, the JWKS is
+// fetched again on the first lookup after expiry. Defaults to DefaultJWKSTTL
+func WithJWKSTTL(ttl time.Duration) JWKSOption {
+	return func(j *jwks) {
+		j.ttl = ttl
+	}
+}
+
+// WithJWKSMinRefreshInterval sets the minimum duration between two fetches
+// triggered by a token with an unknown key id, or after a failed fetch, so
+// that such tokens cannot flood the JWKS endpoint. Defaults to
+// DefaultJWKSMinRefreshInterval
+func WithJWKSMinRefreshInterval(d time.Duration) JWKSOption {
+	return func(j *jwks) {
+		j.minRefresh = d
+	}
+}
+
+// WithJWKSHTTPClient sets the client used to fetch the JWKS
+func WithJWKSHTTPClient(client *http.Client) JWKSOption {
+	return func(j *jwks) {
+		j.client = client
+	}
+}
+
+// WithJWKSBackgroundRefresh fetches the JWKS every interval in the background,
+// until ctx is done
+func WithJWKSBackgroundRefresh(ctx context.Context, interval time.Duration) JWKSOption {
+	return func(j *jwks) {
+		j.bgCtx = ctx
+		j.bgInterval = interval
+	}
+}
+
+type jwks struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	bgCtx      context.Context
+	bgInterval time.Duration
+
+	// refreshMu serializes the fetches
+	refreshMu sync.Mutex
+
+	mu      sync.RWMutex
+	keys    map[string]interface{}
+	fetched time.Time
+
+	// attempted and err are the time and the error of the last fetch,
+	// guarded by refreshMu
+	attempted time.Time
+	err       error
+}
+
+// NewJWKSKeyfunc returns a keyfunc verifying the RSA and ECDSA signed tokens
+// with the keys published at the JWKS url. The key is selected by the kid
+// header of the token, the JWKS is fetched on the first use and again when
+// the key id is unknown or the cached keys expire. It is safe for concurrent
+// use, e.g. with WithAccountID or GetAccountID.
+func NewJWKSKeyfunc(url string, opts ...JWKSOption) jwt.Keyfunc {
+	j := &jwks{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		ttl:        DefaultJWKSTTL,
+		minRefresh: DefaultJWKSMinRefreshInterval,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	if j.bgCtx != nil && j.bgInterval > 0 {
+		go j.refreshLoop()
+	}
+	return j.keyfunc
+}
+
+func (j *jwks) keyfunc(token *jwt.Token) (interface{}, error) {
+	// a public key must not be used as an HMAC secret
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, errInvalidSigningMethod
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := j.lookup(kid); ok {
+		return key, nil
+	}
+	if err := j.refresh(false); err != nil {
+		return nil, err
+	}
+	if key, ok := j.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errUnknownKeyID
+}
+
+// lookup returns the cached key, a token without kid matches the single key
+// of the set
+func (j *jwks) lookup(kid string) (interface{}, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.keys == nil || time.Since(j.fetched) > j.ttl {
+		return nil, false
+	}
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+func (j *jwks) refresh(force bool) error {
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+
+	j.mu.RLock()
+	fetched, expired := j.fetched, j.keys == nil || time.Since(j.fetched) > j.ttl
+	j.mu.RUnlock()
+	// another lookup may have refreshed the keys in the meantime, a failing
+	// endpoint is not fetched again before the interval either
+	if !force && time.Since(j.attempted) < j.minRefresh {
+		if j.err != nil {
+			return j.err
+		}
+		if !expired && time.Since(fetched) < j.minRefresh {
+			return nil
+		}
+	}
+
+	keys, err := j.fetch()
+	j.attempted, j.err = time.Now(), err
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.keys, j.fetched = keys, time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *jwks) refreshLoop() {
+	ticker := time.NewTicker(j.bgInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.bgCtx.Done():
+			return
+		case <-ticker.C:
+			// a failed fetch keeps the cached keys until they expire
+			j.refresh(true)
+		}
+	}
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *jwks) fetch() (map[string]interface{}, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch the JWKS: unexpected status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("unable to decode the JWKS: %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// keys that cannot be parsed, e.g. of an unsupported type, are skipped
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid point on curve %q", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// testJWKSServer serves the public keys of the set it holds
+type testJWKSServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    []map[string]string
+	fetches int32
+}
+
+func newTestJWKSServer() *testJWKSServer {
+	s := &testJWKSServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.fetches, 1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	return s
+}
+
+func (s *testJWKSServer) addRSA(kid string, key *rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	})
+}
+
+func (s *testJWKSServer) addEC(kid string, key *ecdsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	})
+}
+
+func signToken(method jwt.SigningMethod, kid string, key interface{}, t *testing.T) string {
+	token := jwt.NewWithClaims(method, jwt.MapClaims{MultiTenancyField: "id-abc-123"})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Error when building token: %v", err)
+	}
+	return signed
+}
+
+func TestNewJWKSKeyfunc(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	srv := newTestJWKSServer()
+	defer srv.Close()
+	srv.addRSA("rsa-key", &rsaKey.PublicKey)
+	srv.addEC("ec-key", &ecKey.PublicKey)
+
+	keyfunc := NewJWKSKeyfunc(srv.URL)
+	for _, token := range []string{
+		signToken(jwt.SigningMethodRS256, "rsa-key", rsaKey, t),
+		signToken(jwt.SigningMethodES256, "ec-key", ecKey, t),
+	} {
+		accountID, err := GetAccountID(contextWithToken(token, DefaultTokenType), keyfunc)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if accountID != "id-abc-123" {
+			t.Errorf("Invalid AccountID: %v - expected %v", accountID, "id-abc-123")
+		}
+	}
+	if srv.fetches != 1 {
+		t.Errorf("Invalid number of fetches: %d - expected 1", srv.fetches)
+	}
+
+	// a token signed by a key not in the set is rejected
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := GetAccountID(contextWithToken(signToken(jwt.SigningMethodRS256, "rsa-key", otherKey, t), DefaultTokenType), keyfunc); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrMalformedToken)
+	}
+}
+
+func TestNewJWKSKeyfunc_RejectsHMAC(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := newTestJWKSServer()
+	defer srv.Close()
+	srv.addRSA("rsa-key", &rsaKey.PublicKey)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{})
+	token.Header["kid"] = "rsa-key"
+	signed, _ := token.SignedString(rsaKey.PublicKey.N.Bytes())
+
+	if _, err := NewJWKSKeyfunc(srv.URL)(parseUnverified(signed, t)); err != errInvalidSigningMethod {
+		t.Errorf("Invalid error value: %v - expected %v", err, errInvalidSigningMethod)
+	}
+}
+
+func TestNewJWKSKeyfunc_RefreshOnMiss(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	srv := newTestJWKSServer()
+	defer srv.Close()
+	srv.addRSA("old", &oldKey.PublicKey)
+
+	keyfunc := NewJWKSKeyfunc(srv.URL, WithJWKSMinRefreshInterval(0))
+	if _, err := keyfunc(parseUnverified(signToken(jwt.SigningMethodRS256, "old", oldKey, t), t)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// the key rotation is picked up on the first unknown kid
+	srv.addRSA("new", &newKey.PublicKey)
+	if _, err := keyfunc(parseUnverified(signToken(jwt.SigningMethodRS256, "new", newKey, t), t)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := keyfunc(parseUnverified(signToken(jwt.SigningMethodRS256, "unknown", newKey, t), t)); err != errUnknownKeyID {
+		t.Errorf("Invalid error value: %v - expected %v", err, errUnknownKeyID)
+	}
+	if srv.fetches != 3 {
+		t.Errorf("Invalid number of fetches: %d - expected 3", srv.fetches)
+	}
+}
+
+func TestNewJWKSKeyfunc_MinRefreshInterval(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := newTestJWKSServer()
+	defer srv.Close()
+	srv.addRSA("known", &key.PublicKey)
+
+	keyfunc := NewJWKSKeyfunc(srv.URL, WithJWKSMinRefreshInterval(time.Hour))
+	for i := 0; i < 5; i++ {
+		keyfunc(parseUnverified(signToken(jwt.SigningMethodRS256, "unknown", key, t), t))
+	}
+	if srv.fetches != 1 {
+		t.Errorf("Invalid number of fetches: %d - expected 1", srv.fetches)
+	}
+}
+
+func TestNewJWKSKeyfunc_FailedFetch(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	// a failing endpoint is not fetched again before the min refresh interval
+	keyfunc := NewJWKSKeyfunc(srv.URL, WithJWKSMinRefreshInterval(time.Hour))
+	for i := 0; i < 5; i++ {
+		if _, err := keyfunc(parseUnverified(signToken(jwt.SigningMethodRS256, "known", key, t), t)); err == nil {
+			t.Errorf("Expected an error for the failed fetch")
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Invalid number of fetches: %d - expected 1", fetches)
+	}
+}
+
+func TestJSONWebKey_PointNotOnCurve(t *testing.T) {
+	one := base64.RawURLEncoding.EncodeToString(big.NewInt(1).Bytes())
+	k := jsonWebKey{Kty: "EC", Crv: "P-256", X: one, Y: one}
+	if key, err := k.publicKey(); err == nil {
+		t.Errorf("Expected an error for the point not on the curve, got key %v", key)
+	}
+}
+
+func TestNewJWKSKeyfunc_TTL(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := newTestJWKSServer()
+	defer srv.Close()
+	srv.addRSA("known", &key.PublicKey)
+
+	keyfunc := NewJWKSKeyfunc(srv.URL, WithJWKSTTL(time.Nanosecond))
+	token := parseUnverified(signToken(jwt.SigningMethodRS256, "known", key, t), t)
+	keyfunc(token)
+	time.Sleep(time.Millisecond)
+	keyfunc(token)
+	if srv.fetches != 2 {
+		t.Errorf("Invalid number of fetches: %d - expected 2", srv.fetches)
+	}
+}
+
+func TestNewJWKSKeyfunc_Concurrent(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := newTestJWKSServer()
+	defer srv.Close()
+	srv.addRSA("known", &key.PublicKey)
+
+	keyfunc := NewJWKSKeyfunc(srv.URL)
+	token := parseUnverified(signToken(jwt.SigningMethodRS256, "known", key, t), t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keyfunc(token); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if srv.fetches != 1 {
+		t.Errorf("Invalid number of fetches: %d - expected 1", srv.fetches)
+	}
+}
+
+func TestNewJWKSKeyfunc_BackgroundRefresh(t *testing.T) {
+	srv := newTestJWKSServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	NewJWKSKeyfunc(srv.URL, WithJWKSBackgroundRefresh(ctx, time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if atomic.LoadInt32(&srv.fetches) == 0 {
+		t.Errorf("Expected the JWKS to be fetched in the background")
+	}
+}
+
+func parseUnverified(token string, t *testing.T) *jwt.Token {
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("Error when parsing token: %v", err)
+	}
+	return parsed
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 	"github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
 )
 
 const (
@@ -26,6 +29,8 @@ var (
 	errMissingToken     = errors.New("unable to get token from context")
 	errInvalidAssertion = errors.New("unable to assert token as jwt.MapClaims")
 
+	errInvalidSigningMethod = errors.New("unexpected token signing method")
+
 	// multiTenancyVariants all possible multi-tenant names
 	multiTenancyVariants = []string{
 		MultiTenancyField,
@@ -35,15 +40,13 @@ var (
 
 // GetJWTFieldWithTokenType gets the JWT from a context and returns the
 // specified field. The user must provide a token type, which prefixes the
-// token itself (e.g. "Bearer" or "token")
-func GetJWTFieldWithTokenType(ctx context.Context, tokenType, tokenField string, keyfunc jwt.Keyfunc) (string, error) {
-	token, err := getToken(ctx, tokenType, keyfunc)
+// token itself (e.g. "Bearer" or "token"). The token errors match ErrNoToken
+// or ErrMalformedToken with errors.Is, with the message "unable to get token
+// from context".
+func GetJWTFieldWithTokenType(ctx context.Context, tokenType, tokenField string, keyfunc jwt.Keyfunc, opts ...Option) (string, error) {
+	claims, err := getClaims(ctx, tokenType, keyfunc, newOptions(opts))
 	if err != nil {
-		return "", errMissingToken
-	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", errInvalidAssertion
+		return "", keepMessage(err, errMissingToken)
 	}
 	jwtField, ok := claims[tokenField]
 	if !ok {
@@ -54,43 +57,253 @@ func GetJWTFieldWithTokenType(ctx context.Context, tokenType, tokenField string,
 
 // GetJWTField gets the JWT from a context and returns the specified field
 // using the DefaultTokenName
-func GetJWTField(ctx context.Context, tokenField string, keyfunc jwt.Keyfunc) (string, error) {
-	return GetJWTFieldWithTokenType(ctx, DefaultTokenType, tokenField, keyfunc)
+func GetJWTField(ctx context.Context, tokenField string, keyfunc jwt.Keyfunc, opts ...Option) (string, error) {
+	return GetJWTFieldWithTokenType(ctx, DefaultTokenType, tokenField, keyfunc, opts...)
 }
 
-// GetAccountID gets the JWT from a context and returns the AccountID field
-func GetAccountID(ctx context.Context, keyfunc jwt.Keyfunc) (string, error) {
-	for _, tenantField := range multiTenancyVariants {
-		if val, err := GetJWTField(ctx, tenantField, keyfunc); err == nil {
+// GetAccountID gets the JWT from a context and returns the AccountID field.
+// The returned error matches ErrNoToken, ErrMalformedToken or ErrMissingTenant
+// with errors.Is. Their message is "unable to get field from token", as before
+// they were typed, followed by the cause for a malformed token.
+func GetAccountID(ctx context.Context, keyfunc jwt.Keyfunc, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	if o.trustedTenancy {
+		if val := metautils.ExtractIncoming(ctx).Get(MultiTenancyField); val != "" {
+			return val, nil
+		}
+	}
+	if o.accountIDHeader != "" && !o.hasToken(ctx) {
+		if val := metautils.ExtractIncoming(ctx).Get(o.accountIDHeader); val != "" {
 			return val, nil
 		}
+		return "", keepMessage(noTokenError(), errMissingField)
+	}
+	claims, err := getClaims(ctx, DefaultTokenType, keyfunc, o)
+	if err != nil {
+		return "", keepMessage(err, errMissingField)
+	}
+	paths := o.accountIDPaths
+	if len(paths) == 0 {
+		paths = multiTenancyVariants
+	}
+	for _, path := range paths {
+		if val, ok := lookupClaimPath(claims, path); ok {
+			return fmt.Sprint(val), nil
+		}
+	}
+	return "", ErrMissingTenant
+}
+
+// lookupClaimPath returns the claim at the dotted path, since claim names may
+// contain dots every split of the path is tried
+func lookupClaimPath(claims map[string]interface{}, path string) (interface{}, bool) {
+	if val, ok := claims[path]; ok {
+		return val, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if nested, ok := claims[path[:i]].(map[string]interface{}); ok {
+			if val, ok := lookupClaimPath(nested, path[i+1:]); ok {
+				return val, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// hasAuthorizationHeader reports whether the incoming metadata has an
+// authorization header
+func hasAuthorizationHeader(ctx context.Context) bool {
+	return ctx != nil && metautils.ExtractIncoming(ctx).Get(AuthorizationHeader) != ""
+}
+
+// hasToken reports whether the incoming metadata has a token, either in the
+// authorization header, in one of the token headers or under the token
+// metadata key
+func (o *options) hasToken(ctx context.Context) bool {
+	if hasAuthorizationHeader(ctx) {
+		return true
+	}
+	if ctx == nil {
+		return false
+	}
+	md := metautils.ExtractIncoming(ctx)
+	for _, key := range o.tokenHeaders {
+		if md.Get(key) != "" {
+			return true
+		}
+	}
+	return o.tokenMetadataKey != "" && md.Get(o.tokenMetadataKey) != ""
+}
+
+// tokenCandidate is a token of the request, or the error reading it
+type tokenCandidate struct {
+	token string
+	err   error
+}
+
+// tokenCandidates returns the tokens of the request in the order they are
+// tried: the authorization header, the token headers, then the token metadata
+// key when the request has no authorization header
+func (o *options) tokenCandidates(ctx context.Context, tokenType string) []tokenCandidate {
+	md := metautils.ExtractIncoming(ctx)
+	var candidates []tokenCandidate
+	if hasAuthorizationHeader(ctx) {
+		token, err := grpc_auth.AuthFromMD(ctx, tokenType)
+		candidates = append(candidates, tokenCandidate{token: token, err: err})
+	}
+	for _, key := range o.tokenHeaders {
+		if val := md.Get(key); val != "" {
+			token, err := tokenFromHeader(val, tokenType)
+			candidates = append(candidates, tokenCandidate{token: token, err: err})
+		}
+	}
+	// the authorization header wins over the metadata key
+	if o.tokenMetadataKey != "" && !hasAuthorizationHeader(ctx) {
+		if val := md.Get(o.tokenMetadataKey); val != "" {
+			candidates = append(candidates, tokenCandidate{token: val})
+		}
+	}
+	return candidates
+}
+
+// tokenFromHeader returns the token of a header value of the form
+// "<type> <token>", as the authorization header
+func tokenFromHeader(val, tokenType string) (string, error) {
+	splits := strings.SplitN(val, " ", 2)
+	if len(splits) < 2 {
+		return "", errors.New("bad authorization string")
+	}
+	if !strings.EqualFold(splits[0], tokenType) {
+		return "", fmt.Errorf("request unauthenticated with %s", tokenType)
 	}
-	return "", errMissingField
+	return splits[1], nil
 }
 
 // getToken parses the token into a jwt.Token type from the grpc metadata.
 // WARNING: if keyfunc is nil, the token will get parsed but not verified
 // because it has been checked previously in the stack. More information
 // here: https://pkg.go.dev/github.com/golang-jwt/jwt/v4#Parser.ParseUnverified
-func getToken(ctx context.Context, tokenField string, keyfunc jwt.Keyfunc) (jwt.Token, error) {
-	if ctx == nil {
-		return jwt.Token{}, errMissingToken
+// Tokens using the "none" signing method are rejected in both cases.
+// The first of the tokens of the request that is valid is returned, or else
+// the error of the first one.
+func getToken(ctx context.Context, tokenField string, keyfunc jwt.Keyfunc, o *options) (jwt.Token, error) {
+	if ctx == nil || !o.hasToken(ctx) {
+		return jwt.Token{}, noTokenError()
+	}
+	var firstErr error
+	for _, c := range o.tokenCandidates(ctx, tokenField) {
+		err := c.err
+		if err == nil {
+			var token jwt.Token
+			if token, err = parseCachedToken(ctx, c.token, keyfunc, o); err == nil {
+				return token, nil
+			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return jwt.Token{}, malformedTokenError(firstErr)
+}
+
+// parseCachedToken parses the token, or returns the one parsed earlier in the
+// request, see WithTokenCache
+func parseCachedToken(ctx context.Context, tokenStr string, keyfunc jwt.Keyfunc, o *options) (jwt.Token, error) {
+	cache := tokenCacheFromContext(ctx)
+	if cache != nil {
+		if token, ok := cache.get(tokenStr, keyfunc); ok {
+			if !o.allowsMethod(token.Method.Alg()) {
+				return jwt.Token{}, errInvalidSigningMethod
+			}
+			// the cached token may have been parsed without claims validation
+			if keyfunc != nil && !o.skipClaimsValidation {
+				if err := o.validClaims(token.Claims); err != nil {
+					return jwt.Token{}, err
+				}
+			}
+			return token, nil
+		}
 	}
-	tokenStr, err := grpc_auth.AuthFromMD(ctx, tokenField)
+	verify := keyfunc
+	var key interface{}
+	if cache != nil && keyfunc != nil {
+		// the key is cached for the calls given another keyfunc
+		verify = func(token *jwt.Token) (interface{}, error) {
+			k, err := keyfunc(token)
+			key = k
+			return k, err
+		}
+	}
+	token, err := parseToken(tokenStr, verify, o)
 	if err != nil {
 		return jwt.Token{}, err
 	}
-	parser := jwt.Parser{}
+	if cache != nil {
+		cache.set(tokenStr, token, keyfunc != nil, key)
+	}
+	return token, nil
+}
+
+func parseToken(tokenStr string, keyfunc jwt.Keyfunc, o *options) (jwt.Token, error) {
+	// the claims are validated below when there is a leeway, which the parser lacks
+	parser := jwt.Parser{ValidMethods: o.validMethods, SkipClaimsValidation: o.skipClaimsValidation || o.leeway > 0}
 	if keyfunc != nil {
-		token, err := parser.Parse(tokenStr, keyfunc)
+		token, err := parser.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+			// checked before the keyfunc, which could accept the none method
+			if !o.allowsMethod(token.Method.Alg()) {
+				return nil, errInvalidSigningMethod
+			}
+			return keyfunc(token)
+		})
 		if err != nil {
 			return jwt.Token{}, err
 		}
+		if o.leeway > 0 && !o.skipClaimsValidation {
+			if err := o.validClaims(token.Claims); err != nil {
+				return jwt.Token{}, err
+			}
+		}
 		return *token, nil
 	}
 	token, _, err := parser.ParseUnverified(tokenStr, jwt.MapClaims{})
 	if err != nil {
 		return jwt.Token{}, err
 	}
+	if !o.allowsMethod(token.Method.Alg()) {
+		return jwt.Token{}, errInvalidSigningMethod
+	}
 	return *token, nil
 }
+
+// validClaims checks the exp, iat and nbf claims as jwt.MapClaims.Valid does,
+// with the valid window widened by the leeway
+func (o *options) validClaims(claims jwt.Claims) error {
+	mc, ok := claims.(jwt.MapClaims)
+	if !ok || o.leeway == 0 {
+		return claims.Valid()
+	}
+	now := time.Now()
+	switch {
+	case !mc.VerifyExpiresAt(now.Add(-o.leeway).Unix(), false):
+		return jwt.NewValidationError("token is expired", jwt.ValidationErrorExpired)
+	case !mc.VerifyIssuedAt(now.Add(o.leeway).Unix(), false):
+		return jwt.NewValidationError("token used before issued", jwt.ValidationErrorIssuedAt)
+	case !mc.VerifyNotBefore(now.Add(o.leeway).Unix(), false):
+		return jwt.NewValidationError("token is not valid yet", jwt.ValidationErrorNotValidYet)
+	}
+	return nil
+}
+
+// HMACKeyfunc returns a keyfunc verifying the tokens signed with the secret
+// using any method of the HMAC family (HS256, HS384 or HS512)
+func HMACKeyfunc(secret []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errInvalidSigningMethod
+		}
+		return secret, nil
+	}
+}
@@ -2,8 +2,11 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 	"google.golang.org/grpc/metadata"
@@ -54,7 +57,7 @@ func TestGetJWTFieldWithTokenType(t *testing.T) {
 			tokenType: "token",
 			field:     "some-field",
 			expected:  "",
-			err:       errMissingToken,
+			err:       ErrMalformedToken,
 		},
 		{
 			contextFactory: func(t *testing.T) context.Context {
@@ -71,9 +74,12 @@ func TestGetJWTFieldWithTokenType(t *testing.T) {
 
 		actual, err := GetJWTFieldWithTokenType(ctx, test.tokenType, test.field, nil)
 
-		if err != test.err {
+		if !errors.Is(err, test.err) {
 			t.Errorf("Invalid error value: %v - expected %v", err, test.err)
 		}
+		if test.err != nil && test.err != errMissingField && !strings.HasPrefix(err.Error(), errMissingToken.Error()) {
+			t.Errorf("Invalid error message: %q - expected the one of %v", err, errMissingToken)
+		}
 		if actual != test.expected {
 			t.Errorf("Invalid JWT field: %v - expected %v", actual, test.expected)
 		}
@@ -153,7 +159,321 @@ func TestGetAccountID(t *testing.T) {
 	}
 }
 
+func TestGetAccountID_TypedErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ctx     context.Context
+		keyfunc jwt.Keyfunc
+		err     error
+	}{
+		"no token":        {ctx: context.Background(), err: ErrNoToken},
+		"malformed token": {ctx: contextWithToken("malformed", DefaultTokenType), err: ErrMalformedToken},
+		"invalid token":   {ctx: contextWithToken(makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t), DefaultTokenType), keyfunc: HMACKeyfunc([]byte("other-secret")), err: ErrMalformedToken},
+		"missing tenant":  {ctx: contextWithToken(makeToken(jwt.MapClaims{}, t), DefaultTokenType), err: ErrMissingTenant},
+	} {
+		_, err := GetAccountID(tc.ctx, tc.keyfunc)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.err)
+		}
+		// the token errors are claim getter errors as well
+		if tc.err != ErrMissingTenant && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, ErrInvalidToken)
+		}
+		if tc.err != ErrMissingTenant && errors.Is(err, ErrMissingTenant) {
+			t.Errorf("Invalid error value (%s): %v - unexpected %v", name, err, ErrMissingTenant)
+		}
+		// the message of the untyped errors is kept for the log-based dashboards
+		if err != nil && !strings.HasPrefix(err.Error(), "unable to get field from token") {
+			t.Errorf("Invalid error message (%s): %q - expected the one of %v", name, err, errMissingField)
+		}
+	}
+}
+
+func TestHMACKeyfunc(t *testing.T) {
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS256, jwt.SigningMethodHS384, jwt.SigningMethodHS512} {
+		token := makeTokenWithMethod(method, jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+		ctx := contextWithToken(token, DefaultTokenType)
+
+		actual, err := GetAccountID(ctx, HMACKeyfunc([]byte(TestSecret)))
+		if err != nil {
+			t.Errorf("Unexpected error with %s: %v", method.Alg(), err)
+		}
+		if actual != "id-abc-123" {
+			t.Errorf("Invalid AccountID with %s: %v - expected %v", method.Alg(), actual, "id-abc-123")
+		}
+	}
+}
+
+func TestWithSigningMethods(t *testing.T) {
+	token := makeTokenWithMethod(jwt.SigningMethodHS512, jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+	ctx := contextWithToken(token, DefaultTokenType)
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	if _, err := GetAccountID(ctx, keyfunc, WithSigningMethods("HS256")); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrMalformedToken)
+	}
+	if _, err := GetAccountID(ctx, keyfunc, WithSigningMethods("HS256", "HS512")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// the restriction applies to the cached token as well
+	ctx = WithTokenCache(ctx)
+	GetAccountID(ctx, keyfunc)
+	if _, err := GetAccountID(ctx, keyfunc, WithSigningMethods("HS256")); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrMalformedToken)
+	}
+}
+
+func TestGetAccountID_NoneSigningMethod(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{MultiTenancyField: "id-abc-123"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("Error when building token: %v", err)
+	}
+	ctx := contextWithToken(token, DefaultTokenType)
+
+	unsafeKeyfunc := func(*jwt.Token) (interface{}, error) { return jwt.UnsafeAllowNoneSignatureType, nil }
+	for name, keyfunc := range map[string]jwt.Keyfunc{"unverified": nil, "unsafe keyfunc": unsafeKeyfunc} {
+		if _, err := GetAccountID(ctx, keyfunc); !errors.Is(err, ErrMalformedToken) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, ErrMalformedToken)
+		}
+	}
+}
+
+func TestGetAccountID_AccountIDHeader(t *testing.T) {
+	token := makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+
+	for name, tc := range map[string]struct {
+		md       metadata.MD
+		opts     []Option
+		expected string
+		err      error
+	}{
+		"header":         {md: metadata.Pairs("x-account-id", "id-def-456"), opts: []Option{WithAccountIDHeader("X-Account-ID")}, expected: "id-def-456"},
+		"token precedes": {md: metadata.Pairs("authorization", "Bearer "+token, "x-account-id", "id-def-456"), opts: []Option{WithAccountIDHeader("X-Account-ID")}, expected: "id-abc-123"},
+		"missing header": {md: metadata.Pairs(), opts: []Option{WithAccountIDHeader("X-Account-ID")}, err: ErrNoToken},
+		"disabled":       {md: metadata.Pairs("x-account-id", "id-def-456"), err: ErrNoToken},
+		"invalid token":  {md: metadata.Pairs("authorization", "Bearer invalid", "x-account-id", "id-def-456"), opts: []Option{WithAccountIDHeader("X-Account-ID")}, err: ErrMalformedToken},
+	} {
+		actual, err := GetAccountID(metadata.NewIncomingContext(context.Background(), tc.md), nil, tc.opts...)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.err)
+		}
+		if actual != tc.expected {
+			t.Errorf("Invalid AccountID (%s): %v - expected %v", name, actual, tc.expected)
+		}
+	}
+}
+
+func TestGetAccountID_TrustedMetadataTenancy(t *testing.T) {
+	token := makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	for name, tc := range map[string]struct {
+		md       metadata.MD
+		opts     []Option
+		expected string
+		err      error
+	}{
+		"metadata":         {md: metadata.Pairs(MultiTenancyField, "id-def-456"), opts: []Option{WithTrustedMetadataTenancy()}, expected: "id-def-456"},
+		"metadata precede": {md: metadata.Pairs("authorization", "Bearer "+token, MultiTenancyField, "id-def-456"), opts: []Option{WithTrustedMetadataTenancy()}, expected: "id-def-456"},
+		"no token parsing": {md: metadata.Pairs("authorization", "Bearer invalid", MultiTenancyField, "id-def-456"), opts: []Option{WithTrustedMetadataTenancy()}, expected: "id-def-456"},
+		"token fallback":   {md: metadata.Pairs("authorization", "Bearer "+token), opts: []Option{WithTrustedMetadataTenancy()}, expected: "id-abc-123"},
+		"disabled":         {md: metadata.Pairs("authorization", "Bearer "+token, MultiTenancyField, "id-def-456"), expected: "id-abc-123"},
+		"nothing":          {md: metadata.Pairs(), opts: []Option{WithTrustedMetadataTenancy()}, err: ErrNoToken},
+	} {
+		actual, err := GetAccountID(metadata.NewIncomingContext(context.Background(), tc.md), keyfunc, tc.opts...)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.err)
+		}
+		if actual != tc.expected {
+			t.Errorf("Invalid AccountID (%s): %v - expected %v", name, actual, tc.expected)
+		}
+	}
+}
+
+func TestGetAccountID_ClaimPaths(t *testing.T) {
+	token := makeToken(jwt.MapClaims{
+		MultiTenancyField:            "id-abc-123",
+		"metadata":                   map[string]interface{}{"tenant_id": "id-def-456", "org": map[string]interface{}{"id": 42}},
+		"https://example.com/claims": map[string]interface{}{"tenant_id": "id-ghi-789"},
+	}, t)
+	ctx := contextWithToken(token, DefaultTokenType)
+
+	for name, tc := range map[string]struct {
+		paths    []string
+		expected string
+		err      error
+	}{
+		"nested":               {paths: []string{"metadata.tenant_id"}, expected: "id-def-456"},
+		"deeply nested":        {paths: []string{"metadata.org.id"}, expected: "42"},
+		"dotted claim name":    {paths: []string{"https://example.com/claims.tenant_id"}, expected: "id-ghi-789"},
+		"fallback":             {paths: []string{"missing.tenant_id", "metadata.tenant_id"}, expected: "id-def-456"},
+		"flat":                 {paths: []string{MultiTenancyField}, expected: "id-abc-123"},
+		"missing intermediate": {paths: []string{"missing.tenant_id"}, err: ErrMissingTenant},
+		"not an object":        {paths: []string{"account_id.tenant_id"}, err: ErrMissingTenant},
+	} {
+		actual, err := GetAccountID(ctx, nil, WithAccountIDClaimPaths(tc.paths...))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.err)
+		}
+		if actual != tc.expected {
+			t.Errorf("Invalid AccountID (%s): %v - expected %v", name, actual, tc.expected)
+		}
+	}
+}
+
+func TestGetAccountID_TokenMetadataKey(t *testing.T) {
+	queryToken := makeToken(jwt.MapClaims{MultiTenancyField: "id-def-456"}, t)
+	headerToken := makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	for name, tc := range map[string]struct {
+		md       metadata.MD
+		keyfunc  jwt.Keyfunc
+		opts     []Option
+		expected string
+		err      error
+	}{
+		"query token":     {md: metadata.Pairs("query-token", queryToken), keyfunc: keyfunc, opts: []Option{WithTokenMetadataKey("query-token")}, expected: "id-def-456"},
+		"header precedes": {md: metadata.Pairs("query-token", queryToken, "authorization", "Bearer "+headerToken), keyfunc: keyfunc, opts: []Option{WithTokenMetadataKey("query-token")}, expected: "id-abc-123"},
+		"disabled":        {md: metadata.Pairs("query-token", queryToken), keyfunc: keyfunc, err: ErrNoToken},
+		"invalid":         {md: metadata.Pairs("query-token", queryToken), keyfunc: HMACKeyfunc([]byte("other-secret")), opts: []Option{WithTokenMetadataKey("query-token")}, err: ErrMalformedToken},
+		"header fallback": {md: metadata.Pairs("query-token", queryToken, "x-account-id", "id-ghi-789"), keyfunc: keyfunc, opts: []Option{WithTokenMetadataKey("query-token"), WithAccountIDHeader("x-account-id")}, expected: "id-def-456"},
+	} {
+		actual, err := GetAccountID(metadata.NewIncomingContext(context.Background(), tc.md), tc.keyfunc, tc.opts...)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.err)
+		}
+		if actual != tc.expected {
+			t.Errorf("Invalid AccountID (%s): %v - expected %v", name, actual, tc.expected)
+		}
+	}
+}
+
+func TestGetAccountID_TokenHeaders(t *testing.T) {
+	webToken := makeToken(jwt.MapClaims{MultiTenancyField: "id-def-456"}, t)
+	headerToken := makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+	otherToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{MultiTenancyField: "id-ghi-789"}).SignedString([]byte("other-secret"))
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+	opts := []Option{WithTokenHeaders("x-grpc-web-auth")}
+
+	for name, tc := range map[string]struct {
+		md       metadata.MD
+		opts     []Option
+		expected string
+		err      error
+	}{
+		"custom header only": {md: metadata.Pairs("x-grpc-web-auth", "Bearer "+webToken), opts: opts, expected: "id-def-456"},
+		"header precedes":    {md: metadata.Pairs("x-grpc-web-auth", "Bearer "+webToken, "authorization", "Bearer "+headerToken), opts: opts, expected: "id-abc-123"},
+		"first valid wins":   {md: metadata.Pairs("x-grpc-web-auth", "Bearer "+webToken, "authorization", "Bearer "+otherToken), opts: opts, expected: "id-def-456"},
+		"all invalid":        {md: metadata.Pairs("x-grpc-web-auth", "Bearer "+otherToken, "authorization", "Bearer "+otherToken), opts: opts, err: ErrMalformedToken},
+		"token type":         {md: metadata.Pairs("x-grpc-web-auth", webToken), opts: opts, err: ErrMalformedToken},
+		"disabled":           {md: metadata.Pairs("x-grpc-web-auth", "Bearer "+webToken), err: ErrNoToken},
+	} {
+		actual, err := GetAccountID(metadata.NewIncomingContext(context.Background(), tc.md), keyfunc, tc.opts...)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.err)
+		}
+		if actual != tc.expected {
+			t.Errorf("Invalid AccountID (%s): %v - expected %v", name, actual, tc.expected)
+		}
+	}
+}
+
 // creates a context with a jwt
+func TestGetAccountID_Leeway(t *testing.T) {
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+	now := time.Now()
+	expired := makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123", "exp": now.Add(-2 * time.Second).Unix()}, t)
+	notYetValid := makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123", "nbf": now.Add(2 * time.Second).Unix()}, t)
+
+	for name, tc := range map[string]struct {
+		token  string
+		leeway time.Duration
+		err    error
+	}{
+		"expired without leeway":       {token: expired, err: ErrMalformedToken},
+		"expired within leeway":        {token: expired, leeway: 5 * time.Second},
+		"expired beyond leeway":        {token: expired, leeway: time.Second, err: ErrMalformedToken},
+		"not valid yet without leeway": {token: notYetValid, err: ErrMalformedToken},
+		"not valid yet within leeway":  {token: notYetValid, leeway: 5 * time.Second},
+		"not valid yet beyond leeway":  {token: notYetValid, leeway: time.Second, err: ErrMalformedToken},
+	} {
+		ctx := contextWithToken(tc.token, DefaultTokenType)
+		actual, err := GetAccountID(ctx, keyfunc, WithLeeway(tc.leeway))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.err)
+		}
+		if tc.err == nil && actual != "id-abc-123" {
+			t.Errorf("Invalid AccountID (%s): %v - expected %v", name, actual, "id-abc-123")
+		}
+	}
+}
+
+func TestGetAccountID_RequiredClaims(t *testing.T) {
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	for name, tc := range map[string]struct {
+		claims   jwt.MapClaims
+		required []string
+		missing  string
+	}{
+		"present": {
+			claims:   jwt.MapClaims{MultiTenancyField: "id-abc-123", "sub": "user-1", "email": "user@example.com"},
+			required: []string{"sub", "email"},
+		},
+		"nested present": {
+			claims:   jwt.MapClaims{MultiTenancyField: "id-abc-123", "metadata": map[string]interface{}{"email": "user@example.com"}},
+			required: []string{"metadata.email"},
+		},
+		"missing": {
+			claims:   jwt.MapClaims{MultiTenancyField: "id-abc-123", "sub": "user-1"},
+			required: []string{"sub", "email"},
+			missing:  "email",
+		},
+		"null": {
+			claims:   jwt.MapClaims{MultiTenancyField: "id-abc-123", "sub": nil},
+			required: []string{"sub"},
+			missing:  "sub",
+		},
+		"missing tenant": {
+			claims:   jwt.MapClaims{"sub": "user-1"},
+			required: []string{MultiTenancyField, "sub"},
+			missing:  MultiTenancyField,
+		},
+	} {
+		ctx := contextWithToken(makeToken(tc.claims, t), DefaultTokenType)
+		actual, err := GetAccountID(ctx, keyfunc, WithRequiredClaims(tc.required...))
+		if tc.missing == "" {
+			if err != nil || actual != "id-abc-123" {
+				t.Errorf("Invalid AccountID (%s): %v, %v - expected %v", name, actual, err, "id-abc-123")
+			}
+			continue
+		}
+		if !errors.Is(err, ErrMissingClaim) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, ErrMissingClaim)
+		}
+		if err != nil && !strings.Contains(err.Error(), fmt.Sprintf("%q", tc.missing)) {
+			t.Errorf("Invalid error message (%s): %v - expected the claim %q", name, err, tc.missing)
+		}
+	}
+}
+
+func TestGetJWTField_RequiredClaims(t *testing.T) {
+	ctx := contextWithToken(makeToken(jwt.MapClaims{"sub": "user-1"}, t), DefaultTokenType)
+
+	actual, err := GetJWTField(ctx, "sub", nil, WithRequiredClaims("sub"))
+	if err != nil || actual != "user-1" {
+		t.Errorf("Invalid JWT field: %v, %v - expected %v", actual, err, "user-1")
+	}
+
+	_, err = GetJWTFieldWithTokenType(ctx, DefaultTokenType, "sub", nil, WithRequiredClaims("sub", "email"))
+	if !errors.Is(err, ErrMissingClaim) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrMissingClaim)
+	}
+	if err != nil && !strings.Contains(err.Error(), `"email"`) {
+		t.Errorf("Invalid error message: %v - expected the claim %q", err, "email")
+	}
+}
+
 func contextWithToken(token, tokenType string) context.Context {
 	md := metadata.Pairs(
 		"authorization", fmt.Sprintf("%s %s", tokenType, token),
@@ -163,7 +483,11 @@ func contextWithToken(token, tokenType string) context.Context {
 
 // generates a token string based on the given jwt claims
 func makeToken(claims jwt.Claims, t *testing.T) string {
-	method := jwt.SigningMethodHS256
+	return makeTokenWithMethod(jwt.SigningMethodHS256, claims, t)
+}
+
+// generates a token string signed with the given method
+func makeTokenWithMethod(method jwt.SigningMethod, claims jwt.Claims, t *testing.T) string {
 	token := jwt.NewWithClaims(method, claims)
 	signingString, err := token.SigningString()
 	if err != nil {
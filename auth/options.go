@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option is a type of function that alters the options of the token parsing
+// done by GetAccountID, GetJWTField and GetJWTFieldWithTokenType
+type Option func(*options)
+
+type options struct {
+	validMethods []string
+	// skipClaimsValidation leaves the exp, nbf and iat checks to the caller
+	skipClaimsValidation bool
+	accountIDHeader      string
+	trustedTenancy       bool
+	accountIDPaths       []string
+	tokenMetadataKey     string
+	tokenHeaders         []string
+	leeway               time.Duration
+	requiredClaims       []string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSigningMethods restricts the accepted tokens to the given signing
+// methods, e.g. "HS256" or "HS512". By default any method accepted by the
+// keyfunc is, apart from "none" which is always rejected.
+func WithSigningMethods(methods ...string) Option {
+	return func(o *options) {
+		o.validMethods = methods
+	}
+}
+
+// allowsMethod reports whether the token signing method is accepted
+func (o *options) allowsMethod(alg string) bool {
+	if alg == "none" {
+		return false
+	}
+	if len(o.validMethods) == 0 {
+		return true
+	}
+	for _, m := range o.validMethods {
+		if m == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// WithAccountIDHeader makes GetAccountID return the value of the given
+// metadata header, e.g. "X-Account-ID", when the request has no authorization
+// token. A token always takes precedence over the header. As the header can
+// be set by any client, it must only be trusted when the network guarantees
+// its origin, e.g. when set by a service mesh.
+func WithAccountIDHeader(header string) Option {
+	return func(o *options) {
+		o.accountIDHeader = header
+	}
+}
+
+// WithTrustedMetadataTenancy makes GetAccountID return the account id set
+// under the MultiTenancyField key of the incoming metadata, e.g. by
+// WithOutgoingAccountID, without parsing the token. The token is parsed as
+// usual when the metadata has no account id. As any client can set the
+// metadata, it must only be enabled for the calls between services behind a
+// trusted boundary, never for the ones of external callers.
+func WithTrustedMetadataTenancy() Option {
+	return func(o *options) {
+		o.trustedTenancy = true
+	}
+}
+
+// WithAccountIDClaimPaths makes GetAccountID read the account id from the first
+// of the given claim paths found in the token, instead of the top-level
+// account_id or AccountID claims. A path descends through the nested claims
+// with dots, e.g. "metadata.tenant_id", and may contain claims with dots in
+// their name, e.g. "https://example.com/claims.tenant_id".
+func WithAccountIDClaimPaths(paths ...string) Option {
+	return func(o *options) {
+		o.accountIDPaths = paths
+	}
+}
+
+// WithTokenMetadataKey makes the token be read from the given metadata key,
+// without token type, when the request has no authorization header. It is
+// meant for the browser clients passing the token as a query parameter, see
+// gateway.NewQueryParamAnnotator, and should only be enabled on the routes
+// that need it since such tokens end up in URLs and access logs.
+func WithTokenMetadataKey(key string) Option {
+	return func(o *options) {
+		o.tokenMetadataKey = key
+	}
+}
+
+// WithTokenHeaders makes the token also be read from the given metadata keys,
+// e.g. "x-grpc-web-auth" for the gRPC-Web clients whose proxy drops the
+// authorization header. Their values have the form of the authorization
+// header, "Bearer <token>". They are tried in order after the authorization
+// header, and the first valid token wins.
+func WithTokenHeaders(keys ...string) Option {
+	return func(o *options) {
+		o.tokenHeaders = keys
+	}
+}
+
+// WithLeeway widens the window of the exp, nbf and iat checks of the token by
+// the given duration on both sides, to tolerate the clock skew between the
+// token issuer and the service. Defaults to zero, i.e. no tolerance.
+func WithLeeway(d time.Duration) Option {
+	return func(o *options) {
+		o.leeway = d
+	}
+}
+
+// WithRequiredClaims makes the token getters, GetAccountID among them, fail
+// with ErrMissingClaim naming the first of the given claims that is absent or
+// null in an otherwise valid token. The names may be dotted paths as those of
+// WithAccountIDClaimPaths, and MultiTenancyField makes the tenant required the
+// same way. The account ids not read from the token, see WithAccountIDHeader
+// and WithTrustedMetadataTenancy, are not checked.
+func WithRequiredClaims(names ...string) Option {
+	return func(o *options) {
+		o.requiredClaims = append(o.requiredClaims, names...)
+	}
+}
+
+// missingClaim returns the error of the first required claim absent from the
+// claims
+func (o *options) missingClaim(claims map[string]interface{}) error {
+	for _, name := range o.requiredClaims {
+		if val, ok := lookupClaimPath(claims, name); !ok || val == nil {
+			return fmt.Errorf("%w: %q", ErrMissingClaim, name)
+		}
+	}
+	return nil
+}
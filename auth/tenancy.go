@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type accountIDKeyType struct{}
+
+var accountIDKey = accountIDKeyType{}
+
+// AccountIDFromContext returns the account id stored in the context by the
+// tenancy interceptors
+func AccountIDFromContext(ctx context.Context) (string, bool) {
+	accountID, ok := ctx.Value(accountIDKey).(string)
+	return accountID, ok
+}
+
+// NewContextWithAccountID returns a context holding the account id, as read by
+// AccountIDFromContext
+func NewContextWithAccountID(ctx context.Context, accountID string) context.Context {
+	return context.WithValue(ctx, accountIDKey, accountID)
+}
+
+// TenancyOption is a type of function that alters the configuration of the
+// tenancy interceptors
+type TenancyOption func(*tenancyOptions)
+
+type tenancyOptions struct {
+	anonymous   map[string]struct{}
+	opts        []Option
+	tlsIdentity bool
+	tlsFields   []TLSIdentityField
+	extractor   AccountIDExtractor
+}
+
+// WithAnonymousMethods allows the given methods, in the /service/Method form,
+// to be called without account id
+func WithAnonymousMethods(fullMethods ...string) TenancyOption {
+	return func(o *tenancyOptions) {
+		for _, m := range fullMethods {
+			o.anonymous[m] = struct{}{}
+		}
+	}
+}
+
+// WithTenancyOptions sets the options used to extract the account id, e.g.
+// WithSigningMethods
+func WithTenancyOptions(opts ...Option) TenancyOption {
+	return func(o *tenancyOptions) {
+		o.opts = append(o.opts, opts...)
+	}
+}
+
+// WithTLSIdentity accepts the requests without token whose caller is
+// authenticated by a client certificate, e.g. the internal mesh traffic. The
+// identity read by IdentityFromTLS from the given fields is stored in the
+// context, see IdentityFromContext, the account id is left unset.
+func WithTLSIdentity(fields ...TLSIdentityField) TenancyOption {
+	return func(o *tenancyOptions) {
+		o.tlsIdentity = true
+		o.tlsFields = fields
+	}
+}
+
+// WithAccountIDExtractor extracts the account id with the given extractor, e.g.
+// for tokens that are not JWTs, instead of GetAccountID. The keyfunc and the
+// options of WithTenancyOptions are then unused.
+func WithAccountIDExtractor(extractor AccountIDExtractor) TenancyOption {
+	return func(o *tenancyOptions) {
+		o.extractor = extractor
+	}
+}
+
+func newTenancyOptions(opts []TenancyOption) *tenancyOptions {
+	o := &tenancyOptions{anonymous: map[string]struct{}{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// tenancyContext returns the context holding the account id of the request,
+// or an Unauthenticated error unless the method can be called anonymously
+func (o *tenancyOptions) tenancyContext(ctx context.Context, fullMethod string, extractor AccountIDExtractor) (context.Context, error) {
+	accountID, err := extractor.ExtractAccountID(ctx)
+	if err == nil {
+		return NewContextWithAccountID(ctx, accountID), nil
+	}
+	if o.tlsIdentity && errors.Is(err, ErrNoToken) {
+		if id, tlsErr := IdentityFromTLS(ctx, o.tlsFields...); tlsErr == nil {
+			return NewContextWithIdentity(ctx, id), nil
+		}
+	}
+	if _, ok := o.anonymous[fullMethod]; ok {
+		return ctx, nil
+	}
+	return nil, status.Error(codes.Unauthenticated, err.Error())
+}
+
+// accountIDExtractor returns the extractor of WithAccountIDExtractor, or else
+// the JWT one
+func (o *tenancyOptions) accountIDExtractor(keyfunc jwt.Keyfunc) AccountIDExtractor {
+	if o.extractor != nil {
+		return o.extractor
+	}
+	return JWTAccountIDExtractor(keyfunc, o.opts...)
+}
+
+// TenancyInterceptor returns grpc.UnaryServerInterceptor which extracts the
+// account id of the request and stores it in the context for the handlers,
+// see AccountIDFromContext. The requests without account id are rejected with
+// codes.Unauthenticated, unless their method is allowed by WithAnonymousMethods
+// or their caller is authenticated by WithTLSIdentity.
+func TenancyInterceptor(keyfunc jwt.Keyfunc, opts ...TenancyOption) grpc.UnaryServerInterceptor {
+	o := newTenancyOptions(opts)
+	extractor := o.accountIDExtractor(keyfunc)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := o.tenancyContext(ctx, info.FullMethod, extractor)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TenancyStreamInterceptor is the streaming counterpart of TenancyInterceptor
+func TenancyStreamInterceptor(keyfunc jwt.Keyfunc, opts ...TenancyOption) grpc.StreamServerInterceptor {
+	o := newTenancyOptions(opts)
+	extractor := o.accountIDExtractor(keyfunc)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := o.tenancyContext(stream.Context(), info.FullMethod, extractor)
+		if err != nil {
+			return err
+		}
+		wrapped := grpc_middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
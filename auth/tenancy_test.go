@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	mock_transport "github.com/armezit/atlas-app-toolkit/mocks/transport"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/grpc-ecosystem/go-grpc-middleware/util/metautils"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTenancyInterceptor(t *testing.T) {
+	const anonymousMethod = "/app.Object/PublicMethod"
+	interceptor := TenancyInterceptor(nil, WithAnonymousMethods(anonymousMethod))
+	tokenCtx := contextWithToken(makeToken(jwt.MapClaims{MultiTenancyField: testAccountID}, t), DefaultTokenType)
+
+	for name, tc := range map[string]struct {
+		ctx       context.Context
+		method    string
+		accountID string
+		code      codes.Code
+	}{
+		"account id":         {ctx: tokenCtx, method: testFullMethod, accountID: testAccountID},
+		"anonymous":          {ctx: context.Background(), method: anonymousMethod},
+		"anonymous with id":  {ctx: tokenCtx, method: anonymousMethod, accountID: testAccountID},
+		"unauthenticated":    {ctx: context.Background(), method: testFullMethod, code: codes.Unauthenticated},
+		"missing account id": {ctx: contextWithToken(makeToken(jwt.MapClaims{}, t), DefaultTokenType), method: testFullMethod, code: codes.Unauthenticated},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var called bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				accountID, ok := AccountIDFromContext(ctx)
+				assert.Equal(t, tc.accountID != "", ok)
+				assert.Equal(t, tc.accountID, accountID)
+				return nil, nil
+			}
+			_, err := interceptor(tc.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+			assert.Equal(t, tc.code, status.Code(err))
+			assert.Equal(t, tc.code == codes.OK, called)
+		})
+	}
+}
+
+func TestTenancyStreamInterceptor(t *testing.T) {
+	interceptor := TenancyStreamInterceptor(nil)
+	ctx := metadata.NewIncomingContext(mock_transport.DummyContextWithServerTransportStream(), metadata.Pairs(testAuthorizationHeader, testJWT))
+
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		accountID, _ := AccountIDFromContext(stream.Context())
+		assert.Equal(t, testAccountID, accountID)
+		return nil
+	}
+	assert.NoError(t, interceptor(testRequest{}, mock_transport.NewMockServerStream(ctx), &grpc.StreamServerInfo{FullMethod: testFullMethod}, handler))
+
+	err := interceptor(testRequest{}, mock_transport.NewMockServerStream(mock_transport.DummyContextWithServerTransportStream()), &grpc.StreamServerInfo{FullMethod: testFullMethod}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestTenancyInterceptor_AccountIDExtractor(t *testing.T) {
+	// a legacy token of the form "v1.<account id>"
+	extractor := AccountIDExtractorFunc(func(ctx context.Context) (string, error) {
+		token := metautils.ExtractIncoming(ctx).Get("x-legacy-token")
+		if token == "" {
+			return "", ErrNoToken
+		}
+		if !strings.HasPrefix(token, "v1.") {
+			return "", fmt.Errorf("%w: unknown version", ErrMalformedToken)
+		}
+		return strings.TrimPrefix(token, "v1."), nil
+	})
+	interceptor := TenancyInterceptor(nil, WithAccountIDExtractor(extractor))
+
+	for name, tc := range map[string]struct {
+		md        metadata.MD
+		accountID string
+		code      codes.Code
+	}{
+		"account id": {md: metadata.Pairs("x-legacy-token", "v1."+testAccountID), accountID: testAccountID},
+		"malformed":  {md: metadata.Pairs("x-legacy-token", "v2.abc"), code: codes.Unauthenticated},
+		// the JWT is not read anymore
+		"jwt": {md: metadata.Pairs(testAuthorizationHeader, testJWT), code: codes.Unauthenticated},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				accountID, _ := AccountIDFromContext(ctx)
+				assert.Equal(t, tc.accountID, accountID)
+				return nil, nil
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: testFullMethod}, handler)
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
+
+func TestJWTAccountIDExtractor(t *testing.T) {
+	ctx := contextWithToken(makeToken(jwt.MapClaims{"org_id": testAccountID}, t), DefaultTokenType)
+	accountID, err := JWTAccountIDExtractor(nil, WithAccountIDClaimPaths("org_id")).ExtractAccountID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, testAccountID, accountID)
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+var (
+	// ErrNoTLSIdentity is returned by IdentityFromTLS when the connection is
+	// not authenticated by a verified client certificate
+	ErrNoTLSIdentity = errors.New("unable to get a verified client certificate from the connection")
+	// ErrMissingTLSIdentity is returned by IdentityFromTLS when the client
+	// certificate has none of the identity fields
+	ErrMissingTLSIdentity = errors.New("unable to find the identity in the client certificate")
+)
+
+// TLSIdentityField is a field of the client certificate holding the identity
+// of the caller
+type TLSIdentityField int
+
+const (
+	// URISAN is the first URI subject alternative name, e.g. a SPIFFE id
+	URISAN TLSIdentityField = iota
+	// DNSSAN is the first DNS subject alternative name
+	DNSSAN
+	// EmailSAN is the first email subject alternative name
+	EmailSAN
+	// CommonName is the common name of the subject
+	CommonName
+)
+
+var defaultTLSIdentityFields = []TLSIdentityField{URISAN, DNSSAN, CommonName}
+
+// IdentityFromTLS returns the identity of the caller read from the first of
+// the given fields of its verified client certificate, by default the URI
+// SAN, the DNS SAN and the common name. It returns ErrNoTLSIdentity when the
+// connection is not mutual TLS.
+func IdentityFromTLS(ctx context.Context, fields ...TLSIdentityField) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", ErrNoTLSIdentity
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	// the chains are only verified when the server requires client certificates
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", ErrNoTLSIdentity
+	}
+	if len(fields) == 0 {
+		fields = defaultTLSIdentityFields
+	}
+	cert := info.State.VerifiedChains[0][0]
+	for _, field := range fields {
+		if id := identityField(cert, field); id != "" {
+			return id, nil
+		}
+	}
+	return "", ErrMissingTLSIdentity
+}
+
+func identityField(cert *x509.Certificate, field TLSIdentityField) string {
+	switch field {
+	case URISAN:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case DNSSAN:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case EmailSAN:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	case CommonName:
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+type identityKeyType struct{}
+
+var identityKey = identityKeyType{}
+
+// IdentityFromContext returns the TLS identity stored in the context by the
+// tenancy interceptors, see WithTLSIdentity
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey).(string)
+	return id, ok
+}
+
+// NewContextWithIdentity returns a context holding the identity, as read by
+// IdentityFromContext
+func NewContextWithIdentity(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
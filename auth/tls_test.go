@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func contextWithCertificate(cert *x509.Certificate) context.Context {
+	state := tls.ConnectionState{}
+	if cert != nil {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestIdentityFromTLS(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://cluster.local/ns/default/sa/worker")
+	cert := &x509.Certificate{
+		URIs:           []*url.URL{spiffeID},
+		DNSNames:       []string{"worker.default.svc"},
+		EmailAddresses: []string{"worker@example.com"},
+		Subject:        pkix.Name{CommonName: "worker"},
+	}
+
+	for name, tc := range map[string]struct {
+		ctx    context.Context
+		fields []TLSIdentityField
+		id     string
+		err    error
+	}{
+		"default fields": {ctx: contextWithCertificate(cert), id: spiffeID.String()},
+		"dns san":        {ctx: contextWithCertificate(cert), fields: []TLSIdentityField{DNSSAN}, id: "worker.default.svc"},
+		"email san":      {ctx: contextWithCertificate(cert), fields: []TLSIdentityField{EmailSAN}, id: "worker@example.com"},
+		"fallback":       {ctx: contextWithCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "worker"}}), id: "worker"},
+		"missing field":  {ctx: contextWithCertificate(&x509.Certificate{}), fields: []TLSIdentityField{URISAN}, err: ErrMissingTLSIdentity},
+		"unverified":     {ctx: contextWithCertificate(nil), err: ErrNoTLSIdentity},
+		"no peer":        {ctx: context.Background(), err: ErrNoTLSIdentity},
+		"insecure peer":  {ctx: peer.NewContext(context.Background(), &peer.Peer{}), err: ErrNoTLSIdentity},
+	} {
+		t.Run(name, func(t *testing.T) {
+			id, err := IdentityFromTLS(tc.ctx, tc.fields...)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.id, id)
+		})
+	}
+}
+
+func TestTenancyInterceptor_TLSIdentity(t *testing.T) {
+	interceptor := TenancyInterceptor(nil, WithTLSIdentity(CommonName))
+	certCtx := contextWithCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "worker"}})
+
+	for name, tc := range map[string]struct {
+		ctx       context.Context
+		accountID string
+		identity  string
+		code      codes.Code
+	}{
+		"tls identity":    {ctx: certCtx, identity: "worker"},
+		"token wins":      {ctx: contextWithToken(makeToken(jwt.MapClaims{MultiTenancyField: testAccountID}, t), DefaultTokenType), accountID: testAccountID},
+		"unauthenticated": {ctx: contextWithCertificate(nil), code: codes.Unauthenticated},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				accountID, _ := AccountIDFromContext(ctx)
+				assert.Equal(t, tc.accountID, accountID)
+				identity, _ := IdentityFromContext(ctx)
+				assert.Equal(t, tc.identity, identity)
+				return nil, nil
+			}
+			_, err := interceptor(tc.ctx, nil, &grpc.UnaryServerInfo{FullMethod: testFullMethod}, handler)
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
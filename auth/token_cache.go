@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+type tokenCacheKeyType struct{}
+
+var tokenCacheKey = tokenCacheKeyType{}
+
+// tokenCache holds the last token parsed from a request context
+type tokenCache struct {
+	mu       sync.Mutex
+	raw      string
+	token    jwt.Token
+	verified bool
+	// key is the one returned by the keyfunc that verified the token
+	key interface{}
+}
+
+// WithTokenCache returns a context in which the token parsed by the functions
+// of this package, e.g. GetAccountID, is cached, so that the middlewares down
+// the chain do not parse and verify it again. The cache is keyed on the raw
+// token, a different token in the metadata is parsed again. A token parsed
+// without keyfunc is not reused by the calls given one, and a token verified
+// with a key is verified again by the calls whose keyfunc returns another.
+func WithTokenCache(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(tokenCacheKey).(*tokenCache); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, tokenCacheKey, &tokenCache{})
+}
+
+func tokenCacheFromContext(ctx context.Context) *tokenCache {
+	c, _ := ctx.Value(tokenCacheKey).(*tokenCache)
+	return c
+}
+
+// get returns the cached token, which must have been verified with the key
+// returned by the keyfunc unless it is nil
+func (c *tokenCache) get(raw string, keyfunc jwt.Keyfunc) (jwt.Token, bool) {
+	c.mu.Lock()
+	token, verified, key := c.token, c.verified, c.key
+	hit := c.raw == raw
+	c.mu.Unlock()
+	if !hit {
+		return jwt.Token{}, false
+	}
+	if keyfunc == nil {
+		return token, true
+	}
+	if !verified {
+		return jwt.Token{}, false
+	}
+	// the keyfunc is called outside the lock as it may fetch the key
+	k, err := keyfunc(&token)
+	if err != nil || !reflect.DeepEqual(k, key) {
+		return jwt.Token{}, false
+	}
+	return token, true
+}
+
+func (c *tokenCache) set(raw string, token jwt.Token, verified bool, key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.raw, c.token, c.verified, c.key = raw, token, verified, key
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithTokenCache(t *testing.T) {
+	method := newCountingMethod()
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		return []byte(TestSecret), nil
+	}
+
+	token := makeTokenWithMethod(method, jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+	ctx := WithTokenCache(contextWithToken(token, DefaultTokenType))
+	if WithTokenCache(ctx) != ctx {
+		t.Errorf("Expected the existing cache to be kept")
+	}
+
+	for i := 0; i < 3; i++ {
+		accountID, err := GetAccountID(ctx, keyfunc)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if accountID != "id-abc-123" {
+			t.Errorf("Invalid AccountID: %v - expected %v", accountID, "id-abc-123")
+		}
+	}
+	if method.verified != 1 {
+		t.Errorf("Invalid number of verifications: %d - expected 1", method.verified)
+	}
+
+	// a changed token invalidates the cache
+	other := makeTokenWithMethod(method, jwt.MapClaims{MultiTenancyField: "id-def-456"}, t)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", DefaultTokenType+" "+other))
+	accountID, err := GetAccountID(ctx, keyfunc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if accountID != "id-def-456" {
+		t.Errorf("Invalid AccountID: %v - expected %v", accountID, "id-def-456")
+	}
+	if method.verified != 2 {
+		t.Errorf("Invalid number of verifications: %d - expected 2", method.verified)
+	}
+}
+
+func TestWithTokenCache_Unverified(t *testing.T) {
+	method := newCountingMethod()
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		return []byte(TestSecret), nil
+	}
+
+	token := makeTokenWithMethod(method, jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+	ctx := WithTokenCache(contextWithToken(token, DefaultTokenType))
+
+	// a token parsed without keyfunc is verified when a keyfunc is given
+	if _, err := GetAccountID(ctx, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := GetAccountID(ctx, keyfunc); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := GetAccountID(ctx, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if method.verified != 1 {
+		t.Errorf("Invalid number of verifications: %d - expected 1", method.verified)
+	}
+}
+
+func TestWithTokenCache_Disabled(t *testing.T) {
+	method := newCountingMethod()
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		return []byte(TestSecret), nil
+	}
+
+	ctx := contextWithToken(makeTokenWithMethod(method, jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t), DefaultTokenType)
+	GetAccountID(ctx, keyfunc)
+	GetAccountID(ctx, keyfunc)
+	if method.verified != 2 {
+		t.Errorf("Invalid number of verifications: %d - expected 2", method.verified)
+	}
+}
+
+func TestWithTokenCache_OtherKeyfunc(t *testing.T) {
+	method := newCountingMethod()
+	keyfunc := func(secret string) jwt.Keyfunc {
+		return func(token *jwt.Token) (interface{}, error) {
+			return []byte(secret), nil
+		}
+	}
+
+	token := makeTokenWithMethod(method, jwt.MapClaims{MultiTenancyField: "id-abc-123"}, t)
+	ctx := WithTokenCache(contextWithToken(token, DefaultTokenType))
+	if _, err := GetAccountID(ctx, keyfunc(TestSecret)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// a keyfunc returning another key verifies the token again
+	if _, err := GetAccountID(ctx, keyfunc("other-secret")); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrMalformedToken)
+	}
+	if method.verified != 2 {
+		t.Errorf("Invalid number of verifications: %d - expected 2", method.verified)
+	}
+
+	// one returning the key it was verified with reuses the cached token, the
+	// failed verification did not replace it
+	if _, err := GetAccountID(ctx, keyfunc(TestSecret)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := GetAccountID(ctx, keyfunc(TestSecret)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if method.verified != 2 {
+		t.Errorf("Invalid number of verifications: %d - expected 2", method.verified)
+	}
+}
+
+// countingMethod is the HS256 signing method counting the verifications of
+// the signatures
+type countingMethod struct {
+	*jwt.SigningMethodHMAC
+	verified int
+}
+
+func newCountingMethod() *countingMethod {
+	m := &countingMethod{SigningMethodHMAC: jwt.SigningMethodHS256}
+	jwt.RegisterSigningMethod(m.Alg(), func() jwt.SigningMethod { return m })
+	return m
+}
+
+func (m *countingMethod) Alg() string {
+	return "HS256-counted"
+}
+
+func (m *countingMethod) Verify(signingString, signature string, key interface{}) error {
+	m.verified++
+	return m.SigningMethodHMAC.Verify(signingString, signature, key)
+}
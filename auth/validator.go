@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	// ErrInvalidIssuer is returned by Validator.Validate when the token iss
+	// claim is not one of the allowed issuers
+	ErrInvalidIssuer = errors.New("token issuer is not allowed")
+	// ErrInvalidAudience is returned by Validator.Validate when the token aud
+	// claim misses one of the required audiences
+	ErrInvalidAudience = errors.New("token audience is not valid")
+	// ErrTokenExpired is returned by Validator.Validate when the token exp
+	// claim is in the past
+	ErrTokenExpired = errors.New("token is expired")
+	// ErrTokenNotValidYet is returned by Validator.Validate when the token nbf
+	// claim is in the future
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+)
+
+// Claims is the typed view of the common claims of a token, as returned by
+// ParseClaims or a Validator. The claims missing from the token are left
+// empty
+type Claims struct {
+	// Raw holds all the claims as decoded from the JSON payload, including the
+	// unknown ones
+	Raw map[string]interface{}
+	// AccountID is read from the claims of GetAccountID, see
+	// WithAccountIDClaimPaths
+	AccountID string
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	// Roles is read from the RolesClaim
+	Roles []string
+}
+
+// ValidatorOption is a type of function that alters a Validator in the
+// instantiation of NewValidator
+type ValidatorOption func(*Validator)
+
+// WithIssuers sets the issuers the tokens are accepted from, any issuer is
+// accepted when none is set
+func WithIssuers(issuers ...string) ValidatorOption {
+	return func(v *Validator) {
+		v.issuers = append(v.issuers, issuers...)
+	}
+}
+
+// WithAudiences sets the audiences the tokens must all be issued for
+func WithAudiences(audiences ...string) ValidatorOption {
+	return func(v *Validator) {
+		v.audiences = append(v.audiences, audiences...)
+	}
+}
+
+// WithClockSkew tolerates a clock drift of d between the issuer and the
+// service when checking the exp and nbf claims. A WithLeeway option given by
+// WithValidatorOptions has the same effect, the larger of both is used.
+func WithClockSkew(d time.Duration) ValidatorOption {
+	return func(v *Validator) {
+		v.clockSkew = d
+	}
+}
+
+// WithValidatorOptions sets the options used to parse the tokens, e.g.
+// WithSigningMethods
+func WithValidatorOptions(opts ...Option) ValidatorOption {
+	return func(v *Validator) {
+		v.opts = append(v.opts, opts...)
+	}
+}
+
+// Validator validates the issuer, audience and validity period of the bearer
+// token of a context
+type Validator struct {
+	issuers   []string
+	audiences []string
+	clockSkew time.Duration
+	opts      []Option
+	now       func() time.Time
+}
+
+// NewValidator returns a Validator configured with the given options
+func NewValidator(opts ...ValidatorOption) *Validator {
+	v := &Validator{now: time.Now}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Validate gets the bearer token from a context, like GetAccountID, and
+// validates it. The returned error wraps ErrInvalidToken, ErrInvalidIssuer,
+// ErrInvalidAudience, ErrTokenExpired or ErrTokenNotValidYet.
+func (v *Validator) Validate(ctx context.Context, keyfunc jwt.Keyfunc) (*Claims, error) {
+	o := newOptions(v.opts)
+	// the validity period is checked below with the clock skew
+	o.skipClaimsValidation = true
+	claims, err := getClaims(ctx, DefaultTokenType, keyfunc, o)
+	if err != nil {
+		return nil, err
+	}
+
+	skew := v.clockSkew
+	if o.leeway > skew {
+		skew = o.leeway
+	}
+	now := v.now()
+	if !claims.VerifyExpiresAt(now.Add(-skew).Unix(), false) {
+		return nil, ErrTokenExpired
+	}
+	if !claims.VerifyNotBefore(now.Add(skew).Unix(), false) {
+		return nil, ErrTokenNotValidYet
+	}
+
+	issuer, _ := claims["iss"].(string)
+	if len(v.issuers) > 0 && !contains(v.issuers, issuer) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidIssuer, issuer)
+	}
+	for _, aud := range v.audiences {
+		if !claims.VerifyAudience(aud, true) {
+			return nil, fmt.Errorf("%w: missing %q", ErrInvalidAudience, aud)
+		}
+	}
+
+	return newClaims(claims, o)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
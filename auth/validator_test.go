@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	for name, tc := range map[string]struct {
+		claims   jwt.MapClaims
+		opts     []ValidatorOption
+		expected error
+	}{
+		"valid": {
+			claims: jwt.MapClaims{"iss": "issuer-a", "aud": []string{"svc-a", "svc-b"}, "sub": "user-1", "exp": now.Add(time.Minute).Unix()},
+			opts:   []ValidatorOption{WithIssuers("issuer-a", "issuer-b"), WithAudiences("svc-a")},
+		},
+		"invalid issuer": {
+			claims:   jwt.MapClaims{"iss": "issuer-c", "aud": "svc-a"},
+			opts:     []ValidatorOption{WithIssuers("issuer-a", "issuer-b")},
+			expected: ErrInvalidIssuer,
+		},
+		"missing issuer": {
+			claims:   jwt.MapClaims{"aud": "svc-a"},
+			opts:     []ValidatorOption{WithIssuers("issuer-a")},
+			expected: ErrInvalidIssuer,
+		},
+		"invalid audience": {
+			claims:   jwt.MapClaims{"iss": "issuer-a", "aud": "svc-b"},
+			opts:     []ValidatorOption{WithAudiences("svc-a")},
+			expected: ErrInvalidAudience,
+		},
+		"missing audience": {
+			claims:   jwt.MapClaims{"iss": "issuer-a"},
+			opts:     []ValidatorOption{WithAudiences("svc-a")},
+			expected: ErrInvalidAudience,
+		},
+		"expired": {
+			claims:   jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()},
+			expected: ErrTokenExpired,
+		},
+		"expired within skew": {
+			claims: jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()},
+			opts:   []ValidatorOption{WithClockSkew(2 * time.Minute)},
+		},
+		"not valid yet": {
+			claims:   jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()},
+			expected: ErrTokenNotValidYet,
+		},
+		"not valid yet within skew": {
+			claims: jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()},
+			opts:   []ValidatorOption{WithClockSkew(2 * time.Minute)},
+		},
+	} {
+		v := NewValidator(tc.opts...)
+		v.now = func() time.Time { return now }
+
+		ctx := contextWithToken(makeToken(tc.claims, t), DefaultTokenType)
+		claims, err := v.Validate(ctx, keyfunc)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("Invalid error value (%s): %v - expected %v", name, err, tc.expected)
+		}
+		if tc.expected == nil && claims == nil {
+			t.Errorf("Expected claims (%s)", name)
+		}
+	}
+}
+
+func TestValidator_Claims(t *testing.T) {
+	ctx := contextWithToken(makeToken(jwt.MapClaims{"iss": "issuer-a", "aud": "svc-a", "sub": "user-1", MultiTenancyField: "id-abc-123"}, t), DefaultTokenType)
+
+	claims, err := NewValidator().Validate(ctx, HMACKeyfunc([]byte(TestSecret)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if claims.Issuer != "issuer-a" || claims.Subject != "user-1" || !reflect.DeepEqual(claims.Audience, []string{"svc-a"}) {
+		t.Errorf("Invalid claims: %+v", claims)
+	}
+	if claims.Raw[MultiTenancyField] != "id-abc-123" {
+		t.Errorf("Invalid AccountID: %v - expected %v", claims.Raw[MultiTenancyField], "id-abc-123")
+	}
+}
+
+func TestValidator_InvalidToken(t *testing.T) {
+	ctx := contextWithToken(makeToken(jwt.MapClaims{}, t), DefaultTokenType)
+	if _, err := NewValidator().Validate(ctx, HMACKeyfunc([]byte("other-secret"))); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrInvalidToken)
+	}
+}
+
+func TestWithTokenCache_ExpiredToken(t *testing.T) {
+	// a token cached by the validator is still checked for expiry
+	ctx := WithTokenCache(contextWithToken(makeToken(jwt.MapClaims{MultiTenancyField: "id-abc-123", "exp": time.Now().Add(-time.Minute).Unix()}, t), DefaultTokenType))
+	keyfunc := HMACKeyfunc([]byte(TestSecret))
+
+	if _, err := NewValidator(WithClockSkew(time.Hour)).Validate(ctx, keyfunc); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := GetAccountID(ctx, keyfunc); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("Invalid error value: %v - expected %v", err, ErrMalformedToken)
+	}
+}
@@ -0,0 +1,80 @@
+package errors
+
+import (
+	"fmt"
+	"sort"
+
+	rpcdetails "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AppError is an application error carrying a gRPC code, a message and the
+// field violations of the request. It implements GRPCStatus, so that handlers
+// can return it directly: the violations are sent as a errdetails.BadRequest
+// detail of the status and rendered by the gateway error handlers.
+type AppError struct {
+	code       codes.Code
+	message    string
+	violations []*rpcdetails.BadRequest_FieldViolation
+}
+
+// NewAppError function returns a new application error with the code and
+// the message.
+func NewAppError(code codes.Code, format string, args ...interface{}) *AppError {
+	return &AppError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// Error function returns the message of the application error.
+func (e *AppError) Error() string { return e.message }
+
+// Code function returns the gRPC code of the application error.
+func (e *AppError) Code() codes.Code { return e.code }
+
+// FieldViolations function returns the field violations of the application
+// error.
+func (e *AppError) FieldViolations() []*rpcdetails.BadRequest_FieldViolation {
+	return e.violations
+}
+
+// WithFieldViolation function appends a violation of the field to the
+// application error.
+func (e *AppError) WithFieldViolation(field string, format string, args ...interface{}) *AppError {
+	e.violations = append(e.violations, &rpcdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+	return e
+}
+
+// WithFieldViolations function appends the violations of several fields to
+// the application error in the order of the field names, empty descriptions
+// are skipped.
+func (e *AppError) WithFieldViolations(fields map[string][]string) *AppError {
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	for _, field := range names {
+		for _, desc := range fields[field] {
+			if field != "" && desc != "" {
+				e.WithFieldViolation(field, "%s", desc)
+			}
+		}
+	}
+	return e
+}
+
+// GRPCStatus function returns the application error as GRPC status, with a
+// errdetails.BadRequest detail holding the field violations if any.
+func (e *AppError) GRPCStatus() *status.Status {
+	st := status.New(e.code, e.message)
+	if len(e.violations) == 0 {
+		return st
+	}
+	if s, err := st.WithDetails(&rpcdetails.BadRequest{FieldViolations: e.violations}); err == nil {
+		return s
+	}
+	return st
+}
@@ -0,0 +1,54 @@
+package errors
+
+import (
+	"testing"
+
+	rpcdetails "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAppError(t *testing.T) {
+	err := NewAppError(codes.InvalidArgument, "invalid contact %d", 1).
+		WithFieldViolation("email", "must not be empty").
+		WithFieldViolations(map[string][]string{"phone": {"invalid format", ""}, "": {"skipped"}, "age": {"must be positive"}})
+
+	if err.Error() != "invalid contact 1" {
+		t.Errorf(UnexpectedValue, "message", "invalid contact 1", err.Error())
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("Expected a gRPC status error")
+	}
+	if st.Code() != codes.InvalidArgument {
+		t.Errorf(UnexpectedValue, "code", codes.InvalidArgument, st.Code())
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf(UnexpectedValue, "details length", 1, len(st.Details()))
+	}
+	br, ok := st.Details()[0].(*rpcdetails.BadRequest)
+	if !ok {
+		t.Fatalf(UnexpectedValue, "detail type", "*errdetails.BadRequest", st.Details()[0])
+	}
+
+	expected := [][2]string{{"email", "must not be empty"}, {"age", "must be positive"}, {"phone", "invalid format"}}
+	if len(br.GetFieldViolations()) != len(expected) {
+		t.Fatalf(UnexpectedValue, "violations", expected, br.GetFieldViolations())
+	}
+	for i, v := range br.GetFieldViolations() {
+		if v.GetField() != expected[i][0] || v.GetDescription() != expected[i][1] {
+			t.Errorf(UnexpectedValue, "violation", expected[i], v)
+		}
+	}
+}
+
+func TestAppErrorWithoutViolations(t *testing.T) {
+	st := NewAppError(codes.NotFound, "contact not found").GRPCStatus()
+	if st.Code() != codes.NotFound || st.Message() != "contact not found" {
+		t.Errorf(UnexpectedValue, "status", "NotFound: contact not found", st)
+	}
+	if len(st.Details()) != 0 {
+		t.Errorf(UnexpectedValue, "details", "none", st.Details())
+	}
+}
@@ -0,0 +1,101 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// BodyLimitOption is a type of function that alters the configuration of the
+// handler returned by MaxBodyBytesHandler
+type BodyLimitOption func(*bodyLimiter)
+
+// WithRouteMaxBodyBytes overrides the limit of the requests whose path starts
+// with the prefix, the longest matching prefix wins. A limit that is not
+// positive does not limit the body of the route.
+func WithRouteMaxBodyBytes(prefix string, limit int64) BodyLimitOption {
+	return func(l *bodyLimiter) {
+		l.routes = append(l.routes, routeLimit{prefix: prefix, limit: limit})
+	}
+}
+
+type routeLimit struct {
+	prefix string
+	limit  int64
+}
+
+type bodyLimiter struct {
+	limit  int64
+	routes []routeLimit
+}
+
+// MaxBodyBytesHandler returns a handler calling next with the request body
+// limited to limit bytes by http.MaxBytesReader, or to the limit of the route
+// set by WithRouteMaxBodyBytes. A limit that is not positive does not limit
+// the body.
+//
+// The body is read by the ServeMux, which fails the call when the limit is
+// exceeded. The error handler returned by NewErrorHandler then writes a 413
+// Request Entity Too Large, the other handlers write the 400 Bad Request of
+// the decoding error.
+func MaxBodyBytesHandler(next http.Handler, limit int64, opts ...BodyLimitOption) http.Handler {
+	l := &bodyLimiter{limit: limit}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if limit := l.routeLimit(req.URL.Path); limit > 0 && req.Body != nil && req.Body != http.NoBody {
+			body := &countingBody{ReadCloser: req.Body}
+			req.Body = &limitedBody{ReadCloser: http.MaxBytesReader(rw, body, limit), body: body, limit: limit}
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func (l *bodyLimiter) routeLimit(path string) int64 {
+	limit, matched := l.limit, -1
+	for _, r := range l.routes {
+		if len(r.prefix) > matched && strings.HasPrefix(path, r.prefix) {
+			limit, matched = r.limit, len(r.prefix)
+		}
+	}
+	return limit
+}
+
+// countingBody counts the bytes read from the request body
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// limitedBody is the request body set by MaxBodyBytesHandler
+type limitedBody struct {
+	io.ReadCloser
+	body  *countingBody
+	limit int64
+}
+
+// exceeded reports whether more than limit bytes were sent, since the
+// MaxBytesReader reads one byte past the limit to fail
+func (b *limitedBody) exceeded() bool {
+	return b.body.n > b.limit
+}
+
+// bodyLimitExceeded returns the limit of the request body when it was
+// exceeded
+func bodyLimitExceeded(req *http.Request) (int64, bool) {
+	if req == nil {
+		return 0, false
+	}
+	b, ok := req.Body.(*limitedBody)
+	if !ok || !b.exceeded() {
+		return 0, false
+	}
+	return b.limit, true
+}
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMaxBodyBytesHandler(t *testing.T) {
+	// the handler decodes the body as the generated ones
+	mux := NewServeMux()
+	decode := func(rw http.ResponseWriter, req *http.Request, _ map[string]string) {
+		if _, err := ioutil.ReadAll(req.Body); err != nil {
+			_, outbound := runtime.MarshalerForRequest(mux, req)
+			runtime.HTTPError(req.Context(), mux, outbound, rw, req, status.Errorf(codes.InvalidArgument, "%v", err))
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}
+	mux.HandlePath(http.MethodPost, "/v1/users", decode)
+	mux.HandlePath(http.MethodPost, "/v1/files", decode)
+	h := MaxBodyBytesHandler(mux, 16, WithRouteMaxBodyBytes("/v1/files", 32))
+
+	for name, tc := range map[string]struct {
+		path  string
+		size  int
+		code  int
+		limit int64
+	}{
+		"at the limit":         {path: "/v1/users", size: 16, code: http.StatusOK},
+		"over the limit":       {path: "/v1/users", size: 17, code: http.StatusRequestEntityTooLarge, limit: 16},
+		"route limit":          {path: "/v1/files", size: 32, code: http.StatusOK},
+		"over the route limit": {path: "/v1/files", size: 33, code: http.StatusRequestEntityTooLarge, limit: 32},
+	} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(strings.Repeat("x", tc.size)))
+		req.Header.Set("X-Request-ID", "abc-123")
+		h.ServeHTTP(rw, req)
+
+		if rw.Code != tc.code {
+			t.Errorf("%s: invalid http status code: %d - expected: %d", name, rw.Code, tc.code)
+		}
+		if tc.code == http.StatusOK {
+			continue
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rw.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", name, err)
+		}
+		if resp.Error.Code != "INVALID_ARGUMENT" || resp.Error.RequestID != "abc-123" || !strings.Contains(resp.Error.Message, "request body too large") {
+			t.Errorf("%s: invalid error response: %+v", name, resp)
+		}
+	}
+}
+
+func TestMaxBodyBytesHandler_RouteLimit(t *testing.T) {
+	l := &bodyLimiter{limit: 16}
+	for _, opt := range []BodyLimitOption{
+		WithRouteMaxBodyBytes("/v1/files", 32),
+		WithRouteMaxBodyBytes("/v1/files/uploads", 0),
+	} {
+		opt(l)
+	}
+	for path, expected := range map[string]int64{
+		"/v1/users":           16,
+		"/v1/files/1":         32,
+		"/v1/files/uploads/1": 0,
+	} {
+		if limit := l.routeLimit(path); limit != expected {
+			t.Errorf("%s: invalid limit: %d - expected: %d", path, limit, expected)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package gateway
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressionThreshold is the default size from which the responses
+// are compressed by CompressionHandler
+const DefaultCompressionThreshold = 1024
+
+// defaultUncompressibleTypes are the content types that are already compressed
+var defaultUncompressibleTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+	"application/x-bzip2", "application/x-7z-compressed", "application/x-rar-compressed",
+}
+
+// CompressionOption is a type of function that alters the configuration of
+// the handler returned by CompressionHandler
+type CompressionOption func(*compression)
+
+// WithCompressionThreshold sets the size from which the responses are
+// compressed. Defaults to DefaultCompressionThreshold
+func WithCompressionThreshold(size int) CompressionOption {
+	return func(c *compression) {
+		c.threshold = size
+	}
+}
+
+// WithCompressionLevel sets the gzip and deflate compression level, e.g.
+// gzip.BestSpeed. Defaults to gzip.DefaultCompression
+func WithCompressionLevel(level int) CompressionOption {
+	return func(c *compression) {
+		c.level = level
+	}
+}
+
+// WithUncompressibleTypes adds content types, or prefixes of them such as
+// "image/", that are not compressed because they already are
+func WithUncompressibleTypes(types ...string) CompressionOption {
+	return func(c *compression) {
+		c.skipTypes = append(c.skipTypes, types...)
+	}
+}
+
+type compression struct {
+	threshold int
+	level     int
+	skipTypes []string
+}
+
+// CompressionHandler returns a handler compressing the responses of next with
+// gzip or deflate, as negotiated by the Accept-Encoding header of the request.
+// The first bytes of the response are buffered up to the threshold, smaller
+// responses are written as is, larger ones are compressed as they are
+// written. The responses that already have a Content-Encoding, or a content
+// type that is already compressed, e.g. images, are not compressed again.
+func CompressionHandler(next http.Handler, opts ...CompressionOption) http.Handler {
+	c := &compression{
+		threshold: DefaultCompressionThreshold,
+		level:     gzip.DefaultCompression,
+		skipTypes: append([]string{}, defaultUncompressibleTypes...),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		addVary(rw.Header(), "Accept-Encoding")
+		encoding := negotiateEncoding(req.Header.Values("Accept-Encoding"))
+		if encoding == "" || req.Method == http.MethodHead {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: rw, c: c, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, req)
+	})
+}
+
+// negotiateEncoding returns the accepted encoding of the highest quality,
+// gzip is preferred over deflate. The "*" coding applies only to the
+// encodings which are not listed
+func negotiateEncoding(accept []string) string {
+	listed := make(map[string]float64)
+	wildcard := -1.0
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			name, q := parseCoding(part)
+			switch name {
+			case "gzip", "deflate":
+				listed[name] = q
+			case "*":
+				wildcard = q
+			}
+		}
+	}
+	var encoding string
+	var best float64
+	for _, name := range []string{"gzip", "deflate"} {
+		q, ok := listed[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > best {
+			encoding, best = name, q
+		}
+	}
+	return encoding
+}
+
+func parseCoding(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, p := range params[1:] {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "q=") {
+			if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+				q = v
+			}
+		}
+	}
+	return name, q
+}
+
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
+
+// compressWriter buffers the response until it is either larger than the
+// threshold, flushed or complete, then writes it compressed or as is
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compression
+	encoding string
+
+	status  int
+	decided bool
+	buf     []byte
+	enc     io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		// informational responses are sent as is
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = code
+	if !bodyAllowed(code) {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < w.c.threshold {
+		return len(p), nil
+	}
+	if err := w.decide(w.compressible()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher, the response is compressed from the first
+// flush on, e.g. of a streamed response, regardless of the threshold
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(w.compressible())
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || !bodyAllowed(w.status) {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" && len(w.buf) > 0 {
+		// the content type must be sniffed before the body is compressed
+		ct = http.DetectContentType(w.buf)
+		h.Set("Content-Type", ct)
+	}
+	ct = strings.ToLower(ct)
+	for _, skip := range w.c.skipTypes {
+		if strings.HasPrefix(ct, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		var err error
+		if w.enc, err = w.newEncoder(); err != nil {
+			return err
+		}
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", w.encoding)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) newEncoder() (io.WriteCloser, error) {
+	if w.encoding == "deflate" {
+		return zlib.NewWriterLevel(w.ResponseWriter, w.c.level)
+	}
+	return gzip.NewWriterLevel(w.ResponseWriter, w.c.level)
+}
+
+func (w *compressWriter) close() error {
+	if !w.decided {
+		if w.status == 0 {
+			// nothing was written, net/http writes the default response
+			return nil
+		}
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.enc != nil {
+		return w.enc.Close()
+	}
+	return nil
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionHandler(t *testing.T) {
+	large := strings.Repeat(`{"id":"1","name":"contact"},`, 100)
+	for name, tc := range map[string]struct {
+		accept      string
+		contentType string
+		encoding    string
+		body        string
+		expected    string
+	}{
+		"gzip":               {accept: "gzip, deflate", contentType: "application/json", body: large, expected: "gzip"},
+		"deflate":            {accept: "deflate, gzip;q=0.5", contentType: "application/json", body: large, expected: "deflate"},
+		"wildcard":           {accept: "*", contentType: "application/json", body: large, expected: "gzip"},
+		"not accepted":       {accept: "br, gzip;q=0", contentType: "application/json", body: large},
+		"wildcard refused":   {accept: "gzip;q=0, *", contentType: "application/json", body: large, expected: "deflate"},
+		"wildcard none":      {accept: "gzip;q=0, deflate;q=0, *", contentType: "application/json", body: large},
+		"below threshold":    {accept: "gzip", contentType: "application/json", body: `{"id":"1"}`},
+		"compressed type":    {accept: "gzip", contentType: "image/png", body: large},
+		"already encoded":    {accept: "gzip", contentType: "application/json", encoding: "br", body: large},
+		"sniffed type":       {accept: "gzip", body: large, expected: "gzip"},
+		"sniffed compressed": {accept: "gzip", body: "\x1f\x8b\x08" + large},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := CompressionHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if tc.contentType != "" {
+					rw.Header().Set("Content-Type", tc.contentType)
+				}
+				if tc.encoding != "" {
+					rw.Header().Set("Content-Encoding", tc.encoding)
+				}
+				rw.WriteHeader(http.StatusOK)
+				// written in chunks, the threshold is crossed on the way
+				for i := 0; i < len(tc.body); i += 100 {
+					end := i + 100
+					if end > len(tc.body) {
+						end = len(tc.body)
+					}
+					rw.Write([]byte(tc.body[i:end]))
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/contacts", nil)
+			req.Header.Set("Accept-Encoding", tc.accept)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusOK)
+			}
+			if v := rw.Header().Get("Vary"); v != "Accept-Encoding" {
+				t.Errorf("invalid vary header: %q", v)
+			}
+			encoding := rw.Header().Get("Content-Encoding")
+			if tc.encoding != "" {
+				if encoding != tc.encoding {
+					t.Errorf("invalid content encoding: %q - expected: %q", encoding, tc.encoding)
+				}
+			} else if encoding != tc.expected {
+				t.Errorf("invalid content encoding: %q - expected: %q", encoding, tc.expected)
+			}
+			if body := decompress(t, tc.expected, rw.Body.Bytes()); body != tc.body {
+				t.Errorf("invalid body: %q - expected: %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestCompressionHandlerThreshold(t *testing.T) {
+	h := CompressionHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Header().Set("Content-Length", "5")
+		rw.Write([]byte("hello"))
+	}), WithCompressionThreshold(4))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if rw.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected the response to be compressed")
+	}
+	if rw.Header().Get("Content-Length") != "" {
+		t.Errorf("unexpected content length: %s", rw.Header().Get("Content-Length"))
+	}
+	if body := decompress(t, "gzip", rw.Body.Bytes()); body != "hello" {
+		t.Errorf("invalid body: %q", body)
+	}
+}
+
+func TestCompressionHandlerStream(t *testing.T) {
+	h := CompressionHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"result":1}`))
+		rw.(http.Flusher).Flush()
+		rw.Write([]byte(`{"result":2}`))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if !rw.Flushed {
+		t.Errorf("expected the response to be flushed")
+	}
+	if rw.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected the response to be compressed")
+	}
+	if body := decompress(t, "gzip", rw.Body.Bytes()); body != `{"result":1}{"result":2}` {
+		t.Errorf("invalid body: %q", body)
+	}
+}
+
+func TestCompressionHandlerNoContent(t *testing.T) {
+	h := CompressionHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(http.MethodDelete, "/contacts/1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNoContent {
+		t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusNoContent)
+	}
+	if rw.Header().Get("Content-Encoding") != "" || rw.Body.Len() != 0 {
+		t.Errorf("unexpected body: %q", rw.Body.String())
+	}
+}
+
+func decompress(t *testing.T, encoding string, data []byte) string {
+	var err error
+	switch encoding {
+	case "gzip":
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			data, err = ioutil.ReadAll(r)
+		}
+	case "deflate":
+		r, zerr := zlib.NewReader(bytes.NewReader(data))
+		if err = zerr; err == nil {
+			data, err = ioutil.ReadAll(r)
+		}
+	}
+	if err != nil {
+		t.Fatalf("failed to decompress the body: %v", err)
+	}
+	return string(data)
+}
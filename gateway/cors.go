@@ -0,0 +1,222 @@
+package gateway
+
+import (
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMethods returns the methods of the cross-origin requests allowed
+// by default by CORSHandler
+func DefaultCORSMethods() []string {
+	return []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	}
+}
+
+// corsRequestHeaders are the request headers read by the gateway besides the
+// ones of the header matchers
+var corsRequestHeaders = []string{
+	"Accept",
+	"Authorization",
+	"Content-Type",
+	"If-None-Match",
+	RequestTimeoutHeader,
+}
+
+// corsResponseHeaders are the response headers set by the gateway besides the
+// ones of the header matchers
+var corsResponseHeaders = []string{
+	"ETag",
+	"Retry-After",
+}
+
+// CORSOption is a type of function that alters the configuration of the
+// handler returned by CORSHandler
+type CORSOption func(*corsConfig)
+
+// WithCORSOrigins sets the allowed origins, e.g. "https://app.example.com",
+// "*" allows any origin. Defaults to "*"
+func WithCORSOrigins(origins ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.origins = origins
+	}
+}
+
+// WithCORSMethods sets the allowed methods. Defaults to DefaultCORSMethods
+func WithCORSMethods(methods ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.methods = methods
+	}
+}
+
+// WithCORSHeaderOptions sets the options of the header matchers, e.g.
+// WithAllowedHeaders, so that the headers they pass through are allowed and
+// exposed
+func WithCORSHeaderOptions(opts ...HeaderMatcherOption) CORSOption {
+	return func(c *corsConfig) {
+		c.headerOpts = append(c.headerOpts, opts...)
+	}
+}
+
+// WithCORSCredentials allows the requests with credentials, the allowed origin
+// is then echoed. The origins must be listed with WithCORSOrigins, any site
+// could make credentialed reads otherwise, CORSHandler panics when "*" is
+// allowed
+func WithCORSCredentials() CORSOption {
+	return func(c *corsConfig) {
+		c.credentials = true
+	}
+}
+
+// WithCORSMaxAge sets the duration the browsers may cache the preflight
+// responses for
+func WithCORSMaxAge(d time.Duration) CORSOption {
+	return func(c *corsConfig) {
+		c.maxAge = d
+	}
+}
+
+type corsConfig struct {
+	origins     []string
+	methods     []string
+	headerOpts  []HeaderMatcherOption
+	credentials bool
+	maxAge      time.Duration
+
+	anyOrigin      bool
+	allowedOrigins map[string]struct{}
+	allowedMethods map[string]struct{}
+	allowedHeaders map[string]string
+	exposed        string
+}
+
+// CORSHandler returns a handler serving the CORS requests of the browsers
+// before calling next. The allowed request headers are the ones passed through
+// by DefaultIncomingHeaderMatcher, as the request id headers,
+// together with the Authorization, Content-Type and the other headers read by
+// the gateway. The headers returned by DefaultOutgoingHeaderMatcher are
+// exposed. WithCORSHeaderOptions takes the options of the header matchers to
+// keep them in sync.
+//
+// The preflight OPTIONS requests are answered without calling next, the
+// requested headers that are allowed are echoed in
+// Access-Control-Allow-Headers.
+//
+// It panics when the credentials are allowed for any origin, see
+// WithCORSCredentials.
+func CORSHandler(next http.Handler, opts ...CORSOption) http.Handler {
+	c := &corsConfig{
+		origins: []string{"*"},
+		methods: DefaultCORSMethods(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.init()
+	if c.anyOrigin && c.credentials {
+		panic("gateway: the CORS credentials cannot be allowed for any origin")
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// the responses to the other origins differ
+		h := rw.Header()
+		h.Add("Vary", "Origin")
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			c.preflight(h, origin, req.Header)
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if c.allowOrigin(h, origin) && c.exposed != "" {
+			h.Set("Access-Control-Expose-Headers", c.exposed)
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func (c *corsConfig) init() {
+	c.allowedOrigins = map[string]struct{}{}
+	for _, o := range c.origins {
+		if o == "*" {
+			c.anyOrigin = true
+		}
+		c.allowedOrigins[strings.ToLower(o)] = struct{}{}
+	}
+	c.allowedMethods = map[string]struct{}{}
+	for _, m := range c.methods {
+		c.allowedMethods[strings.ToUpper(m)] = struct{}{}
+	}
+
+	allowed := allowedHeaders(c.headerOpts)
+	var exposed []string
+	for _, h := range outgoingAllowedHeaders(c.headerOpts) {
+		exposed = append(exposed, textproto.CanonicalMIMEHeaderKey(h))
+	}
+	sort.Strings(exposed)
+	c.exposed = strings.Join(append(exposed, corsResponseHeaders...), ", ")
+
+	WithAllowedHeaders(corsRequestHeaders...)(allowed)
+	c.allowedHeaders = allowed
+}
+
+// allowOrigin sets the Access-Control-Allow-Origin header of an allowed origin
+func (c *corsConfig) allowOrigin(h http.Header, origin string) bool {
+	if _, ok := c.allowedOrigins[strings.ToLower(origin)]; !ok && !c.anyOrigin {
+		return false
+	}
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// preflight sets the headers of the response to a preflight request, none
+// when the origin or the method is not allowed
+func (c *corsConfig) preflight(h http.Header, origin string, req http.Header) {
+	method := strings.ToUpper(req.Get("Access-Control-Request-Method"))
+	if _, ok := c.allowedMethods[method]; !ok {
+		return
+	}
+	if !c.allowOrigin(h, origin) {
+		return
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+
+	var headers []string
+	for _, v := range req.Values("Access-Control-Request-Headers") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := c.allowedHeaders[strings.ToLower(name)]; ok {
+				headers = append(headers, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+	if len(headers) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if c.maxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.maxAge/time.Second)))
+	}
+}
@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSHandler_Preflight(t *testing.T) {
+	var called bool
+	h := CORSHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		called = true
+	}), WithCORSOrigins("https://app.example.com"), WithCORSMaxAge(time.Minute),
+		WithCORSHeaderOptions(WithAllowedHeaders("X-Tenant-Region")))
+
+	for name, tc := range map[string]struct {
+		origin   string
+		method   string
+		headers  string
+		allowed  bool
+		expected string
+	}{
+		"allowed":            {origin: "https://app.example.com", method: http.MethodPost, headers: "content-type, x-request-id, authorization", allowed: true, expected: "Content-Type, X-Request-Id, Authorization"},
+		"account header":     {origin: "https://app.example.com", method: http.MethodGet, headers: "X-Account-ID", allowed: true},
+		"extended headers":   {origin: "https://app.example.com", method: http.MethodGet, headers: "x-tenant-region", allowed: true, expected: "X-Tenant-Region"},
+		"unknown header":     {origin: "https://app.example.com", method: http.MethodGet, headers: "x-request-id, x-unknown", allowed: true, expected: "X-Request-Id"},
+		"no header":          {origin: "https://app.example.com", method: http.MethodGet, allowed: true},
+		"origin not allowed": {origin: "https://evil.example.com", method: http.MethodGet, headers: "x-request-id"},
+		"method not allowed": {origin: "https://app.example.com", method: "TRACE"},
+	} {
+		called = false
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodOptions, "/v1/users", nil)
+		req.Header.Set("Origin", tc.origin)
+		req.Header.Set("Access-Control-Request-Method", tc.method)
+		if tc.headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", tc.headers)
+		}
+		h.ServeHTTP(rw, req)
+
+		if called {
+			t.Errorf("%s: the preflight request must not be forwarded", name)
+		}
+		if rw.Code != http.StatusNoContent {
+			t.Errorf("%s: invalid http status code: %d - expected: %d", name, rw.Code, http.StatusNoContent)
+		}
+		if origin := rw.Header().Get("Access-Control-Allow-Origin"); (origin == tc.origin) != tc.allowed {
+			t.Errorf("%s: invalid allowed origin: %q", name, origin)
+		}
+		if headers := rw.Header().Get("Access-Control-Allow-Headers"); headers != tc.expected {
+			t.Errorf("%s: invalid allowed headers: %q - expected: %q", name, headers, tc.expected)
+		}
+		if !tc.allowed {
+			continue
+		}
+		if methods := rw.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(methods, tc.method) {
+			t.Errorf("%s: invalid allowed methods: %q", name, methods)
+		}
+		if maxAge := rw.Header().Get("Access-Control-Max-Age"); maxAge != "60" {
+			t.Errorf("%s: invalid max age: %q - expected: %q", name, maxAge, "60")
+		}
+	}
+}
+
+func TestCORSHandler_Request(t *testing.T) {
+	var called bool
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		called = true
+	})
+
+	for name, tc := range map[string]struct {
+		handler     http.Handler
+		origin      string
+		expected    string
+		credentials string
+	}{
+		"any origin":   {handler: CORSHandler(next), origin: "https://app.example.com", expected: "*"},
+		"credentials":  {handler: CORSHandler(next, WithCORSOrigins("https://app.example.com"), WithCORSCredentials()), origin: "https://app.example.com", expected: "https://app.example.com", credentials: "true"},
+		"not allowed":  {handler: CORSHandler(next, WithCORSOrigins("https://app.example.com")), origin: "https://evil.example.com"},
+		"same origin":  {handler: CORSHandler(next)},
+		"other origin": {handler: CORSHandler(next, WithCORSOrigins("https://app.example.com", "https://admin.example.com")), origin: "https://admin.example.com", expected: "https://admin.example.com"},
+	} {
+		called = false
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		tc.handler.ServeHTTP(rw, req)
+
+		if !called {
+			t.Errorf("%s: the request must be forwarded", name)
+		}
+		if origin := rw.Header().Get("Access-Control-Allow-Origin"); origin != tc.expected {
+			t.Errorf("%s: invalid allowed origin: %q - expected: %q", name, origin, tc.expected)
+		}
+		if credentials := rw.Header().Get("Access-Control-Allow-Credentials"); credentials != tc.credentials {
+			t.Errorf("%s: invalid allowed credentials: %q - expected: %q", name, credentials, tc.credentials)
+		}
+		exposed := rw.Header().Get("Access-Control-Expose-Headers")
+		if tc.expected != "" && (!strings.Contains(exposed, "X-Request-Id") || !strings.Contains(exposed, "ETag")) {
+			t.Errorf("%s: invalid exposed headers: %q", name, exposed)
+		}
+		if tc.expected == "" && exposed != "" {
+			t.Errorf("%s: unexpected exposed headers: %q", name, exposed)
+		}
+	}
+}
+
+func TestCORSHandler_CredentialsAnyOrigin(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	for name, opts := range map[string][]CORSOption{
+		"default origins": {WithCORSCredentials()},
+		"listed wildcard": {WithCORSOrigins("https://app.example.com", "*"), WithCORSCredentials()},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: the credentials must not be allowed for any origin", name)
+				}
+			}()
+			CORSHandler(next, opts...)
+		}()
+	}
+}
@@ -0,0 +1,217 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/armezit/atlas-app-toolkit/query"
+)
+
+// ResponseEnvelope is the JSON body written by the forwarder returned by
+// NewEnvelopeForwardResponseMessage, e.g.
+//
+//	{"success": true, "results": [...], "page": {...}, "request_id": "..."}
+type ResponseEnvelope struct {
+	Success   bool        `json:"success"`
+	Results   interface{} `json:"results"`
+	Page      *PageInfo   `json:"page,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// EnvelopeOption is a type of function that alters the configuration of the
+// forwarder returned by NewEnvelopeForwardResponseMessage
+type EnvelopeOption func(*envelopeForwarder)
+
+// WithEnvelopeRequestID reads the request id of the envelope from the response
+// metadata or the request header under the given key. Defaults to the key set
+// by SetRequestIDKey
+func WithEnvelopeRequestID(key string) EnvelopeOption {
+	return func(fw *envelopeForwarder) {
+		fw.requestIDKey = key
+	}
+}
+
+// WithEnvelopeHeaderMatcher sets the matcher of the response metadata
+// forwarded as HTTP headers. Defaults to PrefixOutgoingHeaderMatcher
+func WithEnvelopeHeaderMatcher(matcher runtime.HeaderMatcherFunc) EnvelopeOption {
+	return func(fw *envelopeForwarder) {
+		fw.outgoingHeaderMatcher = matcher
+	}
+}
+
+// WithEnvelopeErrorHandler sets the handler of the errors occurring while
+// forwarding the response. Defaults to the handler returned by NewErrorHandler
+func WithEnvelopeErrorHandler(handler runtime.ErrorHandlerFunc) EnvelopeOption {
+	return func(fw *envelopeForwarder) {
+		fw.errHandler = handler
+	}
+}
+
+// WithEnvelopeStatusMapper sets the mapper of the status set by SetStatus to
+// the HTTP status
+func WithEnvelopeStatusMapper(mapper *StatusMapper) EnvelopeOption {
+	return func(fw *envelopeForwarder) {
+		fw.statusMapper = mapper
+	}
+}
+
+type envelopeForwarder struct {
+	statusMapper          *StatusMapper
+	requestIDKey          string
+	outgoingHeaderMatcher runtime.HeaderMatcherFunc
+	errHandler            runtime.ErrorHandlerFunc
+}
+
+// EnvelopeForwardResponseMessage is NewEnvelopeForwardResponseMessage with the
+// default options
+var EnvelopeForwardResponseMessage = NewEnvelopeForwardResponseMessage()
+
+// NewEnvelopeForwardResponseMessage returns a ForwardResponseMessageFunc that
+// wraps the response in a ResponseEnvelope. It is opt-in per method, by
+// overriding the forwarder of the generated code, e.g.
+//
+//	forward_Contacts_List_0 = gateway.EnvelopeForwardResponseMessage
+//
+// A message holding a single repeated field, besides a query.PageInfo, is
+// a list payload whose results are the elements of that field, any other
+// message is an object payload. The page of the envelope is read from the
+// query.PageInfo field, or else from the trailer set by SetPageInfoTrailer, and
+// omitted without pagination. A response that already has a success field is
+// not wrapped again, nor are the responses of the marshalers whose content
+// type is not JSON, e.g. runtime.ProtoMarshaller.
+// Streamed responses are not supported, use ForwardResponseStream for them.
+func NewEnvelopeForwardResponseMessage(opts ...EnvelopeOption) ForwardResponseMessageFunc {
+	fw := &envelopeForwarder{
+		outgoingHeaderMatcher: PrefixOutgoingHeaderMatcher,
+		errHandler:            NewErrorHandler(),
+	}
+	for _, opt := range opts {
+		opt(fw)
+	}
+	return fw.forward
+}
+
+func (fw *envelopeForwarder) forward(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, rw http.ResponseWriter, req *http.Request, resp protoreflect.ProtoMessage, opts ...func(context.Context, http.ResponseWriter, protoreflect.ProtoMessage) error) {
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		grpclog.Infof("forward response envelope: failed to extract ServerMetadata from context")
+	}
+	handleForwardResponseServerMetadata(fw.outgoingHeaderMatcher, rw, md)
+	handleForwardResponseTrailerHeader(rw, md)
+
+	if err := handleForwardResponseOptions(ctx, rw, resp, opts); err != nil {
+		fw.errHandler(ctx, mux, marshaler, rw, req, err)
+		return
+	}
+
+	data, err := fw.marshal(marshaler, resp, md, requestIDFromMetadata(md, req, fw.requestIDKey))
+	if err != nil {
+		grpclog.Infof("forward response envelope: failed to marshal response: %v", err)
+		fw.errHandler(ctx, mux, marshaler, rw, req, fmt.Errorf("forward response envelope: internal error"))
+		return
+	}
+
+	httpStatus, _ := fw.statusMapper.restStatus(ctx, nil)
+	rw.Header().Set("Content-Type", marshaler.ContentType(resp))
+	rw.WriteHeader(httpStatus)
+	if _, err := rw.Write(data); err != nil {
+		grpclog.Infof("forward response envelope: failed to write response: %v", err)
+	}
+
+	handleForwardResponseTrailer(rw, md)
+}
+
+func (fw *envelopeForwarder) marshal(marshaler runtime.Marshaler, resp protoreflect.ProtoMessage, md runtime.ServerMetadata, requestID string) ([]byte, error) {
+	data, err := marshaler.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	// the envelope is JSON, the other formats are forwarded unwrapped
+	if !isJSONContentType(marshaler.ContentType(resp)) {
+		return data, nil
+	}
+	env := &ResponseEnvelope{Success: true, RequestID: requestID}
+	env.Page, _ = PageInfoFromMetadata(md)
+	// well-known types such as structpb.ListValue are not marshaled as objects
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		env.Results = json.RawMessage(data)
+		return json.Marshal(env)
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	if _, ok := payload["success"]; ok {
+		return data, nil
+	}
+
+	if fd := pageInfoField(resp); fd != nil {
+		if page, ok := pageInfoFromField(resp, fd); ok {
+			env.Page = page
+		}
+		delete(payload, fd.JSONName())
+		delete(payload, string(fd.Name()))
+	}
+	env.Results = payload
+	if list := listField(resp); list != nil {
+		// an empty list is omitted by the marshaler
+		env.Results = []json.RawMessage{}
+		for _, key := range []string{list.JSONName(), string(list.Name())} {
+			if results, ok := payload[key]; ok {
+				env.Results = results
+			}
+		}
+	}
+	return json.Marshal(env)
+}
+
+// isJSONContentType reports whether the content type is application/json or a
+// JSON based one, e.g. application/problem+json
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+var pageInfoName = (&query.PageInfo{}).ProtoReflect().Descriptor().FullName()
+
+// pageInfoField returns the query.PageInfo field of the message, if any
+func pageInfoField(resp protoreflect.ProtoMessage) protoreflect.FieldDescriptor {
+	fields := resp.ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); fd.Message() != nil && fd.Message().FullName() == pageInfoName {
+			return fd
+		}
+	}
+	return nil
+}
+
+// listField returns the repeated field of a message holding only that field
+// besides the page info, if any
+func listField(resp protoreflect.ProtoMessage) protoreflect.FieldDescriptor {
+	var list protoreflect.FieldDescriptor
+	fields := resp.ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Message() != nil && fd.Message().FullName() == pageInfoName {
+			continue
+		}
+		if !fd.IsList() || list != nil {
+			return nil
+		}
+		list = fd
+	}
+	return list
+}
@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/armezit/atlas-app-toolkit/query"
+)
+
+// newTestListResponse returns a dynamic list message with repeated results and
+// a page info, as generated for a List method
+func newTestListResponse(t *testing.T, results ...*structpb.Struct) proto.Message {
+	page := (&query.PageInfo{}).ProtoReflect().Descriptor()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("gateway/envelope_test.proto"),
+		Package:    proto.String("gateway.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{page.ParentFile().Path(), "google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("ListResponse"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("results"),
+				JsonName: proto.String("results"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".google.protobuf.Struct"),
+			}, {
+				Name:     proto.String("page_info"),
+				JsonName: proto.String("pageInfo"),
+				Number:   proto.Int32(2),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String("." + string(page.FullName())),
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("failed to build the test descriptor: %v", err)
+	}
+	md := fd.Messages().Get(0)
+	msg := dynamicpb.NewMessage(md)
+	list := msg.Mutable(md.Fields().ByName("results")).List()
+	for _, r := range results {
+		list.Append(protoreflect.ValueOfMessage(r.ProtoReflect()))
+	}
+	msg.Set(md.Fields().ByName("page_info"), protoreflect.ValueOfMessage((&query.PageInfo{Offset: 10, Size: 2}).ProtoReflect()))
+	return msg
+}
+
+func TestEnvelopeForwardResponseMessage(t *testing.T) {
+	item, _ := structpb.NewStruct(map[string]interface{}{"name": "contact"})
+	wrapped, _ := structpb.NewStruct(map[string]interface{}{"success": "yes"})
+
+	for name, tc := range map[string]struct {
+		resp     proto.Message
+		expected string
+	}{
+		"object":          {resp: item, expected: `{"success":true,"results":{"name":"contact"}}`},
+		"list":            {resp: newTestListResponse(t, item, item), expected: `{"success":true,"results":[{"name":"contact"},{"name":"contact"}],"page":{"offset":10,"total_size":2}}`},
+		"empty list":      {resp: newTestListResponse(t), expected: `{"success":true,"results":[],"page":{"offset":10,"total_size":2}}`},
+		"list value":      {resp: structpb.NewListValue(&structpb.ListValue{}).GetListValue(), expected: `{"success":true,"results":[]}`},
+		"already wrapped": {resp: wrapped, expected: `{"success":"yes"}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+			rw := httptest.NewRecorder()
+			EnvelopeForwardResponseMessage(ctx, nil, &runtime.JSONPb{}, rw, nil, tc.resp)
+
+			if rw.Code != http.StatusOK {
+				t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusOK)
+			}
+			var actual, expected interface{}
+			if err := json.Unmarshal(rw.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+			json.Unmarshal([]byte(tc.expected), &expected)
+			if a, e := mustMarshal(actual), mustMarshal(expected); a != e {
+				t.Errorf("invalid response: %s - expected: %s", a, e)
+			}
+		})
+	}
+}
+
+func TestEnvelopeForwardResponseMessageRequestID(t *testing.T) {
+	item, _ := structpb.NewStruct(map[string]interface{}{"name": "contact"})
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("X-Request-ID", "server-id"),
+	})
+	rw := httptest.NewRecorder()
+	NewEnvelopeForwardResponseMessage(WithEnvelopeRequestID("X-Request-ID"))(ctx, nil, &runtime.JSONPb{}, rw, nil, item)
+
+	v := &ResponseEnvelope{}
+	if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if !v.Success || v.RequestID != "server-id" {
+		t.Errorf("invalid envelope: %+v", v)
+	}
+}
+
+func TestEnvelopeForwardResponseMessageNonJSON(t *testing.T) {
+	item, _ := structpb.NewStruct(map[string]interface{}{"name": "contact"})
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	rw := httptest.NewRecorder()
+	EnvelopeForwardResponseMessage(ctx, nil, &runtime.ProtoMarshaller{}, rw, nil, item)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("invalid http status code: %d - expected: %d", rw.Code, http.StatusOK)
+	}
+	if ct := rw.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("invalid content type: %q - expected: %q", ct, "application/octet-stream")
+	}
+	v := &structpb.Struct{}
+	if err := proto.Unmarshal(rw.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if !proto.Equal(v, item) {
+		t.Errorf("invalid response: %v - expected: %v", v, item)
+	}
+}
+
+func mustMarshal(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,207 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	rpcdetails "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/armezit/atlas-app-toolkit/rpc/errfields"
+)
+
+// DefaultErrorRequestIDKey is the default metadata key and HTTP header the
+// request id of the error responses is read from, when requestid.SetConfig
+// sets none
+const DefaultErrorRequestIDKey = "X-Request-ID"
+
+// requestIDKey is the metadata key and HTTP header the request id is read from
+// by the error handler, the envelope and the SSE forwarders without option
+var requestIDKey = DefaultErrorRequestIDKey
+
+// SetRequestIDKey sets the default metadata key and HTTP header the request id
+// is read from by the error handler, the envelope and the SSE forwarders. It
+// is called by requestid.SetConfig with the configured key, so that the
+// renderers read the request id where the requestid package sets it.
+func SetRequestIDKey(key string) {
+	if key == "" {
+		key = DefaultErrorRequestIDKey
+	}
+	requestIDKey = key
+}
+
+// ErrorResponse is the JSON body written by the handler returned by
+// NewErrorHandler, e.g.
+//
+//	{"error": {"code": "NOT_FOUND", "message": "...", "request_id": "...", "details": [...]}}
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes the error of an ErrorResponse. The code is the name of
+// the gRPC status code as returned by CodeName, the details are the JSON
+// representations of the status details
+type ErrorBody struct {
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	RequestID string            `json:"request_id,omitempty"`
+	Details   []json.RawMessage `json:"details,omitempty"`
+}
+
+// ErrorHandlerOption is a type of function that alters the configuration of the
+// handler returned by NewErrorHandler
+type ErrorHandlerOption func(*errorHandler)
+
+// WithErrorStatusMapper sets the mapper of the gRPC codes to the HTTP
+// statuses, e.g. the one shared with the response forwarders
+func WithErrorStatusMapper(mapper *StatusMapper) ErrorHandlerOption {
+	return func(h *errorHandler) {
+		h.statusMapper = mapper
+	}
+}
+
+// WithErrorStatus maps the gRPC code to the HTTP status, overriding the
+// mapping of the StatusMapper
+func WithErrorStatus(code codes.Code, httpStatus int) ErrorHandlerOption {
+	return func(h *errorHandler) {
+		h.statuses[code] = httpStatus
+	}
+}
+
+// WithErrorRequestIDKey sets the metadata key and HTTP header the request id is
+// read from. Defaults to the key set by SetRequestIDKey
+func WithErrorRequestIDKey(key string) ErrorHandlerOption {
+	return func(h *errorHandler) {
+		h.requestIDKey = key
+	}
+}
+
+// WithErrorHeaderMatcher sets the matcher of the response metadata forwarded
+// as HTTP headers. Defaults to PrefixOutgoingHeaderMatcher
+func WithErrorHeaderMatcher(matcher runtime.HeaderMatcherFunc) ErrorHandlerOption {
+	return func(h *errorHandler) {
+		h.outgoingHeaderMatcher = matcher
+	}
+}
+
+type errorHandler struct {
+	statusMapper          *StatusMapper
+	statuses              map[codes.Code]int
+	requestIDKey          string
+	outgoingHeaderMatcher runtime.HeaderMatcherFunc
+}
+
+// NewErrorHandler returns a runtime.ErrorHandlerFunc writing the errors as an
+// ErrorResponse, to be registered with runtime.WithErrorHandler. The HTTP
+// status is mapped from the gRPC code by HTTPStatusFromCode unless overridden
+// by WithErrorStatusMapper or WithErrorStatus. The request id is read from the response metadata set
+// by the server, e.g. by the requestid interceptors, then from the request
+// header. A retry delay of the status, see NewRetryAfterError, is set as the
+// Retry-After header. A request body exceeding the limit of
+// MaxBodyBytesHandler yields a 413 Request Entity Too Large.
+func NewErrorHandler(opts ...ErrorHandlerOption) runtime.ErrorHandlerFunc {
+	h := &errorHandler{
+		statuses:              map[codes.Code]int{},
+		outgoingHeaderMatcher: PrefixOutgoingHeaderMatcher,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *errorHandler) handle(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, rw http.ResponseWriter, req *http.Request, err error) {
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		grpclog.Infof("error handler: failed to extract ServerMetadata from context")
+	}
+	handleForwardResponseServerMetadata(h.outgoingHeaderMatcher, rw, md)
+
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.New(codes.Unknown, err.Error())
+	}
+	httpStatus := h.httpStatus(st.Code())
+	if limit, ok := bodyLimitExceeded(req); ok {
+		st = status.Newf(codes.InvalidArgument, "request body too large, the limit is %d bytes", limit)
+		httpStatus = http.StatusRequestEntityTooLarge
+	}
+	resp := newErrorResponse(st, requestIDFromMetadata(md, req, h.requestIDKey))
+
+	buf, merr := marshaler.Marshal(resp)
+	if merr != nil {
+		grpclog.Infof("error handler: failed to marshal error response: %v", merr)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Del("Trailer")
+	rw.Header().Set("Content-Type", marshaler.ContentType(resp))
+	setRetryAfter(rw, st)
+	rw.WriteHeader(httpStatus)
+	if _, err := rw.Write(buf); err != nil {
+		grpclog.Infof("error handler: failed to write response: %v", err)
+	}
+}
+
+// newErrorResponse returns the ErrorResponse of the status, the details that
+// cannot be marshaled are skipped
+func newErrorResponse(st *status.Status, requestID string) *ErrorResponse {
+	resp := &ErrorResponse{Error: ErrorBody{
+		Code:      CodeName(st.Code()),
+		Message:   st.Message(),
+		RequestID: requestID,
+	}}
+	for _, d := range st.Proto().GetDetails() {
+		detail, err := protojson.Marshal(d)
+		if err != nil {
+			grpclog.Infof("error handler: failed to marshal error detail %q: %v", d.GetTypeUrl(), err)
+			continue
+		}
+		resp.Error.Details = append(resp.Error.Details, detail)
+	}
+	return resp
+}
+
+func (h *errorHandler) httpStatus(code codes.Code) int {
+	if httpStatus, ok := h.statuses[code]; ok {
+		return httpStatus
+	}
+	return h.statusMapper.HTTPStatus(code)
+}
+
+// requestIDFromMetadata returns the request id set in the response metadata by
+// the server, or else the one of the request header. An empty key is the one
+// set by SetRequestIDKey, read on every call as requestid.SetConfig may be
+// called after the renderers are built
+func requestIDFromMetadata(md runtime.ServerMetadata, req *http.Request, key string) string {
+	if key == "" {
+		key = requestIDKey
+	}
+	if vals := md.HeaderMD.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	if req != nil {
+		return req.Header.Get(key)
+	}
+	return ""
+}
+
+// badRequestFields converts the field violations of a errdetails.BadRequest
+// detail, e.g. the one of errors.AppError, into the fields of a RestError
+func badRequestFields(d interface{}) (*errfields.FieldInfo, bool) {
+	br, ok := d.(*rpcdetails.BadRequest)
+	if !ok {
+		return nil, false
+	}
+	fields := &errfields.FieldInfo{}
+	for _, v := range br.GetFieldViolations() {
+		fields.AddField(v.GetField(), v.GetDescription())
+	}
+	return fields, true
+}
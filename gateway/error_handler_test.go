@@ -0,0 +1,168 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	rpcdetails "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/armezit/atlas-app-toolkit/errors"
+)
+
+func TestNewErrorHandler(t *testing.T) {
+	st, _ := status.New(codes.NotFound, "contact not found").WithDetails(&rpcdetails.ResourceInfo{ResourceName: "contacts/1"})
+	md := runtime.ServerMetadata{HeaderMD: metadata.Pairs(DefaultErrorRequestIDKey, "server-id")}
+
+	for name, tc := range map[string]struct {
+		ctx       context.Context
+		header    string
+		opts      []ErrorHandlerOption
+		status    int
+		requestID string
+	}{
+		"server request id":  {ctx: runtime.NewServerMetadataContext(context.Background(), md), header: "client-id", status: http.StatusNotFound, requestID: "server-id"},
+		"client request id":  {ctx: context.Background(), header: "client-id", status: http.StatusNotFound, requestID: "client-id"},
+		"overridden status":  {ctx: context.Background(), opts: []ErrorHandlerOption{WithErrorStatus(codes.NotFound, http.StatusGone)}, status: http.StatusGone},
+		"custom request key": {ctx: context.Background(), header: "client-id", opts: []ErrorHandlerOption{WithErrorRequestIDKey("X-Trace-ID")}, status: http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/contacts/1", nil)
+			req.Header.Set(DefaultErrorRequestIDKey, tc.header)
+			rw := httptest.NewRecorder()
+			NewErrorHandler(tc.opts...)(tc.ctx, nil, &runtime.JSONPb{}, rw, req, st.Err())
+
+			if rw.Code != tc.status {
+				t.Errorf("invalid http status code: %d - expected: %d", rw.Code, tc.status)
+			}
+			var v struct {
+				Error struct {
+					Code      string                   `json:"code"`
+					Message   string                   `json:"message"`
+					RequestID string                   `json:"request_id"`
+					Details   []map[string]interface{} `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rw.Body.Bytes(), &v); err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+			if v.Error.Code != "NOT_FOUND" {
+				t.Errorf("invalid code: %s - expected: %s", v.Error.Code, "NOT_FOUND")
+			}
+			if v.Error.Message != "contact not found" {
+				t.Errorf("invalid message: %s", v.Error.Message)
+			}
+			if v.Error.RequestID != tc.requestID {
+				t.Errorf("invalid request id: %q - expected: %q", v.Error.RequestID, tc.requestID)
+			}
+			if len(v.Error.Details) != 1 || v.Error.Details[0]["resourceName"] != "contacts/1" {
+				t.Errorf("invalid details: %v", v.Error.Details)
+			}
+		})
+	}
+}
+
+func TestSetRequestIDKey(t *testing.T) {
+	defer SetRequestIDKey("")
+	// the key is read by the renderers built before it is set
+	errHandler := NewErrorHandler()
+	envelope := NewEnvelopeForwardResponseMessage()
+	sse := NewSSEForwardResponseStream()
+	SetRequestIDKey("X-Correlation-ID")
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	req := httptest.NewRequest(http.MethodGet, "/contacts/1", nil)
+	req.Header.Set("X-Correlation-ID", "abc-123")
+	req.Header.Set(DefaultErrorRequestIDKey, "other-id")
+
+	rw := httptest.NewRecorder()
+	errHandler(ctx, nil, &runtime.JSONPb{}, rw, req, status.Error(codes.NotFound, "gone"))
+	var resp ErrorResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Error.RequestID != "abc-123" {
+		t.Errorf("invalid error request id: %q - expected: %q", resp.Error.RequestID, "abc-123")
+	}
+
+	rw = httptest.NewRecorder()
+	item, _ := structpb.NewStruct(map[string]interface{}{"name": "contact"})
+	envelope(ctx, nil, &runtime.JSONPb{}, rw, req, item)
+	v := &ResponseEnvelope{}
+	if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if v.RequestID != "abc-123" {
+		t.Errorf("invalid envelope request id: %q - expected: %q", v.RequestID, "abc-123")
+	}
+
+	rw = httptest.NewRecorder()
+	sse(ctx, nil, &runtime.JSONBuiltin{}, rw, req, sseRecv(status.Error(codes.NotFound, "gone")))
+	_, events := parseSSE(t, rw.Body.String())
+	resp = ErrorResponse{}
+	if len(events) != 1 {
+		t.Fatalf("invalid number of events: %d - expected: %d", len(events), 1)
+	}
+	if err := json.Unmarshal([]byte(events[0].data), &resp); err != nil {
+		t.Fatalf("failed to unmarshal error data %q: %v", events[0].data, err)
+	}
+	if resp.Error.RequestID != "abc-123" {
+		t.Errorf("invalid SSE request id: %q - expected: %q", resp.Error.RequestID, "abc-123")
+	}
+}
+
+func TestNewErrorHandlerUnknownError(t *testing.T) {
+	rw := httptest.NewRecorder()
+	NewErrorHandler()(context.Background(), nil, &runtime.JSONBuiltin{}, rw, nil, http.ErrHandlerTimeout)
+
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusInternalServerError)
+	}
+	v := &ErrorResponse{}
+	if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if v.Error.Code != "UNKNOWN" || v.Error.Message != http.ErrHandlerTimeout.Error() {
+		t.Errorf("invalid error: %+v", v.Error)
+	}
+}
+
+func TestNewErrorHandlerAppError(t *testing.T) {
+	err := errors.NewAppError(codes.InvalidArgument, "invalid contact").
+		WithFieldViolation("email", "must not be empty")
+
+	rw := httptest.NewRecorder()
+	NewErrorHandler()(context.Background(), nil, &runtime.JSONPb{}, rw, httptest.NewRequest(http.MethodPost, "/contacts", nil), err)
+
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusBadRequest)
+	}
+	var v struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details []struct {
+				Type            string              `json:"@type"`
+				FieldViolations []map[string]string `json:"fieldViolations"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &v); err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if v.Error.Code != "INVALID_ARGUMENT" {
+		t.Errorf("invalid code: %s - expected: %s", v.Error.Code, "INVALID_ARGUMENT")
+	}
+	if len(v.Error.Details) != 1 || v.Error.Details[0].Type != "type.googleapis.com/google.rpc.BadRequest" {
+		t.Fatalf("invalid details: %v", v.Error.Details)
+	}
+	if fv := v.Error.Details[0].FieldViolations; len(fv) != 1 || fv[0]["field"] != "email" || fv[0]["description"] != "must not be empty" {
+		t.Errorf("invalid field violations: %v", fv)
+	}
+}
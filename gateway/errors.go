@@ -44,13 +44,13 @@ var (
 
 // NewProtoMessageErrorHandler returns runtime.ProtoErrorHandlerFunc
 func NewProtoMessageErrorHandler(out runtime.HeaderMatcherFunc) runtime.ErrorHandlerFunc {
-	h := &ProtoErrorHandler{out}
+	h := &ProtoErrorHandler{OutgoingHeaderMatcher: out}
 	return h.MessageHandler
 }
 
 // NewProtoStreamErrorHandler returns ProtoStreamErrorHandlerFunc
 func NewProtoStreamErrorHandler(out runtime.HeaderMatcherFunc) ProtoStreamErrorHandlerFunc {
-	h := &ProtoErrorHandler{out}
+	h := &ProtoErrorHandler{OutgoingHeaderMatcher: out}
 	return h.StreamHandler
 }
 
@@ -60,6 +60,9 @@ func NewProtoStreamErrorHandler(out runtime.HeaderMatcherFunc) ProtoStreamErrorH
 // See RestError for the JSON format of an error
 type ProtoErrorHandler struct {
 	OutgoingHeaderMatcher runtime.HeaderMatcherFunc
+	// StatusMapper maps the gRPC code of the error to the HTTP status,
+	// HTTPStatusFromCode is used when nil
+	StatusMapper *StatusMapper
 }
 
 // MessageHandler implements runtime.ProtoErrorHandlerFunc
@@ -97,7 +100,7 @@ func (h *ProtoErrorHandler) writeError(ctx context.Context, headerWritten bool,
 	if !ok {
 		st = status.New(codes.Unknown, err.Error())
 	}
-	statusCode, statusStr := HTTPStatus(ctx, st)
+	statusCode, statusStr := h.StatusMapper.restStatus(ctx, st)
 
 	details := []interface{}{}
 	var fields interface{}
@@ -109,6 +112,13 @@ func (h *ProtoErrorHandler) writeError(ctx context.Context, headerWritten bool,
 		case *errfields.FieldInfo:
 			fields = d
 		default:
+			if fi, ok := badRequestFields(d); ok {
+				fields = fi
+				continue
+			}
+			if isRetryInfo(d) {
+				continue
+			}
 			grpclog.Infof("error handler: failed to recognize error message")
 			rw.WriteHeader(http.StatusInternalServerError)
 			return
@@ -140,6 +150,7 @@ func (h *ProtoErrorHandler) writeError(ctx context.Context, headerWritten bool,
 		restResp.Error[0]["status"] = statusStr
 	}
 	if !headerWritten {
+		setRetryAfter(rw, st)
 		rw.Header().Del("Trailer")
 		rw.Header().Set("Content-Type", marshaler.ContentType(nil))
 		rw.WriteHeader(statusCode)
@@ -165,3 +165,29 @@ func TestWriteErrorContainer(t *testing.T) {
 	}
 
 }
+
+func TestWriteErrorAppError(t *testing.T) {
+	err := errors.NewAppError(codes.InvalidArgument, "Invalid contact.").
+		WithFieldViolation("email", "must not be empty")
+
+	v := new(RestErrs)
+
+	rw := httptest.NewRecorder()
+	ProtoMessageErrorHandler(context.Background(), nil, &runtime.JSONBuiltin{}, rw, nil, err)
+
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusBadRequest)
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+
+	if v.Error[0]["message"] != "Invalid contact." {
+		t.Errorf("invalid message: %s", v.Error[0]["message"])
+	}
+
+	vMap := v.Error[0]["fields"].(map[string]interface{})
+	if vMap["email"].([]interface{})[0] != "must not be empty" {
+		t.Errorf("invalid fields value: %v", v.Error[0]["fields"])
+	}
+}
@@ -0,0 +1,98 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ETagForwardResponseMessage is NewETagForwardResponseMessage wrapping
+// ForwardResponseMessage
+var ETagForwardResponseMessage = NewETagForwardResponseMessage(ForwardResponseMessage)
+
+// NewETagForwardResponseMessage returns a ForwardResponseMessageFunc that sets
+// a weak ETag, the SHA-256 of the body written by next, on the successful
+// responses of the GET and HEAD requests. When the If-None-Match header of the
+// request matches the ETag, a 304 Not Modified is written without body. It is
+// opt-in per method, by overriding the forwarder of the generated code, e.g.
+//
+//	forward_Files_GetFile_0 = gateway.ETagForwardResponseMessage
+//
+// The body is buffered to be hashed, the checksum only depends on its content
+// so the ETag is the same across the instances and restarts of the gateway.
+func NewETagForwardResponseMessage(next ForwardResponseMessageFunc) ForwardResponseMessageFunc {
+	return func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, rw http.ResponseWriter, req *http.Request, resp protoreflect.ProtoMessage, opts ...func(context.Context, http.ResponseWriter, protoreflect.ProtoMessage) error) {
+		if req == nil || req.Method != http.MethodGet && req.Method != http.MethodHead {
+			next(ctx, mux, marshaler, rw, req, resp, opts...)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: rw}
+		next(ctx, mux, marshaler, bw, req, resp, opts...)
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+
+		if bw.status == http.StatusOK && rw.Header().Get("ETag") == "" {
+			etag := weakETag(bw.body.Bytes())
+			rw.Header().Set("ETag", etag)
+			if etagMatch(req.Header.Values("If-None-Match"), etag) {
+				rw.Header().Del("Content-Length")
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		rw.WriteHeader(bw.status)
+		if _, err := rw.Write(bw.body.Bytes()); err != nil {
+			grpclog.Infof("forward response etag: failed to write response: %v", err)
+		}
+	}
+}
+
+// bufferedWriter holds the status and the body of the response, the header
+// is the one of the wrapped writer
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(p)
+}
+
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch reports whether one of the If-None-Match values matches the ETag
+// with the weak comparison of RFC 7232
+func etagMatch(ifNoneMatch []string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, header := range ifNoneMatch {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	gateway_test "github.com/armezit/atlas-app-toolkit/gateway/internal"
+)
+
+func forwardETag(method string, ifNoneMatch string, user *gateway_test.User) *httptest.ResponseRecorder {
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/v1/users/1", nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	ETagForwardResponseMessage(ctx, nil, &runtime.JSONBuiltin{}, rw, req, user)
+	return rw
+}
+
+func TestETagForwardResponseMessage(t *testing.T) {
+	poe := &gateway_test.User{Name: "Poe", Age: 209}
+
+	rw := forwardETag(http.MethodGet, "", poe)
+	etag := rw.Header().Get("ETag")
+	if rw.Code != http.StatusOK || rw.Body.Len() == 0 {
+		t.Fatalf("invalid response: %d %q - expected: %d with a body", rw.Code, rw.Body.String(), http.StatusOK)
+	}
+	if len(etag) != len(`W/""`)+32 || etag[:3] != `W/"` {
+		t.Errorf("invalid etag: %s", etag)
+	}
+
+	// the etag only depends on the content
+	if other := forwardETag(http.MethodGet, "", &gateway_test.User{Name: "Poe", Age: 209}).Header().Get("ETag"); other != etag {
+		t.Errorf("invalid etag: %s - expected: %s", other, etag)
+	}
+	if other := forwardETag(http.MethodGet, "", &gateway_test.User{Name: "Hemingway", Age: 119}).Header().Get("ETag"); other == etag {
+		t.Errorf("invalid etag: %s - expected another one", other)
+	}
+
+	for name, tc := range map[string]struct {
+		method      string
+		ifNoneMatch string
+		code        int
+		etag        bool
+	}{
+		"match":        {method: http.MethodGet, ifNoneMatch: etag, code: http.StatusNotModified, etag: true},
+		"strong match": {method: http.MethodGet, ifNoneMatch: etag[2:], code: http.StatusNotModified, etag: true},
+		"list match":   {method: http.MethodGet, ifNoneMatch: `"other", ` + etag, code: http.StatusNotModified, etag: true},
+		"any":          {method: http.MethodGet, ifNoneMatch: "*", code: http.StatusNotModified, etag: true},
+		"no match":     {method: http.MethodGet, ifNoneMatch: `W/"other"`, code: http.StatusOK, etag: true},
+		"head":         {method: http.MethodHead, ifNoneMatch: etag, code: http.StatusNotModified, etag: true},
+		"post":         {method: http.MethodPost, ifNoneMatch: etag, code: http.StatusOK},
+		"delete":       {method: http.MethodDelete, code: http.StatusOK},
+	} {
+		rw := forwardETag(tc.method, tc.ifNoneMatch, poe)
+		if rw.Code != tc.code {
+			t.Errorf("%s: invalid http status code: %d - expected: %d", name, rw.Code, tc.code)
+		}
+		if tc.code == http.StatusNotModified && rw.Body.Len() != 0 {
+			t.Errorf("%s: unexpected body: %q", name, rw.Body.String())
+		}
+		if tc.code == http.StatusOK && rw.Body.Len() == 0 {
+			t.Errorf("%s: missing body", name)
+		}
+		if got := rw.Header().Get("ETag"); (got != "") != tc.etag {
+			t.Errorf("%s: invalid etag: %q", name, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	// DefaultFieldMaskParam is the default query parameter the field mask of
+	// the response is read from, e.g. ?fields=id,user.address.city
+	DefaultFieldMaskParam = "fields"
+
+	fieldMaskMetaKey = "field-mask"
+)
+
+// NewFieldMaskAnnotator returns an annotator storing the comma separated
+// paths of the query parameter param in the gRPC context, for
+// FieldMaskForwardResponseOption to prune the response with.
+func NewFieldMaskAnnotator(param string) func(context.Context, *http.Request) metadata.MD {
+	return func(ctx context.Context, req *http.Request) metadata.MD {
+		if req == nil || req.URL == nil {
+			return nil
+		}
+		if fields := req.URL.Query()[param]; len(fields) > 0 {
+			return metadata.Pairs(fieldMaskMetaKey, strings.Join(fields, ","))
+		}
+		return nil
+	}
+}
+
+// FieldMaskForwardResponseOption prunes the response message to the paths
+// stored by the annotator returned by NewFieldMaskAnnotator, it is meant to
+// be registered with runtime.WithForwardResponseOption. An unknown path fails
+// the response with codes.InvalidArgument, i.e. a 400 of the error handler.
+func FieldMaskForwardResponseOption(ctx context.Context, rw http.ResponseWriter, resp protoreflect.ProtoMessage) error {
+	fields, ok := Header(ctx, fieldMaskMetaKey)
+	if !ok || fields == "" || resp == nil {
+		return nil
+	}
+	mask, err := ParseFieldMask(resp, fields)
+	if err != nil {
+		return err
+	}
+	ApplyFieldMask(resp, mask)
+	return nil
+}
+
+// ParseFieldMask builds the field mask of the comma separated paths of the
+// message. The path segments are field names, either the proto or the JSON
+// ones, and may go through repeated and map fields, e.g. results.address.city
+// selects the city of every result. The mask holds the proto names of the
+// fields, an unknown path returns a codes.InvalidArgument error.
+func ParseFieldMask(msg protoreflect.ProtoMessage, fields string) (*fieldmaskpb.FieldMask, error) {
+	mask := &fieldmaskpb.FieldMask{}
+	desc := msg.ProtoReflect().Descriptor()
+	for _, path := range strings.Split(fields, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		resolved, err := resolveFieldPath(desc, path)
+		if err != nil {
+			return nil, err
+		}
+		mask.Paths = append(mask.Paths, resolved)
+	}
+	mask.Normalize()
+	return mask, nil
+}
+
+// ApplyFieldMask clears the fields of the message that are not selected by
+// the mask, an empty mask selects all the fields.
+func ApplyFieldMask(msg protoreflect.ProtoMessage, mask *fieldmaskpb.FieldMask) {
+	if len(mask.GetPaths()) == 0 {
+		return
+	}
+	tree := fieldMaskTree{}
+	for _, path := range mask.GetPaths() {
+		tree.add(strings.Split(path, "."))
+	}
+	tree.prune(msg.ProtoReflect())
+}
+
+func resolveFieldPath(desc protoreflect.MessageDescriptor, path string) (string, error) {
+	segments := strings.Split(path, ".")
+	names := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if desc == nil {
+			return "", status.Errorf(codes.InvalidArgument, "invalid field path %q: %q is not a message", path, strings.Join(names, "."))
+		}
+		fd := desc.Fields().ByName(protoreflect.Name(seg))
+		if fd == nil {
+			fd = desc.Fields().ByJSONName(seg)
+		}
+		if fd == nil {
+			return "", status.Errorf(codes.InvalidArgument, "invalid field path %q: unknown field %q of %s", path, seg, desc.FullName())
+		}
+		names = append(names, string(fd.Name()))
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		desc = fd.Message()
+	}
+	return strings.Join(names, "."), nil
+}
+
+// fieldMaskTree holds the selected fields by name, a leaf selects the
+// whole field
+type fieldMaskTree map[protoreflect.Name]fieldMaskTree
+
+func (t fieldMaskTree) add(path []string) {
+	sub, ok := t[protoreflect.Name(path[0])]
+	if ok && len(sub) == 0 {
+		// the whole field is already selected
+		return
+	}
+	if len(path) == 1 {
+		t[protoreflect.Name(path[0])] = fieldMaskTree{}
+		return
+	}
+	if sub == nil {
+		sub = fieldMaskTree{}
+		t[protoreflect.Name(path[0])] = sub
+	}
+	sub.add(path[1:])
+}
+
+func (t fieldMaskTree) prune(m protoreflect.Message) {
+	var cleared []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := t[fd.Name()]
+		switch {
+		case !ok:
+			cleared = append(cleared, fd)
+		case len(sub) == 0:
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					sub.prune(mv.Message())
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				for i := 0; i < v.List().Len(); i++ {
+					sub.prune(v.List().Get(i).Message())
+				}
+			}
+		case fd.Message() != nil:
+			sub.prune(v.Message())
+		}
+		return true
+	})
+	for _, fd := range cleared {
+		m.Clear(fd)
+	}
+}
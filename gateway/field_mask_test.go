@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func testFieldMaskMessage() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("contacts.proto"),
+		Package: proto.String("contacts"),
+		Options: &descriptorpb.FileOptions{
+			JavaPackage: proto.String("com.contacts"),
+			GoPackage:   proto.String("contacts"),
+		},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Contact"), Options: &descriptorpb.MessageOptions{Deprecated: proto.Bool(true), MapEntry: proto.Bool(false)}},
+			{Name: proto.String("Address")},
+		},
+	}
+}
+
+func TestParseFieldMask(t *testing.T) {
+	for fields, expected := range map[string][]string{
+		"name":                            {"name"},
+		"options.javaPackage, package":    {"options.java_package", "package"},
+		"options,options.go_package":      {"options"},
+		"message_type.options.deprecated": {"message_type.options.deprecated"},
+	} {
+		mask, err := ParseFieldMask(testFieldMaskMessage(), fields)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", fields, err)
+			continue
+		}
+		if !reflect.DeepEqual(mask.GetPaths(), expected) {
+			t.Errorf("invalid paths for %q: %v - expected: %v", fields, mask.GetPaths(), expected)
+		}
+	}
+
+	for _, fields := range []string{"unknown", "options.unknown", "name.first"} {
+		if _, err := ParseFieldMask(testFieldMaskMessage(), fields); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("invalid error for %q: %v - expected: %v", fields, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestApplyFieldMask(t *testing.T) {
+	msg := testFieldMaskMessage()
+	mask, err := ParseFieldMask(msg, "name,options.java_package,message_type.options.deprecated")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ApplyFieldMask(msg, mask)
+
+	expected := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("contacts.proto"),
+		Options: &descriptorpb.FileOptions{JavaPackage: proto.String("com.contacts")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Options: &descriptorpb.MessageOptions{Deprecated: proto.Bool(true)}},
+			{},
+		},
+	}
+	if !proto.Equal(msg, expected) {
+		t.Errorf("invalid pruned message: %v - expected: %v", msg, expected)
+	}
+}
+
+func TestFieldMaskForwardResponseOption(t *testing.T) {
+	annotate := NewFieldMaskAnnotator(DefaultFieldMaskParam)
+	for name, tc := range map[string]struct {
+		url      string
+		err      codes.Code
+		expected *descriptorpb.FileDescriptorProto
+	}{
+		"no fields":     {url: "/contacts", expected: testFieldMaskMessage()},
+		"nested fields": {url: "/contacts?fields=package&fields=options.goPackage", expected: &descriptorpb.FileDescriptorProto{Package: proto.String("contacts"), Options: &descriptorpb.FileOptions{GoPackage: proto.String("contacts")}}},
+		"unknown field": {url: "/contacts?fields=user.address.city", err: codes.InvalidArgument},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			ctx, err := runtime.AnnotateContext(context.Background(), runtime.NewServeMux(runtime.WithMetadata(annotate)), req, "/contacts.Contacts/Read")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			msg := testFieldMaskMessage()
+			err = FieldMaskForwardResponseOption(ctx, httptest.NewRecorder(), msg)
+			if status.Code(err) != tc.err {
+				t.Fatalf("invalid error: %v - expected: %v", err, tc.err)
+			}
+			if tc.expected != nil && !proto.Equal(msg, tc.expected) {
+				t.Errorf("invalid pruned message: %v - expected: %v", msg, tc.expected)
+			}
+		})
+	}
+}
@@ -168,3 +168,14 @@ func WithGatewayOptions(opt ...runtime.ServeMuxOption) Option {
 		g.gatewayMuxOptions = append(g.gatewayMuxOptions, opt...)
 	}
 }
+
+// WithOutgoingHeaderMatcher forwards the gRPC response metadata matching the
+// given matcher as HTTP response headers, for both the successful and the
+// failed calls, see ResponseHeaderMatcher. It replaces the default
+// ProtoMessageErrorHandler by one using the matcher
+func WithOutgoingHeaderMatcher(matcher runtime.HeaderMatcherFunc) Option {
+	return WithGatewayOptions(
+		runtime.WithOutgoingHeaderMatcher(matcher),
+		runtime.WithErrorHandler(NewProtoMessageErrorHandler(matcher)),
+	)
+}
@@ -54,6 +54,17 @@ func Header(ctx context.Context, key string) (string, bool) {
 	return "", false
 }
 
+// HeaderValues returns all the values of the key, e.g. of a multi-valued
+// header such as x-forwarded-for, while Header only returns the first one.
+// The incoming and outgoing metadata of the context, and the response header
+// metadata of the gateway, are all looked up and their values joined: the
+// incoming metadata carries the headers received by a gRPC server, while the
+// outgoing metadata carries the ones the gateway forwards to it.
+// Calls HeaderN(ctx, key, -1)
+func HeaderValues(ctx context.Context, key string) ([]string, bool) {
+	return HeaderN(ctx, key, -1)
+}
+
 // HeaderN returns first n values for a given key if it exists in gRPC metadata
 // from incoming or outcoming context, otherwise returns (nil, false)
 //
@@ -156,6 +167,107 @@ func TracingHeaderMatcher() runtime.HeaderMatcherFunc {
 	return ExtendedDefaultHeaderMatcher(GetXB3Headers()...)
 }
 
+// ResponseHeaderMatcher returns an outgoing header matcher forwarding the
+// gRPC response metadata key, e.g. the request id echoed by the server, as
+// the given HTTP response header. The header defaults to the key when empty
+func ResponseHeaderMatcher(key, header string) runtime.HeaderMatcherFunc {
+	if header == "" {
+		header = key
+	}
+	key = strings.ToLower(key)
+	return func(k string) (string, bool) {
+		if strings.ToLower(k) != key {
+			return "", false
+		}
+		return header, true
+	}
+}
+
+// AccountIDHeader is the header carrying the account id of the calls without
+// token, see auth.WithAccountIDHeader. As any client can set it, it must only
+// be trusted when the network guarantees its origin, it is only returned by
+// DefaultOutgoingHeaderMatcher and must be allowed with WithAllowedHeaders to
+// be passed to the gRPC services
+const AccountIDHeader = "X-Account-ID"
+
+// DefaultAllowedHeaders returns the headers passed through by
+// DefaultIncomingHeaderMatcher and DefaultOutgoingHeaderMatcher: the request
+// id and tracing headers of the toolkit
+func DefaultAllowedHeaders() []string {
+	return append([]string{
+		"X-Request-ID",
+		"X-Parent-Request-ID",
+		"Request-Id",
+		"traceparent",
+		"tracestate",
+		"baggage",
+	}, GetXB3Headers()...)
+}
+
+// defaultOutgoingHeaders are the headers returned by
+// DefaultOutgoingHeaderMatcher besides the DefaultAllowedHeaders
+var defaultOutgoingHeaders = []string{AccountIDHeader}
+
+// HeaderMatcherOption is a type of function that alters the headers passed
+// through by DefaultIncomingHeaderMatcher and DefaultOutgoingHeaderMatcher
+type HeaderMatcherOption func(allowed map[string]string)
+
+// WithAllowedHeaders passes the given headers through in addition to the
+// DefaultAllowedHeaders
+func WithAllowedHeaders(headers ...string) HeaderMatcherOption {
+	return func(allowed map[string]string) {
+		for _, h := range headers {
+			allowed[strings.ToLower(h)] = h
+		}
+	}
+}
+
+func allowedHeaders(opts []HeaderMatcherOption) map[string]string {
+	allowed := map[string]string{}
+	WithAllowedHeaders(DefaultAllowedHeaders()...)(allowed)
+	for _, opt := range opts {
+		opt(allowed)
+	}
+	return allowed
+}
+
+func outgoingAllowedHeaders(opts []HeaderMatcherOption) map[string]string {
+	allowed := allowedHeaders(opts)
+	WithAllowedHeaders(defaultOutgoingHeaders...)(allowed)
+	return allowed
+}
+
+// DefaultIncomingHeaderMatcher returns the incoming header matcher of
+// runtime.WithIncomingHeaderMatcher. The allowed headers are passed to the gRPC
+// service under their lowercase name, e.g. x-request-id, the other headers are
+// matched by runtime.DefaultHeaderMatcher which strips the Grpc-Metadata-
+// prefix and prefixes the permanent HTTP headers with grpcgateway-.
+func DefaultIncomingHeaderMatcher(opts ...HeaderMatcherOption) runtime.HeaderMatcherFunc {
+	allowed := allowedHeaders(opts)
+	return func(h string) (string, bool) {
+		key := strings.ToLower(h)
+		if _, ok := allowed[key]; ok {
+			return key, true
+		}
+		return runtime.DefaultHeaderMatcher(h)
+	}
+}
+
+// DefaultOutgoingHeaderMatcher returns the outgoing header matcher of
+// runtime.WithOutgoingHeaderMatcher, or WithOutgoingHeaderMatcher. The allowed
+// metadata keys of the gRPC response are returned as HTTP headers without the
+// Grpc-Metadata- prefix, the other keys are discarded. The account id of the
+// response is returned under AccountIDHeader as well.
+func DefaultOutgoingHeaderMatcher(opts ...HeaderMatcherOption) runtime.HeaderMatcherFunc {
+	allowed := outgoingAllowedHeaders(opts)
+	return func(key string) (string, bool) {
+		if h, ok := allowed[strings.ToLower(key)]; ok {
+			return textproto.CanonicalMIMEHeaderKey(h), true
+		}
+		return "", false
+	}
+}
+
 // AtlasDefaultHeaderMatcher func used to add all headers used by atlas-app-toolkit
 // This function also passes through all the headers that runtime.DefaultHeaderMatcher handles.
 // AtlasDefaultHeaderMatcher can be used as a Incoming/Outgoing header matcher.
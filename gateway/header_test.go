@@ -2,9 +2,16 @@ package gateway
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 func TestHeader(t *testing.T) {
@@ -55,6 +62,74 @@ func TestHeaderN(t *testing.T) {
 	}
 }
 
+func TestHeaderValues(t *testing.T) {
+	imd := metadata.Pairs("x-forwarded-for", "10.0.0.1", "x-forwarded-for", "10.0.0.2")
+	omd := metadata.Pairs("grpcgateway-x-forwarded-for", "10.0.0.3")
+
+	ictx := metadata.NewIncomingContext(context.Background(), imd)
+	ctx := metadata.NewOutgoingContext(ictx, omd)
+
+	if v, ok := HeaderValues(ctx, "X-Forwarded-For"); !ok {
+		t.Error("failed to get 'x-forwarded-for'")
+	} else if len(v) != 3 || v[0] != "10.0.0.1" || v[1] != "10.0.0.2" || v[2] != "10.0.0.3" {
+		t.Errorf("invalid values of 'x-forwarded-for': %s", v)
+	}
+	if v, ok := Header(ctx, "x-forwarded-for"); !ok || v != "10.0.0.1" {
+		t.Errorf("invalid value of 'x-forwarded-for': %s", v)
+	}
+
+	if v, ok := HeaderValues(ctx, "missing"); ok || v != nil {
+		t.Errorf("invalid result of a missing key: %s, %v", v, ok)
+	}
+}
+
+func TestDefaultIncomingHeaderMatcher(t *testing.T) {
+	matcher := DefaultIncomingHeaderMatcher(WithAllowedHeaders("X-Tenant-Region"))
+	for in, expected := range map[string]struct {
+		key string
+		ok  bool
+	}{
+		"X-Request-Id":            {"x-request-id", true},
+		"x-parent-request-id":     {"x-parent-request-id", true},
+		"X-Account-ID":            {"", false},
+		"X-B3-TraceId":            {"x-b3-traceid", true},
+		"Traceparent":             {"traceparent", true},
+		"Baggage":                 {"baggage", true},
+		"X-Tenant-Region":         {"x-tenant-region", true},
+		"Grpc-Metadata-My-Header": {"My-Header", true},
+		"Authorization":           {"grpcgateway-Authorization", true},
+		"X-Unknown":               {"", false},
+	} {
+		if key, ok := matcher(in); key != expected.key || ok != expected.ok {
+			t.Errorf("invalid match of %q: (%q, %v) - expected: (%q, %v)", in, key, ok, expected.key, expected.ok)
+		}
+	}
+
+	// the account id header is passed through when allowed
+	matcher = DefaultIncomingHeaderMatcher(WithAllowedHeaders(AccountIDHeader))
+	if key, ok := matcher("X-Account-ID"); key != "x-account-id" || !ok {
+		t.Errorf("invalid match of %q: (%q, %v) - expected: (%q, %v)", "X-Account-ID", key, ok, "x-account-id", true)
+	}
+}
+
+func TestDefaultOutgoingHeaderMatcher(t *testing.T) {
+	matcher := DefaultOutgoingHeaderMatcher(WithAllowedHeaders("x-tenant-region"))
+	for in, expected := range map[string]struct {
+		header string
+		ok     bool
+	}{
+		"x-request-id":        {"X-Request-Id", true},
+		"x-account-id":        {"X-Account-Id", true},
+		"x-tenant-region":     {"X-Tenant-Region", true},
+		"grpcgateway-status":  {"", false},
+		"x-internal-metadata": {"", false},
+	} {
+		if header, ok := matcher(in); header != expected.header || ok != expected.ok {
+			t.Errorf("invalid match of %q: (%q, %v) - expected: (%q, %v)", in, header, ok, expected.header, expected.ok)
+		}
+	}
+}
+
 func TestPrefixOutgoingHeaderMatcher(t *testing.T) {
 	key := "Content-Type"
 	v, ok := PrefixOutgoingHeaderMatcher(key)
@@ -271,3 +346,56 @@ func TestChainHeaderMatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestResponseHeaderMatcher(t *testing.T) {
+	matcher := ResponseHeaderMatcher("X-Request-ID", "Request-Id")
+	if h, ok := matcher("x-request-id"); !ok || h != "Request-Id" {
+		t.Errorf("invalid result for the request id key: %s, %v", h, ok)
+	}
+	if h, ok := matcher("other"); ok {
+		t.Errorf("invalid result for another key: %s, %v", h, ok)
+	}
+	if h, _ := ResponseHeaderMatcher("X-Request-ID", "")("x-request-id"); h != "X-Request-ID" {
+		t.Errorf("invalid default header: %s", h)
+	}
+}
+
+func TestWithOutgoingHeaderMatcher(t *testing.T) {
+	var mux *runtime.ServeMux
+	register := func(ctx context.Context, m *runtime.ServeMux, addr string, opts []grpc.DialOption) error {
+		mux = m
+		return nil
+	}
+	if _, err := NewGateway(
+		WithEndpointRegistration("/v1/", register),
+		WithOutgoingHeaderMatcher(ResponseHeaderMatcher("X-Request-ID", "")),
+	); err != nil {
+		t.Fatalf("failed to create gateway: %v", err)
+	}
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		HeaderMD: metadata.Pairs("x-request-id", "test-request-id", "other", "value"),
+	})
+	req := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+
+	for name, forward := range map[string]func(http.ResponseWriter){
+		"success": func(rw http.ResponseWriter) {
+			runtime.ForwardResponseMessage(ctx, mux, &runtime.JSONPb{}, rw, req, &emptypb.Empty{})
+		},
+		"error": func(rw http.ResponseWriter) {
+			runtime.HTTPError(ctx, mux, &runtime.JSONPb{}, rw, req, status.Error(codes.Internal, "internal error"))
+		},
+	} {
+		rec := httptest.NewRecorder()
+		forward(rec)
+		if name == "error" && rec.Code != http.StatusInternalServerError {
+			t.Errorf("invalid status code of %s response: %d", name, rec.Code)
+		}
+		if h := rec.Header().Get("X-Request-ID"); h != "test-request-id" {
+			t.Errorf("invalid request id header of %s response: %q", name, h)
+		}
+		if h := rec.Header().Get("Other"); h != "" {
+			t.Errorf("unexpected header of %s response: %q", name, h)
+		}
+	}
+}
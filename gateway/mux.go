@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+// MuxOption is a type of function that alters the configuration of the
+// ServeMux built by NewServeMux
+type MuxOption func(*muxConfig)
+
+type muxConfig struct {
+	errorHandler          runtime.ErrorHandlerFunc
+	incomingHeaderMatcher runtime.HeaderMatcherFunc
+	outgoingHeaderMatcher runtime.HeaderMatcherFunc
+	marshaler             runtime.Marshaler
+	annotators            []func(context.Context, *http.Request) metadata.MD
+	muxOptions            []runtime.ServeMuxOption
+	accountIDHeader       bool
+}
+
+// WithMuxErrorHandler sets the error handler of the mux. Defaults to the
+// handler returned by NewErrorHandler with the outgoing header matcher
+func WithMuxErrorHandler(handler runtime.ErrorHandlerFunc) MuxOption {
+	return func(c *muxConfig) {
+		c.errorHandler = handler
+	}
+}
+
+// WithMuxIncomingHeaderMatcher sets the matcher of the HTTP headers passed to
+// the gRPC service. Defaults to DefaultIncomingHeaderMatcher
+func WithMuxIncomingHeaderMatcher(matcher runtime.HeaderMatcherFunc) MuxOption {
+	return func(c *muxConfig) {
+		c.incomingHeaderMatcher = matcher
+	}
+}
+
+// WithMuxOutgoingHeaderMatcher sets the matcher of the response metadata
+// returned as HTTP headers, by the mux and by the default error handler.
+// Defaults to DefaultOutgoingHeaderMatcher
+func WithMuxOutgoingHeaderMatcher(matcher runtime.HeaderMatcherFunc) MuxOption {
+	return func(c *muxConfig) {
+		c.outgoingHeaderMatcher = matcher
+	}
+}
+
+// WithMuxMarshaler sets the marshaler of all the MIME types. Defaults to the
+// one of the grpc-gateway runtime
+func WithMuxMarshaler(marshaler runtime.Marshaler) MuxOption {
+	return func(c *muxConfig) {
+		c.marshaler = marshaler
+	}
+}
+
+// WithMuxAnnotators adds the annotators to the MetadataAnnotator, e.g.
+// auth.AccountIDAnnotator or logging.Annotator
+func WithMuxAnnotators(annotators ...func(context.Context, *http.Request) metadata.MD) MuxOption {
+	return func(c *muxConfig) {
+		c.annotators = append(c.annotators, annotators...)
+	}
+}
+
+// WithMuxAccountIDHeader passes the account id headers of the requests to the
+// gRPC service, e.g. for auth.WithAccountIDHeader, when the incoming header
+// matcher allows them. As any client can set them, it must only be used when
+// the network guarantees their origin
+func WithMuxAccountIDHeader() MuxOption {
+	return func(c *muxConfig) {
+		c.accountIDHeader = true
+	}
+}
+
+// WithMuxOptions adds the ServeMuxOptions, which are applied after the ones of
+// NewServeMux and so override them
+func WithMuxOptions(opts ...runtime.ServeMuxOption) MuxOption {
+	return func(c *muxConfig) {
+		c.muxOptions = append(c.muxOptions, opts...)
+	}
+}
+
+// NewServeMux returns a runtime.ServeMux configured with the defaults of the
+// toolkit: the error handler returned by NewErrorHandler, the
+// DefaultIncomingHeaderMatcher and DefaultOutgoingHeaderMatcher header
+// matchers and the MetadataAnnotator. The generated handlers are registered
+// on it as on any runtime.ServeMux.
+//
+// The headers of the requests passed under the x-account-id metadata key, e.g.
+// X-Account-ID or Grpc-Metadata-X-Account-ID, are dropped unless
+// WithMuxAccountIDHeader is given, so that the services never take the
+// account id set by a client for the one of auth.AccountIDAnnotator, which is
+// added with WithMuxAnnotators.
+func NewServeMux(opts ...MuxOption) *runtime.ServeMux {
+	c := &muxConfig{
+		incomingHeaderMatcher: DefaultIncomingHeaderMatcher(),
+		outgoingHeaderMatcher: DefaultOutgoingHeaderMatcher(),
+		annotators:            []func(context.Context, *http.Request) metadata.MD{MetadataAnnotator},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.errorHandler == nil {
+		c.errorHandler = NewErrorHandler(WithErrorHeaderMatcher(c.outgoingHeaderMatcher))
+	}
+
+	incoming := c.incomingHeaderMatcher
+	if !c.accountIDHeader {
+		incoming = withoutAccountIDHeader(incoming)
+	}
+
+	muxOpts := []runtime.ServeMuxOption{
+		runtime.WithErrorHandler(c.errorHandler),
+		runtime.WithIncomingHeaderMatcher(incoming),
+		runtime.WithOutgoingHeaderMatcher(c.outgoingHeaderMatcher),
+	}
+	for _, annotator := range c.annotators {
+		muxOpts = append(muxOpts, runtime.WithMetadata(annotator))
+	}
+	if c.marshaler != nil {
+		muxOpts = append(muxOpts, runtime.WithMarshalerOption(runtime.MIMEWildcard, c.marshaler))
+	}
+	return runtime.NewServeMux(append(muxOpts, c.muxOptions...)...)
+}
+
+// withoutAccountIDHeader drops the headers the matcher passes under the
+// metadata key of AccountIDHeader
+func withoutAccountIDHeader(matcher runtime.HeaderMatcherFunc) runtime.HeaderMatcherFunc {
+	return func(h string) (string, bool) {
+		key, ok := matcher(h)
+		if ok && strings.EqualFold(key, AccountIDHeader) {
+			return "", false
+		}
+		return key, ok
+	}
+}
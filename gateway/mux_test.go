@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewServeMux_Annotate(t *testing.T) {
+	mux := NewServeMux(WithMuxAnnotators(func(ctx context.Context, req *http.Request) metadata.MD {
+		return metadata.Pairs("annotated", "true")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users?_limit=1", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	ctx, err := runtime.AnnotateContext(context.Background(), mux, req, "/app.Users/List")
+	if err != nil {
+		t.Fatalf("failed to annotate context: %v", err)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, expected := range map[string]string{"x-request-id": "abc-123", query_url: "/v1/users?_limit=1", "annotated": "true"} {
+		if v := md.Get(key); len(v) != 1 || v[0] != expected {
+			t.Errorf("invalid metadata %s: %q - expected: %q", key, v, expected)
+		}
+	}
+}
+
+func TestNewServeMux_AccountIDHeader(t *testing.T) {
+	annotate := func(mux *runtime.ServeMux) metadata.MD {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		req.Header.Set("X-Account-ID", "forged")
+		req.Header.Set("Grpc-Metadata-X-Account-ID", "forged")
+		ctx, err := runtime.AnnotateContext(context.Background(), mux, req, "/app.Users/List")
+		if err != nil {
+			t.Fatalf("failed to annotate context: %v", err)
+		}
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return md
+	}
+
+	// a request without token does not reach the service with the account id
+	// set by the client
+	allowed := WithMuxIncomingHeaderMatcher(DefaultIncomingHeaderMatcher(WithAllowedHeaders(AccountIDHeader)))
+	for name, mux := range map[string]*runtime.ServeMux{
+		"default":                NewServeMux(),
+		"allowed by the matcher": NewServeMux(allowed),
+		"runtime matcher":        NewServeMux(WithMuxIncomingHeaderMatcher(runtime.DefaultHeaderMatcher)),
+	} {
+		if v := annotate(mux).Get("x-account-id"); len(v) != 0 {
+			t.Errorf("%s: unexpected account id metadata: %q", name, v)
+		}
+	}
+
+	if v := annotate(NewServeMux(allowed, WithMuxAccountIDHeader())).Get("x-account-id"); len(v) != 2 || v[0] != "forged" {
+		t.Errorf("invalid account id metadata: %q - expected: %q", v, []string{"forged", "forged"})
+	}
+}
+
+func TestNewServeMux_ErrorHandler(t *testing.T) {
+	fail := func(mux *runtime.ServeMux) runtime.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request, _ map[string]string) {
+			md := runtime.ServerMetadata{HeaderMD: metadata.Pairs("x-request-id", "abc-123", "x-internal", "secret")}
+			ctx := runtime.NewServerMetadataContext(req.Context(), md)
+			_, outbound := runtime.MarshalerForRequest(mux, req)
+			runtime.HTTPError(ctx, mux, outbound, rw, req, status.Error(codes.NotFound, "gone"))
+		}
+	}
+
+	mux := NewServeMux()
+	mux.HandlePath(http.MethodGet, "/fail", fail(mux))
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusNotFound)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Error.Code != "NOT_FOUND" || resp.Error.RequestID != "abc-123" {
+		t.Errorf("invalid error response: %+v", resp)
+	}
+	// the default outgoing header matcher only returns the allowed metadata
+	if h := rw.Header().Get("X-Request-Id"); h != "abc-123" {
+		t.Errorf("invalid request id header: %q - expected: %q", h, "abc-123")
+	}
+	if h := rw.Header().Get("Grpc-Metadata-X-Internal"); h != "" {
+		t.Errorf("unexpected header: %q", h)
+	}
+
+	// the error handler can be overridden
+	mux = NewServeMux(WithMuxErrorHandler(func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, rw http.ResponseWriter, req *http.Request, err error) {
+		rw.WriteHeader(http.StatusTeapot)
+	}))
+	mux.HandlePath(http.MethodGet, "/fail", fail(mux))
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	if rw.Code != http.StatusTeapot {
+		t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusTeapot)
+	}
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/armezit/atlas-app-toolkit/query"
+)
+
+const (
+	pageInfoNextPageTokenTrailerKey = "page-info-next-page-token"
+	pageInfoTotalSizeTrailerKey     = "page-info-total-size"
+	pageInfoOffsetTrailerKey        = "page-info-offset"
+)
+
+// PageInfo is the pagination of a list response, set as the page of the
+// ResponseEnvelope
+type PageInfo struct {
+	// NextPageToken is the token of the next page, empty when there are no
+	// more pages
+	NextPageToken string `json:"next_page_token,omitempty"`
+	// TotalSize is the total number of resources, when known
+	TotalSize int64 `json:"total_size,omitempty"`
+	// Offset is the offset of the next page with client-driven pagination
+	Offset int64 `json:"offset,omitempty"`
+}
+
+// SetPageInfoTrailer sets the pagination of a list response in the trailer
+// metadata, for the responses without a query.PageInfo field. Only the non-zero
+// values are set.
+func SetPageInfoTrailer(ctx context.Context, page PageInfo) error {
+	md := metadata.MD{}
+	if page.NextPageToken != "" {
+		md.Set(pageInfoNextPageTokenTrailerKey, page.NextPageToken)
+	}
+	if page.TotalSize != 0 {
+		md.Set(pageInfoTotalSizeTrailerKey, strconv.FormatInt(page.TotalSize, 10))
+	}
+	if page.Offset != 0 {
+		md.Set(pageInfoOffsetTrailerKey, strconv.FormatInt(page.Offset, 10))
+	}
+	if md.Len() == 0 {
+		return nil
+	}
+	return grpc.SetTrailer(ctx, md)
+}
+
+// PageInfoFromMetadata returns the pagination set by SetPageInfoTrailer, the
+// values that cannot be parsed are ignored
+func PageInfoFromMetadata(md runtime.ServerMetadata) (*PageInfo, bool) {
+	page := &PageInfo{}
+	if vals := md.TrailerMD.Get(pageInfoNextPageTokenTrailerKey); len(vals) > 0 {
+		page.NextPageToken = vals[0]
+	}
+	if vals := md.TrailerMD.Get(pageInfoTotalSizeTrailerKey); len(vals) > 0 {
+		page.TotalSize, _ = strconv.ParseInt(vals[0], 10, 64)
+	}
+	if vals := md.TrailerMD.Get(pageInfoOffsetTrailerKey); len(vals) > 0 {
+		page.Offset, _ = strconv.ParseInt(vals[0], 10, 64)
+	}
+	if *page == (PageInfo{}) {
+		return nil, false
+	}
+	return page, true
+}
+
+// pageInfoFromField returns the pagination of the query.PageInfo field of the
+// message
+func pageInfoFromField(resp protoreflect.ProtoMessage, fd protoreflect.FieldDescriptor) (*PageInfo, bool) {
+	msg := resp.ProtoReflect()
+	if !msg.Has(fd) {
+		return nil, false
+	}
+	// the field may be a dynamic message, e.g. with a registry of descriptors
+	data, err := proto.Marshal(msg.Get(fd).Message().Interface())
+	if err != nil {
+		return nil, false
+	}
+	pi := &query.PageInfo{}
+	if err := proto.Unmarshal(data, pi); err != nil {
+		return nil, false
+	}
+	page := &PageInfo{NextPageToken: pi.GetPageToken(), TotalSize: int64(pi.GetSize()), Offset: int64(pi.GetOffset())}
+	if *page == (PageInfo{}) {
+		return nil, false
+	}
+	return page, true
+}
@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// trailerTransportStream records the trailer set by the handlers
+type trailerTransportStream struct {
+	grpc.ServerTransportStream
+	trailer metadata.MD
+}
+
+func (s *trailerTransportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func TestSetPageInfoTrailer(t *testing.T) {
+	stream := &trailerTransportStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	expected := PageInfo{NextPageToken: "next", TotalSize: 42}
+	if err := SetPageInfoTrailer(ctx, expected); err != nil {
+		t.Fatalf("failed to set the page info: %v", err)
+	}
+
+	page, ok := PageInfoFromMetadata(runtime.ServerMetadata{TrailerMD: stream.trailer})
+	if !ok || *page != expected {
+		t.Errorf("invalid page info: %+v - expected: %+v", page, expected)
+	}
+	if page, ok := PageInfoFromMetadata(runtime.ServerMetadata{}); ok || page != nil {
+		t.Errorf("invalid page info without trailer: %+v", page)
+	}
+}
+
+func TestEnvelopeForwardResponseMessagePageTrailer(t *testing.T) {
+	list, _ := structpb.NewList([]interface{}{"a", "b"})
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		TrailerMD: metadata.Pairs(pageInfoNextPageTokenTrailerKey, "next", pageInfoTotalSizeTrailerKey, "2"),
+	})
+	rw := httptest.NewRecorder()
+	EnvelopeForwardResponseMessage(ctx, nil, &runtime.JSONPb{}, rw, nil, list)
+
+	v := &ResponseEnvelope{}
+	if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if v.Page == nil || *v.Page != (PageInfo{NextPageToken: "next", TotalSize: 2}) {
+		t.Errorf("invalid page: %+v", v.Page)
+	}
+}
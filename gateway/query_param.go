@@ -0,0 +1,35 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// NewQueryParamAnnotator returns an annotator storing the value of the query
+// parameter param in the metadata key of the gRPC context, for the requests
+// whose path starts with one of the given prefixes. No request is annotated
+// when no prefix is given.
+//
+// It is meant for the browser clients that cannot set headers, e.g. to pass
+// an access_token to auth.WithTokenMetadataKey. Restrict it to the routes
+// that need it, as query parameters end up in URLs and access logs.
+func NewQueryParamAnnotator(param, key string, pathPrefixes ...string) func(context.Context, *http.Request) metadata.MD {
+	return func(ctx context.Context, req *http.Request) metadata.MD {
+		if req == nil || req.URL == nil {
+			return nil
+		}
+		for _, prefix := range pathPrefixes {
+			if !strings.HasPrefix(req.URL.Path, prefix) {
+				continue
+			}
+			if value := req.URL.Query().Get(param); value != "" {
+				return metadata.Pairs(key, value)
+			}
+			return nil
+		}
+		return nil
+	}
+}
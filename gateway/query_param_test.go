@@ -0,0 +1,33 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNewQueryParamAnnotator(t *testing.T) {
+	annotator := NewQueryParamAnnotator("access_token", "query-token", "/v1/downloads/")
+
+	tests := map[string]metadata.MD{
+		"/v1/downloads/file?access_token=token": metadata.Pairs("query-token", "token"),
+		"/v1/downloads/file":                    nil,
+		"/v1/contacts?access_token=token":       nil,
+	}
+	for target, expected := range tests {
+		req, err := http.NewRequest(http.MethodGet, "http://app.com"+target, nil)
+		if err != nil {
+			t.Fatalf("unable to create request: %v", err)
+		}
+		if md := annotator(context.Background(), req); !reflect.DeepEqual(md, expected) {
+			t.Errorf("invalid metadata for %q: %v - expected %v", target, md, expected)
+		}
+	}
+
+	if md := NewQueryParamAnnotator("access_token", "query-token")(context.Background(), nil); md != nil {
+		t.Errorf("unexpected metadata: %v", md)
+	}
+}
@@ -29,6 +29,9 @@ type ResponseForwarder struct {
 	OutgoingHeaderMatcher runtime.HeaderMatcherFunc
 	MessageErrHandler     runtime.ErrorHandlerFunc
 	StreamErrHandler      ProtoStreamErrorHandlerFunc
+	// StatusMapper maps the status set by SetStatus to the HTTP status,
+	// HTTPStatusFromCode is used when nil
+	StatusMapper *StatusMapper
 }
 
 var (
@@ -48,13 +51,13 @@ func IncludeStatusDetails(withDetails bool) {
 
 // NewForwardResponseMessage returns ForwardResponseMessageFunc
 func NewForwardResponseMessage(out runtime.HeaderMatcherFunc, meh runtime.ErrorHandlerFunc, seh ProtoStreamErrorHandlerFunc) ForwardResponseMessageFunc {
-	fw := &ResponseForwarder{out, meh, seh}
+	fw := &ResponseForwarder{OutgoingHeaderMatcher: out, MessageErrHandler: meh, StreamErrHandler: seh}
 	return fw.ForwardMessage
 }
 
 // NewForwardResponseStream returns ForwardResponseStreamFunc
 func NewForwardResponseStream(out runtime.HeaderMatcherFunc, meh runtime.ErrorHandlerFunc, seh ProtoStreamErrorHandlerFunc) ForwardResponseStreamFunc {
-	fw := &ResponseForwarder{out, meh, seh}
+	fw := &ResponseForwarder{OutgoingHeaderMatcher: out, MessageErrHandler: meh, StreamErrHandler: seh}
 	return fw.ForwardStream
 }
 
@@ -97,7 +100,7 @@ func (fw *ResponseForwarder) ForwardMessage(ctx context.Context, mux *runtime.Se
 		fw.MessageErrHandler(ctx, mux, marshaler, rw, req, err)
 	}
 
-	httpStatus, statusStr := HTTPStatus(ctx, nil)
+	httpStatus, statusStr := fw.StatusMapper.restStatus(ctx, nil)
 
 	retainFields(ctx, req, dynmap)
 	errs, suc, _ := errorsAndSuccessFromContext(ctx)
@@ -164,10 +167,10 @@ func (fw *ResponseForwarder) ForwardStream(ctx context.Context, mux *runtime.Ser
 		return
 	}
 
-	httpStatus, _ := HTTPStatus(ctx, nil)
+	httpStatus, _ := fw.StatusMapper.restStatus(ctx, nil)
 	// if user did not set status explicitly
 	if httpStatus == http.StatusOK {
-		httpStatus = HTTPStatusFromCode(PartialContent)
+		httpStatus = fw.StatusMapper.HTTPStatus(PartialContent)
 	}
 
 	rw.WriteHeader(httpStatus)
@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	rpcdetails "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const retryAfterHeader = "Retry-After"
+
+// NewRetryAfterError returns an error with a rpcdetails.RetryInfo detail, e.g.
+// with codes.ResourceExhausted for rate limiting, that the error handlers of
+// this package translate into a Retry-After header of the response.
+func NewRetryAfterError(c codes.Code, msg string, delay time.Duration) error {
+	st, err := status.New(c, msg).WithDetails(&rpcdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return status.Error(c, msg)
+	}
+	return st.Err()
+}
+
+// RetryDelay returns the delay of the rpcdetails.RetryInfo detail of the status
+func RetryDelay(st *status.Status) (time.Duration, bool) {
+	for _, d := range st.Details() {
+		if ri, ok := d.(*rpcdetails.RetryInfo); ok && ri.GetRetryDelay() != nil {
+			return ri.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
+
+// setRetryAfter sets the Retry-After header, in seconds rounded up, when the
+// status has a retry delay
+func setRetryAfter(rw http.ResponseWriter, st *status.Status) {
+	if delay, ok := RetryDelay(st); ok && delay >= 0 {
+		rw.Header().Set(retryAfterHeader, strconv.FormatInt(int64(math.Ceil(delay.Seconds())), 10))
+	}
+}
+
+func isRetryInfo(d interface{}) bool {
+	_, ok := d.(*rpcdetails.RetryInfo)
+	return ok
+}
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryAfterHeader(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err        error
+		retryAfter string
+	}{
+		"rate limited":   {err: NewRetryAfterError(codes.ResourceExhausted, "too many requests", 1500*time.Millisecond), retryAfter: "2"},
+		"no retry delay": {err: status.Error(codes.ResourceExhausted, "too many requests")},
+		"not a status":   {err: errors.New("failed")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for handler, h := range map[string]runtime.ErrorHandlerFunc{
+				"error handler":       NewErrorHandler(),
+				"proto error handler": ProtoMessageErrorHandler,
+			} {
+				rw := httptest.NewRecorder()
+				h(context.Background(), nil, &runtime.JSONPb{}, rw, nil, tc.err)
+
+				if actual := rw.Header().Get("Retry-After"); actual != tc.retryAfter {
+					t.Errorf("%s: invalid Retry-After header: %q - expected: %q", handler, actual, tc.retryAfter)
+				}
+				if st, _ := status.FromError(tc.err); st.Code() == codes.ResourceExhausted && rw.Code != http.StatusTooManyRequests {
+					t.Errorf("%s: invalid http status code: %d - expected: %d", handler, rw.Code, http.StatusTooManyRequests)
+				}
+			}
+		})
+	}
+}
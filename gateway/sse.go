@@ -0,0 +1,203 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// DefaultSSEEvent is the default event name of the streamed messages, the
+	// one handled by EventSource.onmessage
+	DefaultSSEEvent = "message"
+
+	// sseErrorEvent is the event name of the error ending the stream
+	sseErrorEvent = "error"
+)
+
+// SSEOption is a type of function that alters the configuration of the
+// forwarder returned by NewSSEForwardResponseStream
+type SSEOption func(*sseForwarder)
+
+// WithSSEEvent sets the event name of the streamed messages. Defaults to
+// DefaultSSEEvent
+func WithSSEEvent(event string) SSEOption {
+	return func(fw *sseForwarder) {
+		fw.event = event
+	}
+}
+
+// WithSSERequestIDKey sets the metadata key and HTTP header the request id is
+// read from. Defaults to the key set by SetRequestIDKey
+func WithSSERequestIDKey(key string) SSEOption {
+	return func(fw *sseForwarder) {
+		fw.requestIDKey = key
+	}
+}
+
+// WithSSEHeaderMatcher sets the matcher of the response metadata forwarded
+// as HTTP headers. Defaults to PrefixOutgoingHeaderMatcher
+func WithSSEHeaderMatcher(matcher runtime.HeaderMatcherFunc) SSEOption {
+	return func(fw *sseForwarder) {
+		fw.outgoingHeaderMatcher = matcher
+	}
+}
+
+// WithSSEErrorHandler sets the handler of the errors occurring before the
+// stream is started. Defaults to the handler returned by NewErrorHandler
+func WithSSEErrorHandler(handler runtime.ErrorHandlerFunc) SSEOption {
+	return func(fw *sseForwarder) {
+		fw.errHandler = handler
+	}
+}
+
+type sseForwarder struct {
+	event                 string
+	requestIDKey          string
+	outgoingHeaderMatcher runtime.HeaderMatcherFunc
+	errHandler            runtime.ErrorHandlerFunc
+}
+
+// SSEForwardResponseStream is NewSSEForwardResponseStream with the default
+// options
+var SSEForwardResponseStream = NewSSEForwardResponseStream()
+
+// NewSSEForwardResponseStream returns a ForwardResponseStreamFunc that writes
+// the streamed messages as Server-Sent Events, for the browsers. It is opt-in
+// per method, by overriding the forwarder of the generated code, e.g.
+//
+//	forward_Events_Watch_0 = gateway.SSEForwardResponseStream
+//
+// The stream starts with a comment holding the request id, then every message
+// is written and flushed as a frame of the event set by WithSSEEvent whose
+// data is the message marshaled by the marshaler. An error ending the stream
+// is written as an error event holding an ErrorResponse. The stream ends
+// without error event when the client disconnects, which cancels the gRPC
+// stream.
+func NewSSEForwardResponseStream(opts ...SSEOption) ForwardResponseStreamFunc {
+	fw := &sseForwarder{
+		event:                 DefaultSSEEvent,
+		outgoingHeaderMatcher: PrefixOutgoingHeaderMatcher,
+		errHandler:            NewErrorHandler(),
+	}
+	for _, opt := range opts {
+		opt(fw)
+	}
+	return fw.forward
+}
+
+func (fw *sseForwarder) forward(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, rw http.ResponseWriter, req *http.Request, recv func() (protoreflect.ProtoMessage, error), opts ...func(context.Context, http.ResponseWriter, protoreflect.ProtoMessage) error) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		grpclog.Infof("forward response sse: flush not supported in %T", rw)
+		fw.errHandler(ctx, mux, marshaler, rw, req, status.Error(codes.Internal, "forward response sse: internal error"))
+		return
+	}
+
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		grpclog.Infof("forward response sse: failed to extract ServerMetadata from context")
+	}
+	handleForwardResponseServerMetadata(fw.outgoingHeaderMatcher, rw, md)
+
+	if err := handleForwardResponseOptions(ctx, rw, nil, opts); err != nil {
+		fw.errHandler(ctx, mux, marshaler, rw, req, err)
+		return
+	}
+
+	requestID := requestIDFromMetadata(md, req, fw.requestIDKey)
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	if requestID != "" {
+		if _, err := fmt.Fprintf(rw, ": request-id %s\n\n", sseLine(requestID)); err != nil {
+			grpclog.Infof("forward response sse: failed to write request id: %v", err)
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		resp, err := recv()
+		if err == io.EOF {
+			return
+		}
+		if err == nil {
+			err = handleForwardResponseOptions(ctx, rw, resp, opts)
+		}
+		if err != nil {
+			fw.writeError(ctx, marshaler, rw, err, requestID)
+			flusher.Flush()
+			return
+		}
+
+		data, err := marshaler.Marshal(resp)
+		if err != nil {
+			grpclog.Infof("forward response sse: failed to marshal response: %v", err)
+			fw.writeError(ctx, marshaler, rw, status.Error(codes.Internal, "forward response sse: internal error"), requestID)
+			flusher.Flush()
+			return
+		}
+		if err := writeSSEFrame(rw, fw.event, data); err != nil {
+			// the client is gone, the canceled context ends the gRPC stream
+			grpclog.Infof("forward response sse: failed to write response: %v", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeError writes the error event ending the stream, unless the client
+// disconnected
+func (fw *sseForwarder) writeError(ctx context.Context, marshaler runtime.Marshaler, rw http.ResponseWriter, err error, requestID string) {
+	if ctx.Err() != nil {
+		return
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.New(codes.Unknown, err.Error())
+	}
+	data, merr := marshaler.Marshal(newErrorResponse(st, requestID))
+	if merr != nil {
+		grpclog.Infof("forward response sse: failed to marshal error response: %v", merr)
+		return
+	}
+	if err := writeSSEFrame(rw, sseErrorEvent, data); err != nil {
+		grpclog.Infof("forward response sse: failed to write error response: %v", err)
+	}
+}
+
+// writeSSEFrame writes the event with one data field per line of the data
+func writeSSEFrame(w io.Writer, event string, data []byte) error {
+	var buf bytes.Buffer
+	buf.WriteString("event: ")
+	buf.WriteString(sseLine(event))
+	buf.WriteByte('\n')
+	for _, line := range bytes.Split(bytes.TrimRight(data, "\r\n"), []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimRight(line, "\r"))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// sseLine drops the line breaks that would end a field
+func sseLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	gateway_test "github.com/armezit/atlas-app-toolkit/gateway/internal"
+)
+
+// sseRecv returns the items then the error
+func sseRecv(err error, items ...protoreflect.ProtoMessage) func() (protoreflect.ProtoMessage, error) {
+	return func() (protoreflect.ProtoMessage, error) {
+		if len(items) == 0 {
+			return nil, err
+		}
+		item := items[0]
+		items = items[1:]
+		return item, nil
+	}
+}
+
+// sseEvent is a parsed Server-Sent Event
+type sseEvent struct {
+	event string
+	data  string
+}
+
+func parseSSE(t *testing.T, body string) (comments []string, events []sseEvent) {
+	for _, frame := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		var ev sseEvent
+		var data []string
+		for _, line := range strings.Split(frame, "\n") {
+			switch {
+			case strings.HasPrefix(line, ": "):
+				comments = append(comments, strings.TrimPrefix(line, ": "))
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = append(data, strings.TrimPrefix(line, "data: "))
+			default:
+				t.Errorf("invalid SSE line: %q", line)
+			}
+		}
+		if ev.event != "" {
+			ev.data = strings.Join(data, "\n")
+			events = append(events, ev)
+		}
+	}
+	return comments, events
+}
+
+func TestSSEForwardResponseStream(t *testing.T) {
+	md := runtime.ServerMetadata{HeaderMD: metadata.Pairs("x-request-id", "abc-123")}
+	ctx := runtime.NewServerMetadataContext(context.Background(), md)
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+
+	recv := sseRecv(io.EOF, &gateway_test.User{Name: "Poe", Age: 209}, &gateway_test.User{Name: "Hemingway", Age: 119})
+	SSEForwardResponseStream(ctx, nil, &runtime.JSONBuiltin{}, rw, req, recv)
+
+	if rw.Code != http.StatusOK {
+		t.Errorf("invalid http status code: %d - expected: %d", rw.Code, http.StatusOK)
+	}
+	if ct := rw.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("invalid content-type: %s - expected: %s", ct, "text/event-stream")
+	}
+	if !rw.Flushed {
+		t.Errorf("the stream must be flushed")
+	}
+
+	comments, events := parseSSE(t, rw.Body.String())
+	if len(comments) != 1 || comments[0] != "request-id abc-123" {
+		t.Errorf("invalid comments: %q - expected: %q", comments, []string{"request-id abc-123"})
+	}
+	if len(events) != 2 {
+		t.Fatalf("invalid number of events: %d - expected: %d", len(events), 2)
+	}
+	for i, name := range []string{"Poe", "Hemingway"} {
+		var u gateway_test.User
+		if err := json.Unmarshal([]byte(events[i].data), &u); err != nil {
+			t.Fatalf("failed to unmarshal event data %q: %v", events[i].data, err)
+		}
+		if events[i].event != DefaultSSEEvent || u.Name != name {
+			t.Errorf("invalid event: %s %+v - expected: %s %s", events[i].event, &u, DefaultSSEEvent, name)
+		}
+	}
+}
+
+func TestSSEForwardResponseStreamError(t *testing.T) {
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+
+	recv := sseRecv(status.Error(codes.NotFound, "gone"), &gateway_test.User{Name: "Poe"})
+	NewSSEForwardResponseStream(WithSSEEvent("user"))(ctx, nil, &runtime.JSONBuiltin{}, rw, req, recv)
+
+	_, events := parseSSE(t, rw.Body.String())
+	if len(events) != 2 {
+		t.Fatalf("invalid number of events: %d - expected: %d", len(events), 2)
+	}
+	if events[0].event != "user" {
+		t.Errorf("invalid event: %s - expected: %s", events[0].event, "user")
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal([]byte(events[1].data), &resp); err != nil {
+		t.Fatalf("failed to unmarshal error data %q: %v", events[1].data, err)
+	}
+	if events[1].event != "error" || resp.Error.Code != "NOT_FOUND" || resp.Error.Message != "gone" || resp.Error.RequestID != "abc-123" {
+		t.Errorf("invalid error event: %s %+v", events[1].event, resp)
+	}
+}
+
+func TestSSEForwardResponseStreamDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{}))
+	rw := httptest.NewRecorder()
+
+	// the client disconnects after the first message, which cancels the stream
+	var calls int
+	recv := func() (protoreflect.ProtoMessage, error) {
+		calls++
+		if calls == 1 {
+			return &gateway_test.User{Name: "Poe"}, nil
+		}
+		cancel()
+		return nil, status.Error(codes.Canceled, context.Canceled.Error())
+	}
+	SSEForwardResponseStream(ctx, nil, &runtime.JSONBuiltin{}, rw, httptest.NewRequest(http.MethodGet, "/", nil), recv)
+
+	if calls != 2 {
+		t.Errorf("invalid number of receives: %d - expected: %d", calls, 2)
+	}
+	if _, events := parseSSE(t, rw.Body.String()); len(events) != 1 {
+		t.Errorf("invalid number of events: %d - expected: %d", len(events), 1)
+	}
+}
+
+func TestWriteSSEFrame(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSSEFrame(&buf, "message", []byte("{\n  \"name\": \"Poe\"\r\n}\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "event: message\ndata: {\ndata:   \"name\": \"Poe\"\ndata: }\n\n"
+	if buf.String() != expected {
+		t.Errorf("invalid frame: %q - expected: %q", buf.String(), expected)
+	}
+}
@@ -76,19 +76,7 @@ func SetRunning(ctx context.Context, message, resource string) error {
 // `grpcgateway-status-code` from gRPC metadata.
 // If `grpcgateway-status-code` is not set it is assumed that it is OK.
 func HTTPStatus(ctx context.Context, st *status.Status) (int, string) {
-
-	if st != nil {
-		httpStatus := HTTPStatusFromCode(st.Code())
-
-		return httpStatus, CodeName(st.Code())
-	}
-	statusName := CodeName(codes.OK)
-	if sc, ok := Header(ctx, "status-code"); ok {
-		statusName = sc
-	}
-	httpCode := HTTPStatusFromCode(Code(statusName))
-
-	return httpCode, statusName
+	return (*StatusMapper)(nil).restStatus(ctx, st)
 }
 
 // CodeName returns stringname of gRPC code, function handles as standard
@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusMapper maps the gRPC codes to HTTP statuses with HTTPStatusFromCode,
+// unless overridden by WithStatusMapping. It is consumed by the error
+// handlers and the response forwarders of this package, a nil StatusMapper
+// uses the HTTPStatusFromCode mapping.
+type StatusMapper struct {
+	statuses map[codes.Code]int
+}
+
+// StatusMapperOption is a type of function that alters the mapping of a
+// StatusMapper
+type StatusMapperOption func(*StatusMapper)
+
+// WithStatusMapping maps the gRPC code to the HTTP status, e.g.
+// WithStatusMapping(codes.FailedPrecondition, http.StatusConflict)
+func WithStatusMapping(code codes.Code, httpStatus int) StatusMapperOption {
+	return func(m *StatusMapper) {
+		m.statuses[code] = httpStatus
+	}
+}
+
+// NewStatusMapper returns a StatusMapper with the given overrides of the
+// HTTPStatusFromCode mapping
+func NewStatusMapper(opts ...StatusMapperOption) *StatusMapper {
+	m := &StatusMapper{statuses: map[codes.Code]int{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// HTTPStatus returns the HTTP status the gRPC code is mapped to
+func (m *StatusMapper) HTTPStatus(code codes.Code) int {
+	if m != nil {
+		if httpStatus, ok := m.statuses[code]; ok {
+			return httpStatus
+		}
+	}
+	return HTTPStatusFromCode(code)
+}
+
+// restStatus is HTTPStatus with the mapping of m
+func (m *StatusMapper) restStatus(ctx context.Context, st *status.Status) (int, string) {
+	if st != nil {
+		return m.HTTPStatus(st.Code()), CodeName(st.Code())
+	}
+	statusName := CodeName(codes.OK)
+	if sc, ok := Header(ctx, "status-code"); ok {
+		statusName = sc
+	}
+	return m.HTTPStatus(Code(statusName)), statusName
+}
@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestStatusMapper(t *testing.T) {
+	mapper := NewStatusMapper(
+		WithStatusMapping(codes.FailedPrecondition, http.StatusConflict),
+		WithStatusMapping(codes.Canceled, http.StatusRequestTimeout),
+	)
+	for code, expected := range map[codes.Code][2]int{
+		// the default and the overridden statuses
+		codes.FailedPrecondition: {http.StatusBadRequest, http.StatusConflict},
+		codes.Canceled:           {499, http.StatusRequestTimeout},
+		codes.Aborted:            {http.StatusConflict, http.StatusConflict},
+		codes.OutOfRange:         {http.StatusBadRequest, http.StatusBadRequest},
+		codes.ResourceExhausted:  {http.StatusTooManyRequests, http.StatusTooManyRequests},
+		codes.DeadlineExceeded:   {http.StatusGatewayTimeout, http.StatusGatewayTimeout},
+		codes.Unauthenticated:    {http.StatusUnauthorized, http.StatusUnauthorized},
+		codes.PermissionDenied:   {http.StatusForbidden, http.StatusForbidden},
+		codes.Unimplemented:      {http.StatusNotImplemented, http.StatusNotImplemented},
+	} {
+		if actual := (*StatusMapper)(nil).HTTPStatus(code); actual != expected[0] {
+			t.Errorf("invalid default http status of %s: %d - expected: %d", code, actual, expected[0])
+		}
+		if actual := mapper.HTTPStatus(code); actual != expected[1] {
+			t.Errorf("invalid http status of %s: %d - expected: %d", code, actual, expected[1])
+		}
+	}
+}
+
+func TestStatusMapperConsumers(t *testing.T) {
+	mapper := NewStatusMapper(WithStatusMapping(codes.FailedPrecondition, http.StatusConflict))
+	err := status.Error(codes.FailedPrecondition, "contact is locked")
+
+	rw := httptest.NewRecorder()
+	NewErrorHandler(WithErrorStatusMapper(mapper))(context.Background(), nil, &runtime.JSONPb{}, rw, nil, err)
+	if rw.Code != http.StatusConflict {
+		t.Errorf("invalid error handler http status: %d - expected: %d", rw.Code, http.StatusConflict)
+	}
+
+	rw = httptest.NewRecorder()
+	h := &ProtoErrorHandler{OutgoingHeaderMatcher: PrefixOutgoingHeaderMatcher, StatusMapper: mapper}
+	h.MessageHandler(context.Background(), nil, &runtime.JSONBuiltin{}, rw, nil, err)
+	if rw.Code != http.StatusConflict {
+		t.Errorf("invalid proto error handler http status: %d - expected: %d", rw.Code, http.StatusConflict)
+	}
+
+	// the status set via SetStatus is mapped by the forwarders
+	md := runtime.ServerMetadata{HeaderMD: metadata.Pairs("status-code", CodeName(Created))}
+	ctx := metadata.NewIncomingContext(runtime.NewServerMetadataContext(context.Background(), md), md.HeaderMD)
+	createdMapper := NewStatusMapper(WithStatusMapping(Created, http.StatusOK))
+	item, _ := structpb.NewStruct(map[string]interface{}{"name": "contact"})
+
+	rw = httptest.NewRecorder()
+	NewEnvelopeForwardResponseMessage(WithEnvelopeStatusMapper(createdMapper))(ctx, nil, &runtime.JSONPb{}, rw, nil, item)
+	if rw.Code != http.StatusOK {
+		t.Errorf("invalid envelope http status: %d - expected: %d", rw.Code, http.StatusOK)
+	}
+
+	rw = httptest.NewRecorder()
+	fw := &ResponseForwarder{OutgoingHeaderMatcher: PrefixOutgoingHeaderMatcher, MessageErrHandler: ProtoMessageErrorHandler, StatusMapper: createdMapper}
+	fw.ForwardMessage(ctx, nil, &runtime.JSONPb{}, rw, nil, item)
+	if rw.Code != http.StatusOK {
+		t.Errorf("invalid forwarder http status: %d - expected: %d", rw.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// RequestTimeoutHeader is the header a client sets its timeout with, as a
+	// duration such as "1.5s" or "500ms", or a number of seconds
+	RequestTimeoutHeader = "X-Request-Timeout"
+
+	// grpcTimeoutHeader is the gRPC timeout header, e.g. "5S" or "100m"
+	grpcTimeoutHeader = "Grpc-Timeout"
+)
+
+// TimeoutHandler returns a handler calling next with a context deadline set
+// to the timeout requested by the client in the Grpc-Timeout or the
+// X-Request-Timeout header, clamped to maxTimeout. Requests with no header,
+// or with a value that is not a positive duration, get defaultTimeout. A zero
+// defaultTimeout sets no deadline and a zero maxTimeout does not clamp.
+//
+// The deadline is propagated to the gRPC call by the gateway ServeMux, and
+// logged by the logging gateway interceptors as grpc.request.deadline. It is
+// a handler rather than a ServeMuxOption since the annotators of the ServeMux
+// cannot alter the context of the call.
+func TimeoutHandler(next http.Handler, defaultTimeout, maxTimeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		timeout := requestTimeout(req.Header, defaultTimeout)
+		if maxTimeout > 0 && timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+		// the ServeMux fails the requests with an invalid Grpc-Timeout and would
+		// not clamp a valid one
+		if req.Header.Get(grpcTimeoutHeader) != "" {
+			req = req.Clone(req.Context())
+			req.Header.Del(grpcTimeoutHeader)
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+			req = req.WithContext(ctx)
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// requestTimeout returns the timeout of the Grpc-Timeout header, or else of
+// the X-Request-Timeout header, or def when there is none or it is invalid
+func requestTimeout(h http.Header, def time.Duration) time.Duration {
+	var (
+		timeout time.Duration
+		ok      bool
+	)
+	if v := h.Get(grpcTimeoutHeader); v != "" {
+		timeout, ok = parseGRPCTimeout(v)
+	} else if v := h.Get(RequestTimeoutHeader); v != "" {
+		timeout, ok = parseRequestTimeout(v)
+	}
+	if !ok || timeout <= 0 {
+		return def
+	}
+	return timeout
+}
+
+// parseGRPCTimeout parses a timeout of the gRPC wire format, i.e. at most
+// eight digits followed by a unit
+func parseGRPCTimeout(v string) (time.Duration, bool) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+func parseRequestTimeout(v string) (time.Duration, bool) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, true
+	}
+	// a timeout of one year or more is not plausible and could overflow
+	if n, err := strconv.ParseFloat(v, 64); err == nil && n < 365*24*3600 {
+		return time.Duration(n * float64(time.Second)), true
+	}
+	return 0, false
+}
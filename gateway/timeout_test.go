@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutHandler(t *testing.T) {
+	for name, tc := range map[string]struct {
+		header   http.Header
+		def      time.Duration
+		max      time.Duration
+		expected time.Duration
+	}{
+		"no header":              {def: 5 * time.Second, expected: 5 * time.Second},
+		"no header nor default":  {},
+		"grpc timeout":           {header: http.Header{"Grpc-Timeout": {"2S"}}, def: 5 * time.Second, expected: 2 * time.Second},
+		"request timeout":        {header: http.Header{"X-Request-Timeout": {"1500ms"}}, def: 5 * time.Second, expected: 1500 * time.Millisecond},
+		"request timeout secs":   {header: http.Header{"X-Request-Timeout": {"3"}}, def: 5 * time.Second, expected: 3 * time.Second},
+		"grpc timeout wins":      {header: http.Header{"Grpc-Timeout": {"2S"}, "X-Request-Timeout": {"3s"}}, expected: 2 * time.Second},
+		"clamped":                {header: http.Header{"X-Request-Timeout": {"1h"}}, def: 5 * time.Second, max: 10 * time.Second, expected: 10 * time.Second},
+		"default clamped":        {def: time.Minute, max: 10 * time.Second, expected: 10 * time.Second},
+		"unparseable":            {header: http.Header{"X-Request-Timeout": {"soon"}}, def: 5 * time.Second, expected: 5 * time.Second},
+		"invalid grpc timeout":   {header: http.Header{"Grpc-Timeout": {"2s"}}, def: 5 * time.Second, expected: 5 * time.Second},
+		"negative":               {header: http.Header{"X-Request-Timeout": {"-2s"}}, def: 5 * time.Second, expected: 5 * time.Second},
+		"zero":                   {header: http.Header{"Grpc-Timeout": {"0S"}}, def: 5 * time.Second, expected: 5 * time.Second},
+		"too many grpc digits":   {header: http.Header{"Grpc-Timeout": {"123456789S"}}, def: 5 * time.Second, expected: 5 * time.Second},
+		"implausible seconds":    {header: http.Header{"X-Request-Timeout": {"1e30"}}, def: 5 * time.Second, expected: 5 * time.Second},
+		"not a number of second": {header: http.Header{"X-Request-Timeout": {"NaN"}}, def: 5 * time.Second, expected: 5 * time.Second},
+	} {
+		var (
+			deadline time.Time
+			ok       bool
+			grpcTm   string
+		)
+		h := TimeoutHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			deadline, ok = req.Context().Deadline()
+			grpcTm = req.Header.Get("Grpc-Timeout")
+		}), tc.def, tc.max)
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/items", nil)
+		for k, v := range tc.header {
+			req.Header[k] = v
+		}
+		start := time.Now()
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if grpcTm != "" {
+			t.Errorf("%s: unexpected Grpc-Timeout header %q", name, grpcTm)
+		}
+		if tc.expected == 0 {
+			if ok {
+				t.Errorf("%s: unexpected deadline %v", name, deadline)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: no deadline - expected %v", name, tc.expected)
+			continue
+		}
+		if d := deadline.Sub(start); d < tc.expected || d > tc.expected+time.Second {
+			t.Errorf("%s: invalid timeout %v - expected %v", name, d, tc.expected)
+		}
+	}
+}
@@ -29,3 +29,11 @@ func StandardTestingContext() (context.Context, error) {
 	}
 	return AppendTokenToOutgoingContext(context.Background(), auth.DefaultTokenType, token), nil
 }
+
+// ContextWithAccountID returns a context holding the account id the same way
+// the auth tenancy interceptors store it, so that handlers calling
+// auth.AccountIDFromContext can be unit tested without a token. It is intended
+// specifically for gRPC testing.
+func ContextWithAccountID(ctx context.Context, accountID string) context.Context {
+	return auth.NewContextWithAccountID(ctx, accountID)
+}
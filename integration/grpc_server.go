@@ -0,0 +1,136 @@
+package integration
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_logrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	"github.com/armezit/atlas-app-toolkit/requestid"
+)
+
+// testServerBufferSize is the size of the in-memory connection buffers
+const testServerBufferSize = 1024 * 1024
+
+// TestServerOption is a type of function that alters the configuration of the
+// server started by NewTestServer
+type TestServerOption func(*testServer)
+
+type testServer struct {
+	logger      *logrus.Logger
+	requestID   bool
+	accountID   bool
+	registrars  []func(*grpc.Server)
+	unary       []grpc.UnaryServerInterceptor
+	stream      []grpc.StreamServerInterceptor
+	dialOptions []grpc.DialOption
+}
+
+// WithTestServerLogger sets the logger the calls are logged with, e.g. one
+// writing to a buffer so that tests can assert on the logged fields. The logs
+// are discarded by default
+func WithTestServerLogger(logger *logrus.Logger) TestServerOption {
+	return func(s *testServer) {
+		s.logger = logger
+	}
+}
+
+// WithTestServerRequestID enables or disables the request-id interceptors,
+// they are enabled by default
+func WithTestServerRequestID(enabled bool) TestServerOption {
+	return func(s *testServer) {
+		s.requestID = enabled
+	}
+}
+
+// WithTestServerAccountID enables or disables the interceptors logging the
+// account id of the token, they are enabled by default
+func WithTestServerAccountID(enabled bool) TestServerOption {
+	return func(s *testServer) {
+		s.accountID = enabled
+	}
+}
+
+// WithTestServerRegistrar registers the services under test on the server,
+// e.g. func(s *grpc.Server) { pb.RegisterContactsServer(s, srv) }
+func WithTestServerRegistrar(registrar func(*grpc.Server)) TestServerOption {
+	return func(s *testServer) {
+		s.registrars = append(s.registrars, registrar)
+	}
+}
+
+// WithTestServerInterceptors appends interceptors to the toolkit ones, e.g.
+// auth.TenancyInterceptor
+func WithTestServerInterceptors(unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) TestServerOption {
+	return func(s *testServer) {
+		s.unary = append(s.unary, unary...)
+		s.stream = append(s.stream, stream...)
+	}
+}
+
+// WithTestServerDialOptions sets additional options the client connection is
+// dialed with
+func WithTestServerDialOptions(opts ...grpc.DialOption) TestServerOption {
+	return func(s *testServer) {
+		s.dialOptions = append(s.dialOptions, opts...)
+	}
+}
+
+// NewTestServer starts a gRPC server on an in-memory listener with the
+// toolkit logging, request-id and account-id interceptors, and returns a
+// client connection dialed to it. The returned function closes the connection
+// and stops the server. It is intended specifically for gRPC testing.
+func NewTestServer(opts ...TestServerOption) (*grpc.ClientConn, func(), error) {
+	s := &testServer{requestID: true, accountID: true}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.logger == nil {
+		s.logger = logrus.New()
+		s.logger.Out = ioutil.Discard
+	}
+	entry := logrus.NewEntry(s.logger)
+
+	unary := []grpc.UnaryServerInterceptor{grpc_logrus.UnaryServerInterceptor(entry)}
+	stream := []grpc.StreamServerInterceptor{grpc_logrus.StreamServerInterceptor(entry)}
+	if s.requestID {
+		unary = append(unary, requestid.UnaryServerInterceptor())
+		stream = append(stream, requestid.StreamServerInterceptor())
+	}
+	if s.accountID {
+		unary = append(unary, auth.LogrusUnaryServerInterceptor())
+		stream = append(stream, auth.LogrusStreamServerInterceptor())
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(append(unary, s.unary...)...)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(append(stream, s.stream...)...)),
+	)
+	for _, register := range s.registrars {
+		register(server)
+	}
+
+	listener := bufconn.Listen(testServerBufferSize)
+	go server.Serve(listener)
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}
+	conn, err := grpc.Dial("bufnet", append([]grpc.DialOption{
+		grpc.WithContextDialer(dialer),
+		grpc.WithInsecure(),
+	}, s.dialOptions...)...)
+	if err != nil {
+		server.Stop()
+		return nil, nil, err
+	}
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}, nil
+}
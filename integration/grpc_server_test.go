@@ -0,0 +1,96 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	"github.com/armezit/atlas-app-toolkit/requestid"
+)
+
+func TestNewTestServer(t *testing.T) {
+	var tests = []struct {
+		name      string
+		opts      []TestServerOption
+		requestID interface{}
+		accountID interface{}
+	}{
+		{"default interceptors", nil, "test-request-id", "TestAccount"},
+		{"without request id", []TestServerOption{WithTestServerRequestID(false)}, nil, "TestAccount"},
+		{"without account id", []TestServerOption{WithTestServerAccountID(false)}, "test-request-id", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			logger := logrus.New()
+			logger.Out = out
+			logger.Formatter = &logrus.JSONFormatter{}
+
+			opts := append([]TestServerOption{
+				WithTestServerLogger(logger),
+				WithTestServerRegistrar(func(s *grpc.Server) {
+					healthpb.RegisterHealthServer(s, health.NewServer())
+				}),
+			}, test.opts...)
+			conn, cleanup, err := NewTestServer(opts...)
+			if err != nil {
+				t.Fatalf("unable to start test server: %v", err)
+			}
+			defer cleanup()
+
+			ctx, err := StandardTestingContext()
+			if err != nil {
+				t.Fatalf("unable to build test grpc context: %v", err)
+			}
+			ctx = metadata.AppendToOutgoingContext(ctx, requestid.DefaultRequestIDKey, "test-request-id")
+			if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+				t.Fatalf("unexpected error when calling test server: %v", err)
+			}
+			cleanup()
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+				t.Fatalf("unable to decode log entry %q: %v", out.String(), err)
+			}
+			if entry[requestid.RequestIDLogKey] != test.requestID {
+				t.Errorf("unexpected request id: have %v, expected %v", entry[requestid.RequestIDLogKey], test.requestID)
+			}
+			if entry[auth.MultiTenancyField] != test.accountID {
+				t.Errorf("unexpected account id: have %v, expected %v", entry[auth.MultiTenancyField], test.accountID)
+			}
+		})
+	}
+}
+
+func TestNewTestServer_Interceptors(t *testing.T) {
+	var called bool
+	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		called = true
+		return handler(ctx, req)
+	}
+	conn, cleanup, err := NewTestServer(
+		WithTestServerInterceptors([]grpc.UnaryServerInterceptor{interceptor}, nil),
+		WithTestServerRegistrar(func(s *grpc.Server) {
+			healthpb.RegisterHealthServer(s, health.NewServer())
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unable to start test server: %v", err)
+	}
+	defer cleanup()
+
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("unexpected error when calling test server: %v", err)
+	}
+	if !called {
+		t.Error("expected the interceptor to be called")
+	}
+}
@@ -74,3 +74,14 @@ func TestStandardTestingContext(t *testing.T) {
 		t.Fatalf("context does not contain token in metadata")
 	}
 }
+
+func TestContextWithAccountID(t *testing.T) {
+	ctx := ContextWithAccountID(context.Background(), "test-account")
+	accountID, ok := auth.AccountIDFromContext(ctx)
+	if !ok {
+		t.Fatal("unable to get account id from context")
+	}
+	if accountID != "test-account" {
+		t.Fatalf("unexpected account id: %q", accountID)
+	}
+}
@@ -0,0 +1,93 @@
+package integration
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// testRSAKeyBits is the size of the generated test keys
+const testRSAKeyBits = 2048
+
+// TestRSAKey is a throwaway RSA key pair identified by the kid header of the
+// tokens it signs
+type TestRSAKey struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+}
+
+// NewTestRSAKey generates a RSA key pair with the given key id
+func NewTestRSAKey(kid string) (*TestRSAKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, testRSAKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return &TestRSAKey{ID: kid, PrivateKey: key}, nil
+}
+
+// MakeJWT generates a RS256 token string based on the given JWT claims, the
+// kid header is set to the key id
+func (k *TestRSAKey) MakeJWT(claims jwt.Claims) (string, error) {
+	return makeTestJWTRS256(claims, k.PrivateKey, k.ID)
+}
+
+// MakeTestJWTRS256 generates a RS256 token string based on the given JWT
+// claims, without kid header
+func MakeTestJWTRS256(claims jwt.Claims, key *rsa.PrivateKey) (string, error) {
+	return makeTestJWTRS256(claims, key, "")
+}
+
+func makeTestJWTRS256(claims jwt.Claims, key *rsa.PrivateKey, kid string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString(key)
+}
+
+// TestJWKS returns the JWKS JSON document publishing the public keys of the
+// given key pairs, e.g. to be served by a httptest.Server
+func TestJWKS(keys ...*TestRSAKey) ([]byte, error) {
+	set := make([]map[string]string, 0, len(keys))
+	for _, k := range keys {
+		set = append(set, jsonWebKey(k.ID, &k.PrivateKey.PublicKey))
+	}
+	return json.Marshal(map[string]interface{}{"keys": set})
+}
+
+// JWKSKeyID returns the stable key id the JWKSServer publishes the key under,
+// which is its RFC 7638 thumbprint
+func JWKSKeyID(key *rsa.PublicKey) string {
+	// the members are in lexicographic order, as required for the thumbprint
+	thumbprint, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   encodeBigInt(big.NewInt(int64(key.E))),
+		Kty: "RSA",
+		N:   encodeBigInt(key.N),
+	})
+	sum := sha256.Sum256(thumbprint)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func jsonWebKey(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": jwt.SigningMethodRS256.Alg(),
+		"n":   encodeBigInt(key.N),
+		"e":   encodeBigInt(big.NewInt(int64(key.E))),
+	}
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// JWKSPath is the path the JWKSServer publishes the JWKS at
+const JWKSPath = "/.well-known/jwks.json"
+
+var errNilJWKSKey = errors.New("unable to publish a nil key in the JWKS")
+
+// JWKSServer is a httptest.Server publishing a JWKS with the public keys it
+// holds, each under the key id returned by JWKSKeyID
+type JWKSServer struct {
+	*httptest.Server
+
+	mu   sync.RWMutex
+	jwks []byte
+}
+
+// NewJWKSServer starts a JWKSServer publishing the given keys. The caller
+// should call Close when finished, to shut it down.
+func NewJWKSServer(keys ...*rsa.PublicKey) (*JWKSServer, error) {
+	s := &JWKSServer{}
+	if err := s.SetKeys(keys...); err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(JWKSPath, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.jwks)
+	})
+	s.Server = httptest.NewServer(mux)
+	return s, nil
+}
+
+// JWKSURL returns the URL of the JWKS, e.g. for auth.NewJWKSKeyfunc
+func (s *JWKSServer) JWKSURL() string {
+	return s.URL + JWKSPath
+}
+
+// SetKeys replaces the published keys, e.g. to simulate a key rotation
+func (s *JWKSServer) SetKeys(keys ...*rsa.PublicKey) error {
+	set := make([]map[string]string, 0, len(keys))
+	for _, key := range keys {
+		if key == nil {
+			return errNilJWKSKey
+		}
+		set = append(set, jsonWebKey(JWKSKeyID(key), key))
+	}
+	jwks, err := json.Marshal(map[string]interface{}{"keys": set})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.jwks = jwks
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNewJWKSServer(t *testing.T) {
+	oldKey, err := NewTestRSAKey("")
+	if err != nil {
+		t.Fatalf("unable to generate test key: %v", err)
+	}
+	newKey, err := NewTestRSAKey("")
+	if err != nil {
+		t.Fatalf("unable to generate test key: %v", err)
+	}
+	oldKey.ID = JWKSKeyID(&oldKey.PrivateKey.PublicKey)
+	newKey.ID = JWKSKeyID(&newKey.PrivateKey.PublicKey)
+	if oldKey.ID != JWKSKeyID(&oldKey.PrivateKey.PublicKey) || oldKey.ID == newKey.ID {
+		t.Fatalf("unexpected key ids: %q and %q", oldKey.ID, newKey.ID)
+	}
+
+	srv, err := NewJWKSServer(&oldKey.PrivateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("unable to start jwks server: %v", err)
+	}
+	defer srv.Close()
+	keyfunc := auth.NewJWKSKeyfunc(srv.JWKSURL(), auth.WithJWKSMinRefreshInterval(0))
+
+	validate := func(key *TestRSAKey) error {
+		token, err := key.MakeJWT(StandardClaims)
+		if err != nil {
+			t.Fatalf("unable to make test token: %v", err)
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", auth.DefaultTokenType+" "+token))
+		_, err = auth.GetAccountID(ctx, keyfunc)
+		return err
+	}
+	if err := validate(oldKey); err != nil {
+		t.Errorf("unexpected error with the published key: %v", err)
+	}
+	if err := validate(newKey); err == nil {
+		t.Error("expected the token of the unpublished key to be rejected")
+	}
+
+	// rotate the keys
+	if err := srv.SetKeys(&newKey.PrivateKey.PublicKey); err != nil {
+		t.Fatalf("unable to rotate the keys: %v", err)
+	}
+	if err := validate(newKey); err != nil {
+		t.Errorf("unexpected error with the rotated key: %v", err)
+	}
+}
+
+func TestNewJWKSServer_Errors(t *testing.T) {
+	if _, err := NewJWKSServer(nil); err != errNilJWKSKey {
+		t.Errorf("unexpected error: have %v, expected %v", err, errNilJWKSKey)
+	}
+
+	srv, err := NewJWKSServer()
+	if err != nil {
+		t.Fatalf("unable to start jwks server: %v", err)
+	}
+	defer srv.Close()
+	res, err := http.Get(srv.URL + "/other")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status: have %d, expected %d", res.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,81 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTestJWKS(t *testing.T) {
+	key, err := NewTestRSAKey("test-key")
+	if err != nil {
+		t.Fatalf("unable to generate test key: %v", err)
+	}
+	otherKey, err := NewTestRSAKey("other-key")
+	if err != nil {
+		t.Fatalf("unable to generate test key: %v", err)
+	}
+	jwks, err := TestJWKS(key)
+	if err != nil {
+		t.Fatalf("unable to build test jwks: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	defer srv.Close()
+	keyfunc := auth.NewJWKSKeyfunc(srv.URL)
+
+	var tests = []struct {
+		name  string
+		key   *TestRSAKey
+		valid bool
+	}{
+		{"published key", key, true},
+		{"unknown key id", otherKey, false},
+		{"key id of another key", &TestRSAKey{ID: key.ID, PrivateKey: otherKey.PrivateKey}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := test.key.MakeJWT(StandardClaims)
+			if err != nil {
+				t.Fatalf("unable to make test token: %v", err)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", auth.DefaultTokenType+" "+token))
+			accountID, err := auth.GetAccountID(ctx, keyfunc)
+			if test.valid && (err != nil || accountID != StandardClaims[auth.MultiTenancyField]) {
+				t.Errorf("unexpected account id: have %q, error %v", accountID, err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestMakeTestJWTRS256(t *testing.T) {
+	key, err := NewTestRSAKey("")
+	if err != nil {
+		t.Fatalf("unable to generate test key: %v", err)
+	}
+	token, err := MakeTestJWTRS256(StandardClaims, key.PrivateKey)
+	if err != nil {
+		t.Fatalf("unable to make test token: %v", err)
+	}
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return &key.PrivateKey.PublicKey, nil
+	})
+	if err != nil {
+		t.Fatalf("unable to parse test token: %v", err)
+	}
+	if parsed.Method != jwt.SigningMethodRS256 {
+		t.Errorf("unexpected signing method: have %s, expected %s", parsed.Method.Alg(), jwt.SigningMethodRS256.Alg())
+	}
+	if _, ok := parsed.Header["kid"]; ok {
+		t.Errorf("unexpected kid header: %v", parsed.Header["kid"])
+	}
+}
@@ -1,12 +1,15 @@
 package integration
 
 import (
+	"time"
+
 	"github.com/armezit/atlas-app-toolkit/auth"
 	jwt "github.com/golang-jwt/jwt/v4"
 )
 
 const (
-	// testSecret is a dummy secret used for signing test JWTs
+	// testSecret is a dummy secret used for signing test JWTs, it must only be
+	// trusted in tests
 	testSecret = "some-secret-123"
 )
 
@@ -17,18 +20,74 @@ var (
 	}
 )
 
-// MakeTestJWT generates a token string based on the given JWT claims
+// MakeTestJWT generates a token string based on the given JWT claims, signed
+// with the default test secret
 func MakeTestJWT(method jwt.SigningMethod, claims jwt.Claims) (string, error) {
+	return MakeTestJWTWithSecret(method, claims, []byte(testSecret))
+}
+
+// MakeTestJWTWithSecret generates a token string based on the given JWT claims,
+// signed with the given secret, e.g. to test that tokens signed with a wrong
+// secret are rejected
+func MakeTestJWTWithSecret(method jwt.SigningMethod, claims jwt.Claims, secret []byte) (string, error) {
 	token, err := jwt.NewWithClaims(
 		method, claims,
-	).SignedString([]byte(testSecret))
+	).SignedString(secret)
 	if err != nil {
 		return "", err
 	}
 	return token, nil
 }
 
+// MakeTestJWTWithHeader generates a token string like MakeTestJWT, with the
+// header parameters merged into the token header, e.g. a kid or a typ. The
+// alg parameter is the one of the signing method and cannot be overridden
+func MakeTestJWTWithHeader(method jwt.SigningMethod, claims jwt.Claims, header map[string]interface{}) (string, error) {
+	token := jwt.NewWithClaims(method, claims)
+	for k, v := range header {
+		if k != "alg" {
+			token.Header[k] = v
+		}
+	}
+	return token.SignedString([]byte(testSecret))
+}
+
 // StandardTestJWT builds a JWT with the standard test claims in the JWT payload
 func StandardTestJWT() (string, error) {
 	return MakeTestJWT(jwt.SigningMethodHS256, StandardClaims)
 }
+
+// TestJWTWithExpiry builds a JWT with the standard test claims, issued now and
+// expiring after d. A negative d makes an expired token
+func TestJWTWithExpiry(d time.Duration) (string, error) {
+	now := time.Now()
+	if d < 0 {
+		return testJWTWithTimes(now.Add(d-time.Minute), now.Add(d-time.Minute), now.Add(d))
+	}
+	return testJWTWithTimes(now, now, now.Add(d))
+}
+
+// ExpiredTestJWT builds a JWT with the standard test claims that expired an
+// hour ago
+func ExpiredTestJWT() (string, error) {
+	return TestJWTWithExpiry(-time.Hour)
+}
+
+// NotYetValidTestJWT builds a JWT with the standard test claims that is valid
+// in an hour
+func NotYetValidTestJWT() (string, error) {
+	now := time.Now()
+	return testJWTWithTimes(now, now.Add(time.Hour), now.Add(2*time.Hour))
+}
+
+func testJWTWithTimes(iat, nbf, exp time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"iat": iat.Unix(),
+		"nbf": nbf.Unix(),
+		"exp": exp.Unix(),
+	}
+	for k, v := range StandardClaims {
+		claims[k] = v
+	}
+	return MakeTestJWT(jwt.SigningMethodHS256, claims)
+}
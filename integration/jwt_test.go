@@ -3,7 +3,9 @@ package integration
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/armezit/atlas-app-toolkit/auth"
 	jwt "github.com/golang-jwt/jwt/v4"
 )
 
@@ -58,6 +60,42 @@ func TestMakeTestJWT(t *testing.T) {
 	}
 }
 
+func TestMakeTestJWTWithSecret(t *testing.T) {
+	token, err := MakeTestJWTWithSecret(jwt.SigningMethodHS256, StandardClaims, []byte("other-secret"))
+	if err != nil {
+		t.Fatalf("unexpected error when building test token: %v", err)
+	}
+	keyfunc := func(secret string) jwt.Keyfunc {
+		return func(*jwt.Token) (interface{}, error) { return []byte(secret), nil }
+	}
+	if _, err := jwt.Parse(token, keyfunc("other-secret")); err != nil {
+		t.Errorf("unexpected error when parsing test token: %v", err)
+	}
+	if _, err := jwt.Parse(token, keyfunc(testSecret)); err == nil {
+		t.Error("expected the token to be rejected with the default secret")
+	}
+}
+
+func TestMakeTestJWTWithHeader(t *testing.T) {
+	token, err := MakeTestJWTWithHeader(jwt.SigningMethodHS256, StandardClaims, map[string]interface{}{
+		"kid": "key-1",
+		"typ": "at+jwt",
+		"alg": "none",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error when building test token: %v", err)
+	}
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return []byte(testSecret), nil })
+	if err != nil {
+		t.Fatalf("unexpected error when parsing test token: %v", err)
+	}
+	for k, expected := range map[string]interface{}{"kid": "key-1", "typ": "at+jwt", "alg": "HS256"} {
+		if v := parsed.Header[k]; v != expected {
+			t.Errorf("unexpected %s header: have %v, expected %v", k, v, expected)
+		}
+	}
+}
+
 func TestStandardTestJWT(t *testing.T) {
 	t.Run("check test token", func(t *testing.T) {
 		token, err := StandardTestJWT()
@@ -72,6 +110,42 @@ func TestStandardTestJWT(t *testing.T) {
 	})
 }
 
+func TestTestJWTWithExpiry(t *testing.T) {
+	var tests = []struct {
+		name    string
+		factory func() (string, error)
+		err     uint32
+	}{
+		{"valid", func() (string, error) { return TestJWTWithExpiry(time.Hour) }, 0},
+		{"expired", ExpiredTestJWT, jwt.ValidationErrorExpired},
+		{"negative expiry", func() (string, error) { return TestJWTWithExpiry(-time.Second) }, jwt.ValidationErrorExpired},
+		{"not yet valid", NotYetValidTestJWT, jwt.ValidationErrorNotValidYet},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := test.factory()
+			if err != nil {
+				t.Fatalf("unexpected error when building test token: %v", err)
+			}
+			parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+				return []byte(testSecret), nil
+			})
+			var errFlags uint32
+			if verr, ok := err.(*jwt.ValidationError); ok {
+				errFlags = verr.Errors
+			} else if err != nil {
+				t.Fatalf("unexpected error when parsing test token: %v", err)
+			}
+			if errFlags != test.err {
+				t.Errorf("unexpected validation errors: have %b, expected %b", errFlags, test.err)
+			}
+			if claims := parsed.Claims.(jwt.MapClaims); claims[auth.MultiTenancyField] != StandardClaims[auth.MultiTenancyField] {
+				t.Errorf("unexpected account id: have %v, expected %v", claims[auth.MultiTenancyField], StandardClaims[auth.MultiTenancyField])
+			}
+		})
+	}
+}
+
 type mockSigningMethod struct{}
 
 func (mockSigningMethod) Verify(string, string, interface{}) error { return nil }
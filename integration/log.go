@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogBuffer records the entries of the logger returned by CaptureLogger, each
+// as the map of its fields plus the message and level under the
+// logrus.FieldKeyMsg and logrus.FieldKeyLevel keys. The entries are recorded before formatting,
+// so the logger formatter can be changed freely.
+type LogBuffer struct {
+	// Output is the formatted output of the logger
+	Output bytes.Buffer
+
+	mu      sync.Mutex
+	entries []map[string]interface{}
+}
+
+// CaptureLogger returns a logger at debug level recording its entries in the
+// returned LogBuffer
+func CaptureLogger() (*logrus.Logger, *LogBuffer) {
+	buf := &LogBuffer{}
+	logger := logrus.New()
+	logger.Out = &buf.Output
+	logger.Level = logrus.DebugLevel
+	logger.AddHook(buf)
+	return logger, buf
+}
+
+// Levels implements logrus.Hook
+func (b *LogBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (b *LogBuffer) Fire(entry *logrus.Entry) error {
+	fields := make(map[string]interface{}, len(entry.Data)+2)
+	for k, v := range entry.Data {
+		fields[k] = v
+	}
+	fields[logrus.FieldKeyMsg] = entry.Message
+	fields[logrus.FieldKeyLevel] = entry.Level.String()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, fields)
+	return nil
+}
+
+// Entries returns the recorded entries, in the order they were logged
+func (b *LogBuffer) Entries() []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]map[string]interface{}(nil), b.entries...)
+}
+
+// LastEntry returns the last recorded entry, or nil if nothing was logged
+func (b *LogBuffer) LastEntry() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.entries) == 0 {
+		return nil
+	}
+	return b.entries[len(b.entries)-1]
+}
+
+// FieldValue returns the value of the field of the last recorded entry
+func (b *LogBuffer) FieldValue(key string) (interface{}, bool) {
+	value, ok := b.LastEntry()[key]
+	return value, ok
+}
+
+// Reset drops the recorded entries and output, it must not be called while
+// the logger is in use
+func (b *LogBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = nil
+	b.Output.Reset()
+}
@@ -0,0 +1,41 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCaptureLogger(t *testing.T) {
+	for _, formatter := range []logrus.Formatter{&logrus.TextFormatter{}, &logrus.JSONFormatter{}} {
+		logger, logs := CaptureLogger()
+		logger.Formatter = formatter
+
+		if logs.LastEntry() != nil {
+			t.Errorf("unexpected entry: %v", logs.LastEntry())
+		}
+		logger.WithField("grpc.service", "app.Object").Info("first")
+		logger.WithFields(logrus.Fields{"grpc.service": "app.Other", "request_id": "abc"}).Warn("second")
+
+		if entries := logs.Entries(); len(entries) != 2 || entries[0][logrus.FieldKeyMsg] != "first" {
+			t.Fatalf("unexpected entries: %v", entries)
+		}
+		if value, ok := logs.FieldValue("grpc.service"); !ok || value != "app.Other" {
+			t.Errorf("unexpected field value: have %v, expected %v", value, "app.Other")
+		}
+		if value, _ := logs.FieldValue(logrus.FieldKeyLevel); value != "warning" {
+			t.Errorf("unexpected level: have %v, expected %v", value, "warning")
+		}
+		if _, ok := logs.FieldValue("missing"); ok {
+			t.Error("unexpected missing field")
+		}
+		if logs.Output.Len() == 0 {
+			t.Error("expected the formatted output to be written")
+		}
+
+		logs.Reset()
+		if len(logs.Entries()) != 0 || logs.Output.Len() != 0 {
+			t.Error("expected the buffer to be reset")
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -15,6 +16,13 @@ const logFlagHeaderKey = "log-trace-key"
 const logLevelMetaKey = "log-level"
 const logFlagMetaKey = "log-trace-key"
 
+// Metadata keys the HTTP route of a call is passed with, see
+// HTTPRouteAnnotator
+const (
+	DefaultHTTPPathMetaKey   = "x-forwarded-path"
+	DefaultHTTPMethodMetaKey = "x-forwarded-method"
+)
+
 // Name of field to be logged if included
 const logFlagFieldName = "log-trace-key"
 
@@ -31,3 +39,15 @@ func Annotator(ctx context.Context, req *http.Request) metadata.MD {
 
 	return md
 }
+
+// HTTPRouteAnnotator is a function passing the HTTP method and path of the
+// incoming requests to the gRPC call, to be logged by WithHTTPRouteFields. The
+// path is the pattern of the route when the generated handler sets it, e.g.
+// /v1/users/{id}, or else the path of the request
+func HTTPRouteAnnotator(ctx context.Context, req *http.Request) metadata.MD {
+	path, ok := runtime.HTTPPathPattern(ctx)
+	if !ok {
+		path = req.URL.Path
+	}
+	return metadata.Pairs(DefaultHTTPPathMetaKey, path, DefaultHTTPMethodMetaKey, req.Method)
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/armezit/atlas-app-toolkit/gateway"
+)
+
+const (
+	baggageMetaKey = "baggage"
+
+	// DefaultBaggageValueLimit is the default maximum size of a propagated
+	// baggage value
+	DefaultBaggageValueLimit = 256
+
+	// maxBaggageSize is the maximum size of the baggage header of the W3C
+	// specification
+	maxBaggageSize = 8192
+)
+
+// WithBaggagePropagation makes the gw interceptors copy the members of the
+// inbound W3C baggage header with the given keys, or all the members when no
+// key is given, onto the outgoing metadata alongside the request-id. The
+// members whose value is larger than the limit are dropped, see
+// WithBaggageValueLimit.
+func WithBaggagePropagation(keys ...string) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.baggage = true
+		if o.baggageLimit == 0 {
+			o.baggageLimit = DefaultBaggageValueLimit
+		}
+		if len(keys) == 0 {
+			return
+		}
+		if o.baggageKeys == nil {
+			o.baggageKeys = make(map[string]struct{}, len(keys))
+		}
+		for _, k := range keys {
+			o.baggageKeys[k] = struct{}{}
+		}
+	}
+}
+
+// WithBaggageValueLimit sets the maximum size of the propagated baggage
+// values. Defaults to DefaultBaggageValueLimit
+func WithBaggageValueLimit(limit int) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.baggageLimit = limit
+	}
+}
+
+// withBaggage replaces the baggage of the outgoing metadata by the members
+// of the inbound baggage that are propagated
+func (cfg *gwLogCfg) withBaggage(ctx context.Context) context.Context {
+	if !cfg.baggage {
+		return ctx
+	}
+	headers, ok := gateway.HeaderValues(ctx, baggageMetaKey)
+	if !ok {
+		return ctx
+	}
+
+	var members []string
+	size := 0
+	seen := make(map[string]struct{})
+headers:
+	for _, header := range headers {
+		for _, member := range strings.Split(header, ",") {
+			member = strings.TrimSpace(member)
+			key, value := splitBaggageMember(member)
+			if key == "" || len(value) > cfg.baggageLimit {
+				continue
+			}
+			if _, ok := seen[member]; ok {
+				continue
+			}
+			if _, ok := cfg.baggageKeys[key]; cfg.baggageKeys != nil && !ok {
+				continue
+			}
+			// the members past the size of the specification are dropped,
+			// whichever header they are in
+			if size+len(member)+1 > maxBaggageSize {
+				break headers
+			}
+			seen[member] = struct{}{}
+			members = append(members, member)
+			size += len(member) + 1
+		}
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	if len(members) == 0 {
+		delete(md, baggageMetaKey)
+	} else {
+		md.Set(baggageMetaKey, strings.Join(members, ","))
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// splitBaggageMember returns the key and the value of a key=value;properties
+// baggage member
+func splitBaggageMember(member string) (string, string) {
+	kv := member
+	if i := strings.IndexByte(kv, ';'); i >= 0 {
+		kv = kv[:i]
+	}
+	i := strings.IndexByte(kv, '=')
+	if i < 0 {
+		return "", ""
+	}
+	return strings.TrimSpace(kv[:i]), strings.TrimSpace(kv[i+1:])
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/armezit/atlas-app-toolkit/requestid"
+)
+
+func TestGatewayLoggingInterceptor_BaggagePropagation(t *testing.T) {
+	long := strings.Repeat("x", DefaultBaggageValueLimit+1)
+	huge := strings.Repeat("x", maxBaggageSize-DefaultBaggageValueLimit)
+	for name, tc := range map[string]struct {
+		opts     []GWLogOption
+		ctx      context.Context
+		expected []string
+	}{
+		"configured keys": {
+			opts:     []GWLogOption{WithBaggagePropagation("tier", "experiment")},
+			ctx:      metadata.NewIncomingContext(context.Background(), metadata.Pairs(baggageMetaKey, "tier=gold, user=alice,experiment=exp-1;ttl=60")),
+			expected: []string{"tier=gold,experiment=exp-1;ttl=60"},
+		},
+		"all keys": {
+			opts:     []GWLogOption{WithBaggagePropagation()},
+			ctx:      metadata.NewIncomingContext(context.Background(), metadata.Pairs(baggageMetaKey, "tier=gold", baggageMetaKey, "user=alice")),
+			expected: []string{"tier=gold,user=alice"},
+		},
+		"forwarded header is filtered": {
+			opts:     []GWLogOption{WithBaggagePropagation("tier")},
+			ctx:      metadata.NewOutgoingContext(context.Background(), metadata.Pairs(baggageMetaKey, "tier=gold,user=alice")),
+			expected: []string{"tier=gold"},
+		},
+		"value limit": {
+			opts:     []GWLogOption{WithBaggagePropagation(), WithBaggageValueLimit(4)},
+			ctx:      metadata.NewIncomingContext(context.Background(), metadata.Pairs(baggageMetaKey, "tier=gold,user=alice")),
+			expected: []string{"tier=gold"},
+		},
+		"default value limit": {
+			opts: []GWLogOption{WithBaggagePropagation()},
+			ctx:  metadata.NewOutgoingContext(context.Background(), metadata.Pairs(baggageMetaKey, "large="+long)),
+		},
+		"size limit": {
+			opts:     []GWLogOption{WithBaggagePropagation(), WithBaggageValueLimit(maxBaggageSize)},
+			ctx:      metadata.NewIncomingContext(context.Background(), metadata.Pairs(baggageMetaKey, "large="+huge+",user="+long, baggageMetaKey, "tier=gold")),
+			expected: []string{"large=" + huge},
+		},
+		"disabled": {
+			ctx:      metadata.NewOutgoingContext(context.Background(), metadata.Pairs(baggageMetaKey, "tier=gold,user=alice")),
+			expected: []string{"tier=gold,user=alice"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, _ := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			var baggage []string
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				baggage = md.Get(baggageMetaKey)
+				// the request id is still propagated
+				assert.NotEmpty(t, md.Get(requestid.MetadataKey()))
+				return nil
+			}
+			assert.NoError(t, interceptor(tc.ctx, testFullMethod, nil, nil, nil, invoker))
+			assert.Equal(t, tc.expected, baggage)
+		})
+	}
+}
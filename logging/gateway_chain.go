@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// WithChainedInterceptors sets the unary interceptors that
+// ChainGatewayClientInterceptors runs between the logging and the sentinel
+// interceptors, in the given order. It has no effect on the interceptors
+// built by GatewayLoggingInterceptor.
+func WithChainedInterceptors(interceptors ...grpc.UnaryClientInterceptor) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.chainedUnary = append(o.chainedUnary, interceptors...)
+	}
+}
+
+// WithChainedStreamInterceptors is the streaming counterpart of
+// WithChainedInterceptors, for ChainGatewayClientStreamInterceptors
+func WithChainedStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.chainedStream = append(o.chainedStream, interceptors...)
+	}
+}
+
+// ChainGatewayClientInterceptors returns the dial option chaining the
+// GatewayLoggingInterceptor configured with opts, the interceptors given with
+// WithChainedInterceptors, and the GatewayLoggingSentinelInterceptor, in this
+// order. The logging interceptor comes first so that the calls rejected by any
+// interceptor of the chain are logged, and the sentinel last so that it is
+// only set for the calls that reach the server.
+func ChainGatewayClientInterceptors(logger *logrus.Logger, opts ...GWLogOption) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(gatewayUnaryChain(logger, opts)...)
+}
+
+func gatewayUnaryChain(logger *logrus.Logger, opts []GWLogOption) []grpc.UnaryClientInterceptor {
+	cfg := newGWLogCfg(opts)
+	chain := make([]grpc.UnaryClientInterceptor, 0, len(cfg.chainedUnary)+2)
+	chain = append(chain, GatewayLoggingInterceptor(logger, opts...))
+	chain = append(chain, cfg.chainedUnary...)
+	return append(chain, GatewayLoggingSentinelInterceptor())
+}
+
+// ChainGatewayClientStreamInterceptors is the streaming counterpart of
+// ChainGatewayClientInterceptors, chaining the GatewayLoggingStreamInterceptor,
+// the interceptors given with WithChainedStreamInterceptors and the
+// GatewayLoggingSentinelStreamInterceptor
+func ChainGatewayClientStreamInterceptors(logger *logrus.Logger, opts ...GWLogOption) grpc.DialOption {
+	return grpc.WithChainStreamInterceptor(gatewayStreamChain(logger, opts)...)
+}
+
+func gatewayStreamChain(logger *logrus.Logger, opts []GWLogOption) []grpc.StreamClientInterceptor {
+	cfg := newGWLogCfg(opts)
+	chain := make([]grpc.StreamClientInterceptor, 0, len(cfg.chainedStream)+2)
+	chain = append(chain, GatewayLoggingStreamInterceptor(logger, opts...))
+	chain = append(chain, cfg.chainedStream...)
+	return append(chain, GatewayLoggingSentinelStreamInterceptor())
+}
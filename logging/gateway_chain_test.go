@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChainGatewayClientInterceptors(t *testing.T) {
+	assert.NotNil(t, ChainGatewayClientInterceptors(logrus.New()))
+	assert.NotNil(t, ChainGatewayClientStreamInterceptors(logrus.New()))
+}
+
+func TestGatewayUnaryChain(t *testing.T) {
+	for name, tc := range map[string]struct {
+		reject  bool
+		entries int
+	}{
+		// the call reaching the server is logged by the server
+		"reached the server": {},
+		// the call rejected in the middle of the chain is logged by the gateway
+		"rejected": {reject: true, entries: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+
+			var chained []string
+			middle := func(name string) grpc.UnaryClientInterceptor {
+				return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+					// runs after the logging interceptor
+					assert.NotNil(t, ctxlogrus.Extract(ctx).Data[DefaultGRPCServiceKey])
+					chained = append(chained, name)
+					if tc.reject {
+						return status.Error(codes.InvalidArgument, "rejected")
+					}
+					return invoker(ctx, method, req, reply, cc, opts...)
+				}
+			}
+
+			var sentinelSet bool
+			interceptor := grpc_middleware.ChainUnaryClient(gatewayUnaryChain(logger, []GWLogOption{WithChainedInterceptors(middle("first"), middle("second"))})...)
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				sentinelSet, _ = SentinelValueFromCtx(ctx)
+				return nil
+			}
+
+			err := interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+			if tc.reject {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+				assert.Equal(t, []string{"first"}, chained)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, sentinelSet)
+				assert.Equal(t, []string{"first", "second"}, chained)
+			}
+			assert.Len(t, gatewayLogEntries(t, out), tc.entries)
+		})
+	}
+}
+
+func TestGatewayStreamChain(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+
+	var chained bool
+	middle := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		chained = true
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	chain := gatewayStreamChain(logger, []GWLogOption{WithChainedStreamInterceptors(middle)})
+	require.Len(t, chain, 3)
+	interceptor := grpc_middleware.ChainStreamClient(chain...)
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{ctx: ctx}, nil
+	}
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, testFullMethod, streamer)
+	require.NoError(t, err)
+	assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+	assert.True(t, chained)
+	// the stream reached the server, which logs it
+	assert.Empty(t, out.String())
+}
@@ -2,17 +2,25 @@ package logging
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"path"
+	"strings"
+	"sync"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v4"
-	"github.com/google/uuid"
 	grpc_logrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
-	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/armezit/atlas-app-toolkit/auth"
 	"github.com/armezit/atlas-app-toolkit/gateway"
@@ -21,14 +29,79 @@ import (
 
 const (
 	valueUndefined = "undefined"
+	valueRedacted  = "***"
+
+	requestMetadataField = "grpc.request.metadata"
+	traceIDField         = "trace_id"
+	spanIDField          = "span_id"
+	invalidLogLevelField = "grpc.log_level.invalid"
+	forcedLogLevelField  = "grpc.log_level.forced"
+	effectiveLevelField  = "grpc.log_level.effective"
+	errorDetailsField    = "grpc.error.details"
+	cancellationField    = "grpc.cancellation"
+	accountIDSourceField = "grpc.account_id.source"
+	peerAddressField     = "peer.address"
+	userAgentField       = "grpc.user_agent"
+	httpPathField        = "http.path"
+	httpMethodField      = "http.method"
+	subjectField         = "auth.subject"
+	tokenExpiryField     = "auth.token_expiry"
+
+	userAgentMetaKey = "user-agent"
+
+	accountIDMetadataSource = "metadata"
 )
 
+var errMissingAccountID = errors.New("unable to get account id from token")
+
+// defaultRedactedMetadataKeys are always redacted from the logged metadata
+var defaultRedactedMetadataKeys = []string{"authorization", "cookie", "x-api-key"}
+
 type gwLogCfg struct {
-	dynamicLogLvl bool
-	noRequestID   bool
-	acctIDKeyfunc jwt.Keyfunc
-	withAcctID    bool
-	codeToLevel   grpc_logrus.CodeToLevel
+	dynamicLogLvl  bool
+	noRequestID    bool
+	acctIDKeyfunc  jwt.Keyfunc
+	withAcctID     bool
+	anonymous      map[string]struct{}
+	acctIDClaims   []string
+	acctIDExtract  auth.AccountIDExtractor
+	codeToLevel    grpc_logrus.CodeToLevel
+	sampler        func(fullMethod string) bool
+	dumpMetadata   bool
+	redactedKeys   map[string]struct{}
+	payloadMode    PayloadMode
+	payloadLimit   int
+	requestIDKey   string
+	traceFields    bool
+	ignored        map[string]struct{}
+	ignoredPrefix  []string
+	extractors     []FieldExtractor
+	staticFields   logrus.Fields
+	mostSevere     logrus.Level
+	levelRegistry  *LevelRegistry
+	peerFields     bool
+	httpRoute      bool
+	httpPathKey    string
+	httpMethodKey  string
+	messageSizes   bool
+	finishMessage  func(fullMethod string, code codes.Code) string
+	alwaysLog      bool
+	sentinelDebug  bool
+	fieldNames     map[string]string
+	durationField  grpc_logrus.DurationToField
+	subjectFields  bool
+	methodLevels   map[string]map[codes.Code]logrus.Level
+	baggage        bool
+	baggageKeys    map[string]struct{}
+	baggageLimit   int
+	quietAcctID    bool
+	singleEntry    bool
+	trustedTenant  bool
+	metadataTenant bool
+	metrics        *gwMetrics
+	trailerKeys    []string
+	chainedUnary   []grpc.UnaryClientInterceptor
+	chainedStream  []grpc.StreamClientInterceptor
 }
 
 // GWLogOption is a type of function that alters a gwLogCfg in the instantiation
@@ -40,6 +113,184 @@ func DisableRequestID(o *gwLogCfg) {
 	o.noRequestID = true
 }
 
+// WithRequestIDKey sets the metadata key the request-id is read from and
+// propagated with, which is also used as the name of the log field.
+// Defaults to the metadata key configured in the requestid package
+func WithRequestIDKey(key string) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.requestIDKey = key
+	}
+}
+
+// WithTraceFields adds the trace_id and span_id fields of the span found in
+// the context to gw interceptor logs, they are omitted when there is no span
+func WithTraceFields() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.traceFields = true
+	}
+}
+
+// WithIgnoredMethods disables the gw interceptor logs of the given methods, in
+// the /service/Method form. The request-id is still forwarded for them.
+func WithIgnoredMethods(fullMethods ...string) GWLogOption {
+	return func(o *gwLogCfg) {
+		if o.ignored == nil {
+			o.ignored = make(map[string]struct{}, len(fullMethods))
+		}
+		for _, m := range fullMethods {
+			o.ignored[m] = struct{}{}
+		}
+	}
+}
+
+// WithIgnoredServicePrefix disables the gw interceptor logs of the methods
+// starting with one of the given prefixes, e.g. "/grpc.health.v1.Health/"
+func WithIgnoredServicePrefix(prefixes ...string) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.ignoredPrefix = append(o.ignoredPrefix, prefixes...)
+	}
+}
+
+// FieldExtractor returns a field to add to gw interceptor logs, derived from
+// the request context, ok is false when there is no field to add
+type FieldExtractor func(ctx context.Context) (key string, value interface{}, ok bool)
+
+// WithFieldExtractors adds the fields returned by the extractors to gw
+// interceptor logs. The extractors are called in order for every request, so
+// later extractors override the fields of earlier ones, as well as the
+// fields set by the interceptor itself. An extractor that panics is skipped.
+func WithFieldExtractors(fns ...FieldExtractor) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.extractors = append(o.extractors, fns...)
+	}
+}
+
+// WithStaticFields adds the constant fields, e.g. the version or the region of
+// the deployment, to gw interceptor logs. They never override the fields set
+// by the interceptor: the grpc.* fields and span.kind, system and error keys
+// are dropped, so are the fields the interceptor sets for a call. It is the
+// gateway counterpart of WithCustomFields, which reads claims of the token.
+func WithStaticFields(fields logrus.Fields) GWLogOption {
+	return func(o *gwLogCfg) {
+		if o.staticFields == nil {
+			o.staticFields = make(logrus.Fields, len(fields))
+		}
+		for k, v := range fields {
+			if !isWellKnownField(k) {
+				o.staticFields[k] = v
+			}
+		}
+	}
+}
+
+// isWellKnownField reports whether the field is one of the fields logged for
+// every call
+func isWellKnownField(key string) bool {
+	switch key {
+	case grpc_logrus.SystemField, grpc_logrus.KindField, logrus.ErrorKey:
+		return true
+	}
+	return strings.HasPrefix(key, "grpc.")
+}
+
+// WithPeerFields adds the address of the peer under the peer.address field
+// and the user-agent metadata under the grpc.user_agent field to gw
+// interceptor logs, they are omitted when missing from the context
+func WithPeerFields() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.peerFields = true
+	}
+}
+
+// WithHTTPRouteFields adds the HTTP path and method of the request under the
+// http.path and http.method fields to gw interceptor logs. They are read from
+// the metadata set by HTTPRouteAnnotator, the path defaults to the route
+// pattern of the generated handler, and are omitted for the pure gRPC calls
+func WithHTTPRouteFields() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.httpRoute = true
+	}
+}
+
+// WithHTTPRouteKeys is WithHTTPRouteFields reading the path and the method
+// from the given metadata keys, e.g. the ones set by a proxy
+func WithHTTPRouteKeys(pathKey, methodKey string) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.httpRoute = true
+		o.httpPathKey = pathKey
+		o.httpMethodKey = methodKey
+	}
+}
+
+// WithFinishMessageFunc sets the function building the message of the line
+// logged when a call finishes, from the full method and the status code of
+// the call. Defaults to "finished client unary call with code <code>", or
+// "finished client streaming call with code <code>" for streams.
+func WithFinishMessageFunc(fn func(fullMethod string, code codes.Code) string) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.finishMessage = fn
+	}
+}
+
+// WithAlwaysLog makes the gw interceptors log the calls that reached the
+// server too, for backends that do not log them. The sentinel is still set in
+// the context for the middlewares down the chain.
+func WithAlwaysLog() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.alwaysLog = true
+	}
+}
+
+// WithSentinelDebugLog makes the gw interceptors log the calls that reached
+// the server at debug level, instead of not logging them, e.g. to compare the
+// gateway and the server latencies. It has no effect with WithAlwaysLog.
+func WithSentinelDebugLog() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.sentinelDebug = true
+	}
+}
+
+// WithFieldNames renames the logged fields, keyed by their default name, e.g.
+// {grpc_logrus.KindField: "kind", "grpc.service": "rpc.service"}, in the fields
+// of the request-scoped logger and of the finish line
+func WithFieldNames(names map[string]string) GWLogOption {
+	return func(o *gwLogCfg) {
+		if o.fieldNames == nil {
+			o.fieldNames = make(map[string]string, len(names))
+		}
+		for from, to := range names {
+			if to != "" && to != from {
+				o.fieldNames[from] = to
+			}
+		}
+	}
+}
+
+// WithDurationField sets the resolution of the duration of the finish line,
+// time.Millisecond, time.Microsecond or time.Nanosecond, logged under the
+// grpc.time_ms, grpc.time_us or grpc.time_ns field respectively. Defaults to
+// milliseconds, other resolutions are ignored
+func WithDurationField(resolution time.Duration) GWLogOption {
+	return func(o *gwLogCfg) {
+		switch resolution {
+		case time.Millisecond:
+			o.durationField = grpc_logrus.DurationToTimeMillisField
+		case time.Microsecond:
+			o.durationField = durationToTimeMicrosField
+		case time.Nanosecond:
+			o.durationField = durationToTimeNanosField
+		}
+	}
+}
+
+func durationToTimeMicrosField(duration time.Duration) (string, interface{}) {
+	return "grpc.time_us", duration.Nanoseconds() / int64(time.Microsecond)
+}
+
+func durationToTimeNanosField(duration time.Duration) (string, interface{}) {
+	return "grpc.time_ns", duration.Nanoseconds()
+}
+
 // WithDynamicLogLevel enables or disables dynamic log levels like handled in
 // the server interceptor
 func WithDynamicLogLevel(enable bool) GWLogOption {
@@ -54,11 +305,22 @@ func EnableDynamicLogLevel(o *gwLogCfg) {
 }
 
 // WithAccountID enables the account_id field in gw interceptor logs, like the
-// server interceptor
-func WithAccountID(keyfunc jwt.Keyfunc) GWLogOption {
+// server interceptor. The anonymous methods, in the /service/Method form, are
+// expected to have no token, e.g. login or signup: the account id is not read
+// for them, so they have no account_id field and no failure log.
+func WithAccountID(keyfunc jwt.Keyfunc, anonymousMethods ...string) GWLogOption {
 	return func(o *gwLogCfg) {
 		o.withAcctID = true
+		o.anonymous = nil
+		if len(anonymousMethods) > 0 {
+			o.anonymous = make(map[string]struct{}, len(anonymousMethods))
+			for _, m := range anonymousMethods {
+				o.anonymous[m] = struct{}{}
+			}
+		}
 		o.acctIDKeyfunc = keyfunc
+		o.acctIDClaims = nil
+		o.acctIDExtract = nil
 	}
 }
 
@@ -66,14 +328,159 @@ func WithAccountID(keyfunc jwt.Keyfunc) GWLogOption {
 func EnableAccountID(o *gwLogCfg) {
 	o.withAcctID = true
 	o.acctIDKeyfunc = nil
+	o.acctIDClaims = nil
+	o.acctIDExtract = nil
+}
+
+// WithAccountIDExtractor is like WithAccountID but reads the account_id field
+// with the given extractor, e.g. for tokens that are not JWTs. The extractor
+// is given the outgoing metadata of the call as incoming metadata.
+func WithAccountIDExtractor(extractor auth.AccountIDExtractor) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.withAcctID = true
+		o.acctIDKeyfunc = nil
+		o.acctIDClaims = nil
+		o.acctIDExtract = extractor
+	}
+}
+
+// WithMetadataAccountID logs the account id set in the metadata by
+// auth.WithOutgoingAccountID for the calls without token, e.g. the ones of
+// background jobs. It is off by default since any anonymous caller can set the
+// metadata, and so the logged account id
+func WithMetadataAccountID() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.metadataTenant = true
+	}
+}
+
+// WithTrustedMetadataTenancy logs the account id set in the metadata, e.g. by
+// auth.WithOutgoingAccountID, without parsing the token, as
+// auth.WithTrustedMetadataTenancy. It is meant for the internal fan-out behind
+// a trusted boundary, since any external caller can set the metadata
+func WithTrustedMetadataTenancy() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.trustedTenant = true
+	}
+}
+
+// WithSingleEntry merges the fields of the call into its final line with a
+// single WithFields call, rather than injecting a logger holding them in the
+// context of the call. It saves the allocations of the injected logger, but is
+// incompatible with the middlewares down the chain reading or altering the
+// logger of the context, e.g. with ctxlogrus.Extract or ctxlogrus.AddFields:
+// they get no logger and their changes are not logged.
+func WithSingleEntry() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.singleEntry = true
+	}
+}
+
+// WithQuietAccountID logs the failures to read the account id caused by a
+// missing token at debug level instead of info, e.g. for public endpoints.
+// The account_id field is still set to undefined, a malformed token is still
+// logged at warning level.
+func WithQuietAccountID() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.quietAcctID = true
+	}
+}
+
+// WithSubjectField enables the auth.subject and auth.token_expiry fields in gw
+// interceptor logs, read from the sub and exp claims of the token. The claims
+// missing from the token are omitted. The token is verified with the keyfunc
+// given to WithAccountID, if any, and parsed once for both.
+func WithSubjectField() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.subjectFields = true
+	}
+}
+
+// WithAccountIDClaims is like WithAccountID but reads the account_id field
+// from the first of the given claims with a non-empty value, the claim that
+// matched is logged under the grpc.account_id.source field
+func WithAccountIDClaims(keyfunc jwt.Keyfunc, claims ...string) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.withAcctID = true
+		o.acctIDKeyfunc = keyfunc
+		o.acctIDClaims = claims
+		o.acctIDExtract = nil
+	}
 }
 
+// WithCodeFunc sets the mapping of the status codes to the level of the final
+// line, a nil codeFunc stands for grpc_logrus.DefaultCodeToLevel
 func WithCodeFunc(codeFunc grpc_logrus.CodeToLevel) GWLogOption {
 	return func(o *gwLogCfg) {
 		o.codeToLevel = codeFunc
 	}
 }
 
+// WithCodeLevelOverrideForMethod logs the calls of the full method finishing
+// with the code at lvl, before the code function is consulted, e.g. the
+// cancellations of a long poll at debug level
+func WithCodeLevelOverrideForMethod(fullMethod string, code codes.Code, lvl logrus.Level) GWLogOption {
+	return func(o *gwLogCfg) {
+		if o.methodLevels == nil {
+			o.methodLevels = make(map[string]map[codes.Code]logrus.Level)
+		}
+		if o.methodLevels[fullMethod] == nil {
+			o.methodLevels[fullMethod] = make(map[codes.Code]logrus.Level)
+		}
+		o.methodLevels[fullMethod][code] = lvl
+	}
+}
+
+// levelFor returns the level of the call of the full method finishing with
+// the code
+func (cfg *gwLogCfg) levelFor(fullMethod string, code codes.Code) logrus.Level {
+	if lvl, ok := cfg.methodLevels[fullMethod][code]; ok {
+		return lvl
+	}
+	// the config may be built without the default, e.g. by WithCodeFunc(nil)
+	if cfg.codeToLevel == nil {
+		return grpc_logrus.DefaultCodeToLevel(code)
+	}
+	return cfg.codeToLevel(code)
+}
+
+// WithSampling logs only the given fraction (0.0 to 1.0) of the successful
+// calls. Calls finishing with a non-OK code are always logged.
+func WithSampling(rate float64) GWLogOption {
+	return WithMethodSampler(func(string) bool {
+		return rand.Float64() < rate
+	})
+}
+
+// WithMethodSampler decides per call whether a successful call is logged,
+// the sampler returning false drops the finish line of the call. Calls
+// finishing with a non-OK code are always logged.
+func WithMethodSampler(sampler func(fullMethod string) bool) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.sampler = sampler
+	}
+}
+
+// WithMetadataDump logs the outgoing metadata of the call under the
+// grpc.request.metadata field, the values of the redacted keys are masked
+func WithMetadataDump() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.dumpMetadata = true
+	}
+}
+
+// WithRedactedMetadataKeys masks the values of the given metadata keys
+// wherever the gateway interceptor logs metadata. The keys are matched case
+// insensitively and are added to the authorization, cookie and x-api-key
+// keys, which are always redacted.
+func WithRedactedMetadataKeys(keys ...string) GWLogOption {
+	return func(o *gwLogCfg) {
+		for _, k := range keys {
+			o.redactedKeys[strings.ToLower(k)] = struct{}{}
+		}
+	}
+}
+
 type sentinelKeyType struct{}
 
 var sentinelKey = sentinelKeyType{}
@@ -88,114 +495,646 @@ func SentinelValueFromCtx(ctx context.Context) (value, ok bool) {
 	return false, false
 }
 
+type forcedLevelKeyType struct{}
+
+var forcedLevelKey = forcedLevelKeyType{}
+
+// WithForcedLevel returns a context making the gw interceptors log the call at
+// lvl, over any other level source. Like the log-level header it has no effect
+// unless the dynamic log level is enabled.
+func WithForcedLevel(ctx context.Context, lvl logrus.Level) context.Context {
+	return context.WithValue(ctx, forcedLevelKey, lvl)
+}
+
+func forcedLevelFromContext(ctx context.Context) (logrus.Level, bool) {
+	lvl, ok := ctx.Value(forcedLevelKey).(logrus.Level)
+	return lvl, ok
+}
+
+type logFlagKeyType struct{}
+
+var logFlagKey = logFlagKeyType{}
+
+// LogFlagFromContext returns the value of the log-trace-key header flagging
+// the request for verbose logging, as stashed in the context by the log
+// interceptors. The gw interceptors only stash it when the dynamic log level
+// is enabled.
+func LogFlagFromContext(ctx context.Context) (string, bool) {
+	flag, ok := ctx.Value(logFlagKey).(string)
+	return flag, ok
+}
+
+type logLevelKeyType struct{}
+
+var logLevelKey = logLevelKeyType{}
+
+// LevelFromContext returns the log level resolved for the call from the
+// log-level header, a forced level or the level registry, as stashed in the
+// context by the log interceptors, so that the handlers can log verbose output
+// conditionally. It is absent when the dynamic log level is not enabled, i.e.
+// without EnableDynamicLogLevel for the gw interceptors.
+func LevelFromContext(ctx context.Context) (logrus.Level, bool) {
+	lvl, ok := ctx.Value(logLevelKey).(logrus.Level)
+	return lvl, ok
+}
+
 // GatewayLoggingInterceptor handles the functions of the various toolkit interceptors
 // offered for the grpc server, as well as the standard grpc_logrus server interceptor
 // behavior (superset of grpc_logrus client interceptor behavior)
 func GatewayLoggingInterceptor(logger *logrus.Logger, opts ...GWLogOption) grpc.UnaryClientInterceptor {
-	cfg := &gwLogCfg{}
+	return GatewayLoggingInterceptorFor(LogrusLogger(logger), opts...)
+}
+
+// GatewayLoggingInterceptorFor is the GatewayLoggingInterceptor emitting its
+// logs through any Logger backend
+func GatewayLoggingInterceptorFor(logger Logger, opts ...GWLogOption) grpc.UnaryClientInterceptor {
+	cfg := newGWLogCfg(opts)
+	return func(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		if cfg.isIgnored(method) {
+			ctx, _ = cfg.withRequestID(ctx)
+			return invoker(cfg.withBaggage(ctx), method, req, reply, cc, opts...)
+		}
+		if cfg.quiet(logger) {
+			var sentinelValue bool
+			return invoker(context.WithValue(cfg.startQuietCall(ctx, logger), sentinelKey, &sentinelValue), method, req, reply, cc, opts...)
+		}
+
+		call := cfg.startCall(ctx, logger, method)
+
+		var trailer metadata.MD
+		if len(cfg.trailerKeys) > 0 {
+			// the option is added to a copy, the caller owns the slice
+			opts = append(opts[:len(opts):len(opts)], grpc.Trailer(&trailer))
+		}
+
+		var sentinelValue bool
+		err = invoker(context.WithValue(call.ctx, sentinelKey, &sentinelValue), method, req, reply, cc, opts...)
+
+		// if the sentinel is set, no middlewares had errors, and it is assumed the
+		// server will log the call instead of the gateway doing so
+		if sentinelValue && !cfg.alwaysLog {
+			if !cfg.sentinelDebug {
+				call.observe(status.Code(err))
+				return
+			}
+			call.debug = true
+		}
+
+		extra := cfg.trailerFields(cfg.messageFields(req, reply, err), trailer)
+		call.finish(err, "finished client unary call with code %s", extra)
+		putFields(extra)
+
+		return
+	}
+}
+
+func newGWLogCfg(opts []GWLogOption) *gwLogCfg {
+	cfg := &gwLogCfg{
+		redactedKeys:  make(map[string]struct{}, len(defaultRedactedMetadataKeys)),
+		requestIDKey:  requestid.MetadataKey(),
+		httpPathKey:   DefaultHTTPPathMetaKey,
+		httpMethodKey: DefaultHTTPMethodMetaKey,
+	}
 	cfg.codeToLevel = grpc_logrus.DefaultCodeToLevel
+	cfg.durationField = grpc_logrus.DurationToTimeMillisField
+	for _, k := range defaultRedactedMetadataKeys {
+		cfg.redactedKeys[k] = struct{}{}
+	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
-	return func(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+	cfg.mostSevere = cfg.mostSevereLevel()
+	return cfg
+}
 
-		service := path.Dir(method)[1:]
-		grpcMethod := path.Base(method)
-		startTime := time.Now()
-		fields := logrus.Fields{
-			grpc_logrus.SystemField: "grpc",
-			grpc_logrus.KindField:   "gateway",
-			"grpc.service":          service,
-			"grpc.method":           grpcMethod,
-			"grpc.start_time":       startTime.Format(time.RFC3339),
-		}
-		if d, ok := ctx.Deadline(); ok {
-			fields["grpc.request.deadline"] = d.Format(time.RFC3339)
-		}
-
-		// Request ID -- defaults to on
-		if !cfg.noRequestID {
-			reqID, exists := requestid.FromContext(ctx)
-			if !exists || reqID == "" {
-				reqID = uuid.New().String()
+// mostSevereLevel returns the most severe level the lines of a call can be
+// logged at, whatever its code
+func (cfg *gwLogCfg) mostSevereLevel() logrus.Level {
+	lvl := logrus.TraceLevel
+	// the codes past Unauthenticated are all mapped like the first of them
+	for code := codes.OK; code <= codes.Unauthenticated+1; code++ {
+		if l := cfg.levelFor("", code); l < lvl {
+			lvl = l
+		}
+	}
+	for _, levels := range cfg.methodLevels {
+		for _, l := range levels {
+			if l < lvl {
+				lvl = l
 			}
-			fields[requestid.DefaultRequestIDKey] = reqID
-			ctx = metadata.AppendToOutgoingContext(ctx, requestid.DefaultRequestIDKey, reqID)
 		}
+	}
+	return lvl
+}
 
-		// Custom log level
-		lvl := logger.Level
-		if cfg.dynamicLogLvl {
-			if logFlag, ok := gateway.Header(ctx, logFlagMetaKey); ok {
-				fields[logFlagFieldName] = logFlag[0]
-			}
-			if logLvl, ok := gateway.Header(ctx, logLevelMetaKey); ok {
-				lvl, err = logrus.ParseLevel(logLvl)
-				if err != nil {
-					lvl = logger.Level
-				}
+// quiet reports whether the logger cannot emit any line of the call, so that
+// its fields need not be built. The level of the call must not depend on the
+// request, through the dynamic log level or the level registry, and no metric
+// must be recorded.
+func (cfg *gwLogCfg) quiet(logger Logger) bool {
+	return cfg.mostSevere > logger.Level() && !cfg.dynamicLogLvl && cfg.levelRegistry == nil && cfg.metrics == nil
+}
+
+// startQuietCall is the startCall of the quiet calls, it only propagates the
+// request id and the baggage, and injects the logger with the request-id as
+// only field into the returned context
+func (cfg *gwLogCfg) startQuietCall(ctx context.Context, logger Logger) context.Context {
+	ctx, reqID := cfg.withRequestID(cfg.withBaggage(ctx))
+	if reqID == "" {
+		return loggerToContext(ctx, logger)
+	}
+	fields := getFields()
+	defer putFields(fields)
+	fields[cfg.requestIDKey] = reqID
+	cfg.renameFields(fields)
+	return loggerToContext(ctx, logger.WithFields(fields))
+}
+
+// fieldsPool holds the field maps built for every call. The Logger backends
+// copy the fields, see Logger.WithFields, so a map is reused once logged
+var fieldsPool = sync.Pool{
+	New: func() interface{} { return make(logrus.Fields, 16) },
+}
+
+func getFields() logrus.Fields {
+	return fieldsPool.Get().(logrus.Fields)
+}
+
+// putFields empties the fields and returns them to the pool, they must not be
+// used afterwards
+func putFields(fields logrus.Fields) {
+	if fields == nil {
+		return
+	}
+	for k := range fields {
+		delete(fields, k)
+	}
+	fieldsPool.Put(fields)
+}
+
+// renameFields renames the fields set by WithFieldNames, all at once so that
+// a renamed field is not renamed again
+func (cfg *gwLogCfg) renameFields(fields logrus.Fields) {
+	if len(cfg.fieldNames) == 0 {
+		return
+	}
+	renamed := getFields()
+	defer putFields(renamed)
+	for from, to := range cfg.fieldNames {
+		if v, ok := fields[from]; ok {
+			delete(fields, from)
+			renamed[to] = v
+		}
+	}
+	for k, v := range renamed {
+		fields[k] = v
+	}
+}
+
+// gwCall holds the state of a single call handled by the gateway interceptors
+type gwCall struct {
+	cfg       *gwLogCfg
+	ctx       context.Context
+	logger    Logger
+	method    string
+	startTime time.Time
+	sampled   bool
+	// debug logs the call at debug level, as it is logged by the server
+	debug bool
+	// silent only records the metrics of the call, as it is logged by the server
+	silent bool
+	// fields are the fields of the call with WithSingleEntry, which are not in
+	// the logger
+	fields logrus.Fields
+}
+
+// entryLogger returns the logger of the lines of the call, including the
+// changes made to it down the middleware chain unless WithSingleEntry is set
+func (c *gwCall) entryLogger() Logger {
+	if c.fields != nil {
+		return c.logger.WithFields(c.fields)
+	}
+	return loggerFromContext(c.ctx, c.logger)
+}
+
+// observe records the metrics of the call finishing with code
+func (c *gwCall) observe(code codes.Code) {
+	c.cfg.metrics.observe(c.method, code, time.Since(c.startTime))
+}
+
+// level returns the level the call finishing with code is logged at
+func (c *gwCall) level(code codes.Code) logrus.Level {
+	if c.debug {
+		return logrus.DebugLevel
+	}
+	return c.cfg.levelFor(c.method, code)
+}
+
+// httpRouteFields adds the HTTP path and method of the call, when it was made
+// by a gateway handler
+func (cfg *gwLogCfg) httpRouteFields(ctx context.Context, fields logrus.Fields) {
+	if path, ok := gateway.Header(ctx, cfg.httpPathKey); ok {
+		fields[httpPathField] = path
+	} else if pattern, ok := runtime.HTTPPathPattern(ctx); ok {
+		fields[httpPathField] = pattern
+	}
+	if method, ok := gateway.Header(ctx, cfg.httpMethodKey); ok {
+		fields[httpMethodField] = method
+	}
+}
+
+// startCall builds the initial log fields for the call, propagates the request
+// id to the outgoing metadata and injects the request-scoped logger into the
+// context of the returned call
+func (cfg *gwLogCfg) startCall(ctx context.Context, logger Logger, method string) *gwCall {
+	startTime := time.Now()
+	service, _ := splitMethod(method)
+	fields := getFields()
+	defer putFields(fields)
+	fields[grpc_logrus.SystemField] = "grpc"
+	fields[grpc_logrus.KindField] = "gateway"
+	fields["grpc.start_time"] = startTime.Format(time.RFC3339)
+	if d, ok := ctx.Deadline(); ok {
+		fields["grpc.request.deadline"] = d.Format(time.RFC3339)
+		// the budget left to the call, negative when the deadline is exceeded
+		fields["grpc.request.timeout_ms"] = d.Sub(startTime).Milliseconds()
+	}
+	if cfg.traceFields {
+		if span := trace.FromContext(ctx); span != nil {
+			sc := span.SpanContext()
+			if sc.TraceID != (trace.TraceID{}) && sc.SpanID != (trace.SpanID{}) {
+				fields[traceIDField] = sc.TraceID.String()
+				fields[spanIDField] = sc.SpanID.String()
 			}
 		}
+	}
 
-		// Account ID retrieval -- ever so slightly hacky
-		if cfg.withAcctID {
-			md, _ := metadata.FromOutgoingContext(ctx)
-			if accountID, err := auth.GetAccountID(metadata.NewIncomingContext(ctx, md), cfg.acctIDKeyfunc); err == nil {
-				fields[auth.MultiTenancyField] = accountID
-			} else {
-				logger.Info(err)
-				fields[auth.MultiTenancyField] = valueUndefined
+	if cfg.peerFields {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			fields[peerAddressField] = p.Addr.String()
+		}
+		if ua, ok := gateway.Header(ctx, userAgentMetaKey); ok {
+			fields[userAgentField] = ua
+		}
+	}
+
+	if cfg.httpRoute {
+		cfg.httpRouteFields(ctx, fields)
+	}
+
+	ctx = cfg.withBaggage(ctx)
+
+	// Request ID -- defaults to on
+	var reqID string
+	ctx, reqID = cfg.withRequestID(ctx)
+	if cfg.withAcctID || cfg.subjectFields {
+		// the token parsed here is reused by the middlewares down the chain
+		ctx = auth.WithTokenCache(ctx)
+	}
+	if err := cfg.standardFields(ctx, method, reqID, fields); err != nil {
+		// a missing token is expected on public routes, a malformed one is not
+		lvl := logrus.InfoLevel
+		if errors.Is(err, auth.ErrMalformedToken) {
+			lvl = logrus.WarnLevel
+		} else if cfg.quietAcctID {
+			lvl = logrus.DebugLevel
+		}
+		logger.Logf(lvl, "%v", err)
+	}
+
+	// Custom log level
+	lvl := logger.Level()
+	// a level forced in the context wins over the service override of the
+	// registry, which wins over the header
+	regLvl, fromRegistry := cfg.levelRegistry.Get(service)
+	if fromRegistry {
+		lvl = regLvl
+	}
+	if cfg.dynamicLogLvl {
+		if logFlag, ok := gateway.HeaderValues(ctx, logFlagMetaKey); ok {
+			fields[logFlagFieldName] = logFlag[0]
+			ctx = context.WithValue(ctx, logFlagKey, logFlag[0])
+		}
+		forcedLvl, forced := forcedLevelFromContext(ctx)
+		if forced {
+			lvl = forcedLvl
+			fields[forcedLogLevelField] = true
+		} else if logLvl, ok := gateway.Header(ctx, logLevelMetaKey); ok && !fromRegistry {
+			var err error
+			lvl, err = logrus.ParseLevel(logLvl)
+			if err != nil {
+				// a bad header must not break the request, keep the base level
+				lvl = logger.Level()
+				logger.WithFields(logrus.Fields{invalidLogLevelField: logLvl}).Logf(logrus.WarnLevel, "invalid %s header: %v", logLevelMetaKey, err)
+				fields[invalidLogLevelField] = logLvl
 			}
 		}
+		fields[effectiveLevelField] = lvl.String()
+		ctx = context.WithValue(ctx, logLevelKey, lvl)
+	}
 
-		// inject logger into context (not done by normal grpc_logrus client interceptor)
-		newLogger := CopyLoggerWithLevel(logger, lvl)
-		newCtx := ctxlogrus.ToContext(ctx, newLogger.WithFields(fields))
+	if cfg.dumpMetadata {
+		if md, ok := metadata.FromOutgoingContext(ctx); ok {
+			fields[requestMetadataField] = cfg.redactMetadata(md)
+		}
+	}
 
-		var sentinelValue bool
-		err = invoker(context.WithValue(newCtx, sentinelKey, &sentinelValue), method, req, reply, cc, opts...)
+	for k, v := range cfg.staticFields {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
 
-		// if the sentinel is set, no middlewares had errors, and it is assumed the
-		// server will log the call instead of the gateway doing so
-		if sentinelValue {
-			return
+	for _, extract := range cfg.extractors {
+		if key, value, ok := safeExtract(ctx, logger, extract); ok {
+			fields[key] = value
 		}
+	}
 
-		// catch any changes made down the middleware chain by re-extracting
-		resLogger := ctxlogrus.Extract(newCtx)
+	cfg.renameFields(fields)
+	call := &gwCall{
+		cfg:       cfg,
+		method:    method,
+		startTime: startTime,
+		sampled:   cfg.sampler == nil || cfg.sampler(method),
+	}
+	if cfg.singleEntry {
+		// the fields are only merged into the lines of the call
+		call.ctx, call.logger = ctx, logger
+		if lvl != logger.Level() {
+			call.logger = logger.WithLevel(lvl)
+		}
+		call.fields = make(logrus.Fields, len(fields))
+		for k, v := range fields {
+			call.fields[k] = v
+		}
+		return call
+	}
+	// inject logger into context (not done by normal grpc_logrus client interceptor)
+	call.logger = logger.WithLevel(lvl).WithFields(fields)
+	call.ctx = loggerToContext(ctx, call.logger)
+	return call
+}
 
-		durField, durVal := grpc_logrus.DurationToTimeMillisField(time.Now().Sub(startTime))
-		fields = logrus.Fields{
-			durField:    durVal,
-			"grpc.code": status.Code(err).String(),
+// accountID returns the account id from the token and the claim it was read
+// from, which is empty for the default claims of auth.GetAccountID, or else
+// from the metadata set by auth.WithOutgoingAccountID when enabled
+func (cfg *gwLogCfg) accountID(ctx context.Context) (string, string, error) {
+	if cfg.trustedTenant {
+		if accountID, ok := metadataAccountID(ctx); ok {
+			return accountID, accountIDMetadataSource, nil
 		}
-		// set error message field
-		if err != nil {
-			fields[logrus.ErrorKey] = err
+	}
+	accountID, source, err := cfg.tokenAccountID(ctx)
+	if cfg.metadataTenant && errors.Is(err, auth.ErrNoToken) {
+		// the calls without inbound request carry the account id set by
+		// auth.WithOutgoingAccountID instead of a token
+		if accountID, ok := metadataAccountID(ctx); ok {
+			return accountID, accountIDMetadataSource, nil
+		}
+	}
+	return accountID, source, err
+}
+
+func metadataAccountID(ctx context.Context) (string, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get(auth.MultiTenancyField); len(vals) > 0 && vals[0] != "" {
+		return vals[0], true
+	}
+	return "", false
+}
+
+func (cfg *gwLogCfg) tokenAccountID(ctx context.Context) (string, string, error) {
+	if cfg.acctIDExtract != nil {
+		accountID, err := cfg.acctIDExtract.ExtractAccountID(ctx)
+		return accountID, "", err
+	}
+	if len(cfg.acctIDClaims) == 0 {
+		accountID, err := auth.GetAccountID(ctx, cfg.acctIDKeyfunc)
+		return accountID, "", err
+	}
+	for _, claim := range cfg.acctIDClaims {
+		value, err := auth.GetClaim(ctx, cfg.acctIDKeyfunc, claim)
+		if err != nil && !errors.Is(err, auth.ErrMissingClaim) {
+			return "", "", err
+		}
+		if accountID := fmt.Sprint(value); err == nil && accountID != "" {
+			return accountID, claim, nil
+		}
+	}
+	return "", "", errMissingAccountID
+}
+
+// addSubjectFields adds the subject and the expiry of the token, if any
+func (cfg *gwLogCfg) addSubjectFields(ctx context.Context, fields logrus.Fields) {
+	if sub, err := auth.GetStringClaim(ctx, cfg.acctIDKeyfunc, "sub"); err == nil && sub != "" {
+		fields[subjectField] = sub
+	}
+	if exp, err := auth.GetClaim(ctx, cfg.acctIDKeyfunc, "exp"); err == nil {
+		if sec, ok := exp.(float64); ok {
+			fields[tokenExpiryField] = time.Unix(int64(sec), 0).UTC().Format(time.RFC3339)
+		}
+	}
+}
+
+// parsedMethods caches the service and method names of the full methods, which
+// form a small fixed set
+var parsedMethods sync.Map
+
+type parsedMethod struct {
+	service, method string
+}
+
+// splitMethod returns the service and method names of a /service/Method full
+// method
+func splitMethod(fullMethod string) (string, string) {
+	if v, ok := parsedMethods.Load(fullMethod); ok {
+		pm := v.(parsedMethod)
+		return pm.service, pm.method
+	}
+	pm := parsedMethod{service: path.Dir(fullMethod)[1:], method: path.Base(fullMethod)}
+	parsedMethods.Store(fullMethod, pm)
+	return pm.service, pm.method
+}
+
+// safeExtract calls the extractor, recovering from its panics
+func safeExtract(ctx context.Context, logger Logger, extract FieldExtractor) (key string, value interface{}, ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Logf(logrus.WarnLevel, "recovered from panic in field extractor: %v", p)
+			key, value, ok = "", nil, false
 		}
+	}()
+	return extract(ctx)
+}
 
-		// print log message with all fields
-		resLogger = resLogger.WithFields(fields)
-		levelLogf(resLogger, cfg.codeToLevel(status.Code(err)), "finished client unary call with code "+status.Code(err).String())
+// withRequestID adds the request-id, generated if missing, to the outgoing
+// metadata unless it is disabled
+func (cfg *gwLogCfg) withRequestID(ctx context.Context) (context.Context, string) {
+	if cfg.noRequestID {
+		return ctx, ""
+	}
+	reqID, exists := cfg.requestIDFromContext(ctx)
+	if !exists || reqID == "" {
+		reqID = requestid.New()
+	}
+	return metadata.AppendToOutgoingContext(ctx, cfg.requestIDKey, reqID), reqID
+}
 
+// isIgnored reports whether the logs of the method are disabled
+func (cfg *gwLogCfg) isIgnored(method string) bool {
+	if _, ok := cfg.ignored[method]; ok {
+		return true
+	}
+	for _, prefix := range cfg.ignoredPrefix {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestIDFromContext looks the request-id up under the configured key, the
+// default key also accepts the deprecated one like requestid.FromContext
+func (cfg *gwLogCfg) requestIDFromContext(ctx context.Context) (string, bool) {
+	if cfg.requestIDKey == requestid.MetadataKey() {
+		return requestid.FromContext(ctx)
+	}
+	return gateway.Header(ctx, cfg.requestIDKey)
+}
+
+// redactMetadata returns a copy of md with the values of the redacted keys masked
+func (cfg *gwLogCfg) redactMetadata(md metadata.MD) map[string][]string {
+	res := make(map[string][]string, len(md))
+	for k, vs := range md {
+		if _, ok := cfg.redactedKeys[strings.ToLower(k)]; ok {
+			res[k] = []string{valueRedacted}
+			continue
+		}
+		res[k] = append([]string(nil), vs...)
+	}
+	return res
+}
+
+// finish emits the final log line of the call. Unless a finish message func is
+// configured, the message is the format with the resolved status code as its
+// single verb.
+func (c *gwCall) finish(err error, format string, extra logrus.Fields) {
+	code := status.Code(err)
+	c.observe(code)
+	if c.silent || code == codes.OK && !c.sampled {
 		return
 	}
+
+	// catch any changes made down the middleware chain by re-extracting
+	resLogger := c.logger
+	if c.fields == nil {
+		resLogger = loggerFromContext(c.ctx, c.logger)
+	}
+
+	durField, durVal := c.cfg.durationField(time.Now().Sub(c.startTime))
+	fields := getFields()
+	defer putFields(fields)
+	fields[durField] = durVal
+	fields["grpc.code"] = code.String()
+	if reason, ok := cancellationReason(c.ctx, code); ok {
+		fields[cancellationField] = reason
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	// set error message field
+	if err != nil {
+		fields[logrus.ErrorKey] = err
+		if details := statusDetails(err); len(details) > 0 {
+			fields[errorDetailsField] = details
+		}
+	}
+
+	c.cfg.renameFields(fields)
+	for k, v := range c.fields {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+
+	msg := fmt.Sprintf(format, code.String())
+	if c.cfg.finishMessage != nil {
+		msg = c.cfg.finishMessage(c.method, code)
+	}
+
+	// print log message with all fields
+	resLogger.WithFields(fields).Logf(c.level(code), "%s", msg)
+}
+
+// cancellationReason tells why a call ended with the Canceled or the
+// DeadlineExceeded code: the deadline of the context fired, the context was
+// canceled, e.g. by the client disconnecting or a parent cancellation, the
+// context is done for another reason, or else the call was ended by the remote
+func cancellationReason(ctx context.Context, code codes.Code) (string, bool) {
+	if code != codes.Canceled && code != codes.DeadlineExceeded {
+		return "", false
+	}
+	switch err := ctx.Err(); {
+	case err == nil:
+		return "remote", true
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded", true
+	case errors.Is(err, context.Canceled):
+		return "canceled", true
+	default:
+		return "context_done", true
+	}
+}
+
+// statusDetails renders the details of a gRPC status error as proto JSON, the
+// details that cannot be unpacked are rendered as the unpacking error
+func statusDetails(err error) []string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	var details []string
+	for _, any := range st.Proto().GetDetails() {
+		// the type of the detail is rendered under the @type key
+		data, err := protojson.Marshal(any)
+		if err != nil {
+			details = append(details, err.Error())
+			continue
+		}
+		details = append(details, string(data))
+	}
+	return details
 }
 
 // GatewayLoggingSentinelInterceptor is meant to be the last interceptor in the
 // client interceptor chain, it sets a value left in the context by the
 // GatewayLoggingInterceptor so that it knows whether the call makes it to the
 // server, and thus the server will log the call, and the gateway doesn't need to.
+// The value is set again on every attempt, e.g. of a retry interceptor between
+// the two, and cleared when the attempt is failed by the transport, see
+// reachedServer.
 func GatewayLoggingSentinelInterceptor() grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
-		val := ctx.Value(sentinelKey)
-		if val == nil {
+		succeeded, ok := ctx.Value(sentinelKey).(*bool)
+		if !ok {
 			return invoker(ctx, method, req, reply, cc, opts...)
 		}
-		succeeded, ok := val.(*bool)
-		if ok {
-			*succeeded = true
-		}
-		return invoker(ctx, method, req, reply, cc, opts...)
+		*succeeded = true
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		*succeeded = reachedServer(err)
+		return err
+	}
+}
+
+// reachedServer reports whether the server is assumed to have handled, and
+// logged, the call that returned err. The codes the client transport fails
+// the calls with count as not reached, the gateway then logs the calls the
+// server failed with them too.
+func reachedServer(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return false
 	}
+	return true
 }
@@ -0,0 +1,1563 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	grpc_logrus "github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	"github.com/armezit/atlas-app-toolkit/requestid"
+)
+
+// newGatewayTestLogger returns a JSON logger writing to the returned buffer
+func newGatewayTestLogger(lvl logrus.Level) (*logrus.Logger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	logger := New(lvl.String())
+	logger.Out = out
+	return logger, out
+}
+
+// gatewayLogEntries decodes every JSON log line written to out
+func gatewayLogEntries(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("unable to decode log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestGatewayLoggingInterceptor(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, testJWT))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "not found")
+	}
+
+	err := interceptor(ctx, testFullMethod, nil, nil, nil, invoker)
+	assert.Error(t, err)
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "app.Object", entries[0][DefaultGRPCServiceKey])
+		assert.Equal(t, testMethod, entries[0][DefaultGRPCMethodKey])
+		assert.Equal(t, codes.NotFound.String(), entries[0][DefaultGRPCCodeKey])
+		assert.NotEmpty(t, entries[0]["X-Request-ID"])
+		assert.Equal(t, "finished client unary call with code NotFound", entries[0]["msg"])
+	}
+}
+
+func TestGatewayLoggingInterceptor_NilCodeFunc(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithCodeFunc(nil))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Internal, "boom")
+	}
+	assert.NotPanics(t, func() {
+		assert.Error(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+	})
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, grpc_logrus.DefaultCodeToLevel(codes.Internal).String(), entries[0]["level"])
+	}
+}
+
+func TestGatewayLoggingInterceptor_Deadline(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "not found")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Error(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+	assert.Error(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 2) {
+		assert.NotEmpty(t, entries[0]["grpc.request.deadline"])
+		if timeout, ok := entries[0]["grpc.request.timeout_ms"].(float64); assert.True(t, ok) {
+			assert.InDelta(t, 5000, timeout, 1000)
+		}
+		// without a deadline both fields are omitted
+		assert.NotContains(t, entries[1], "grpc.request.deadline")
+		assert.NotContains(t, entries[1], "grpc.request.timeout_ms")
+	}
+}
+
+func TestGatewayLoggingInterceptor_Cancellation(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	for name, tc := range map[string]struct {
+		ctx      context.Context
+		code     codes.Code
+		expected interface{}
+	}{
+		"deadline exceeded": {ctx: expired, code: codes.DeadlineExceeded, expected: "deadline_exceeded"},
+		"canceled":          {ctx: canceled, code: codes.Canceled, expected: "canceled"},
+		"remote":            {ctx: context.Background(), code: codes.DeadlineExceeded, expected: "remote"},
+		"other code":        {ctx: canceled, code: codes.Unavailable},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger)
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return status.Error(tc.code, "failed")
+			}
+			assert.Error(t, interceptor(tc.ctx, testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.expected, entries[0][cancellationField])
+				if tc.expected == nil {
+					assert.NotContains(t, entries[0], cancellationField)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_Sentinel(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+	sentinel := GatewayLoggingSentinelInterceptor()
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return sentinel(ctx, method, req, reply, cc, func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		}, opts...)
+	}
+
+	err := interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+}
+
+func TestGatewayLoggingInterceptor_AlwaysLog(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithAlwaysLog())
+	sentinel := GatewayLoggingSentinelInterceptor()
+
+	var sentinelSet bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return sentinel(ctx, method, req, reply, cc, func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			sentinelSet, _ = SentinelValueFromCtx(ctx)
+			return nil
+		}, opts...)
+	}
+
+	assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+	assert.True(t, sentinelSet)
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, codes.OK.String(), entries[0][DefaultGRPCCodeKey])
+	}
+}
+
+func TestGatewayLoggingInterceptor_SentinelDebugLog(t *testing.T) {
+	for name, lvl := range map[string]logrus.Level{"info": logrus.InfoLevel, "debug": logrus.DebugLevel} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(lvl)
+			interceptor := GatewayLoggingInterceptor(logger, WithSentinelDebugLog())
+			sentinel := GatewayLoggingSentinelInterceptor()
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return sentinel(ctx, method, req, reply, cc, func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+					return nil
+				}, opts...)
+			}
+
+			assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+			entries := gatewayLogEntries(t, out)
+			if lvl < logrus.DebugLevel {
+				assert.Empty(t, entries)
+				return
+			}
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, "debug", entries[0]["level"])
+				assert.Equal(t, codes.OK.String(), entries[0][DefaultGRPCCodeKey])
+				assert.Contains(t, entries[0], "grpc.time_ms")
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_SentinelRetry(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+	sentinel := GatewayLoggingSentinelInterceptor()
+
+	// the first attempt reaches the server, which fails it with a retryable
+	// code, the retry then fails at the transport
+	attempts := []error{status.Error(codes.Aborted, "conflict"), status.Error(codes.Unavailable, "connection refused")}
+	retry := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) (err error) {
+		for _, attemptErr := range attempts {
+			err = sentinel(ctx, method, req, reply, cc, func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				return attemptErr
+			}, opts...)
+		}
+		return err
+	}
+
+	assert.Error(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, retry))
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, codes.Unavailable.String(), entries[0][DefaultGRPCCodeKey])
+	}
+
+	// a retry reaching the server leaves the logging to the server
+	out.Reset()
+	attempts = []error{status.Error(codes.Unavailable, "connection refused"), nil}
+	assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, retry))
+	assert.Empty(t, out.String())
+}
+
+// fakeClientStream replays the configured responses on RecvMsg
+type fakeClientStream struct {
+	grpc.ClientStream
+	ctx       context.Context
+	responses int
+	err       error
+}
+
+func (s *fakeClientStream) Context() context.Context { return s.ctx }
+
+func (s *fakeClientStream) SendMsg(m interface{}) error { return nil }
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if s.responses > 0 {
+		s.responses--
+		return nil
+	}
+	if s.err != nil {
+		return s.err
+	}
+	return io.EOF
+}
+
+func TestGatewayLoggingStreamInterceptor(t *testing.T) {
+	for name, tc := range map[string]struct {
+		streamErr error
+		code      codes.Code
+	}{
+		"eof":   {code: codes.OK},
+		"error": {streamErr: status.Error(codes.Unavailable, "gone"), code: codes.Unavailable},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingStreamInterceptor(logger)
+
+			streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &fakeClientStream{ctx: ctx, responses: 2, err: tc.streamErr}, nil
+			}
+
+			cs, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, testFullMethod, streamer)
+			assert.NoError(t, err)
+
+			assert.NoError(t, cs.SendMsg(nil))
+			for cs.RecvMsg(nil) == nil {
+			}
+			// the finish line must be emitted only once
+			cs.RecvMsg(nil)
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 2) {
+				assert.Equal(t, "started client streaming call", entries[0]["msg"])
+				assert.Equal(t, tc.code.String(), entries[1][DefaultGRPCCodeKey])
+				assert.Equal(t, float64(1), entries[1][requestMessagesField])
+				assert.Equal(t, float64(2), entries[1][responseMessagesField])
+				assert.Equal(t, entries[0]["X-Request-ID"], entries[1]["X-Request-ID"])
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingStreamInterceptor_Sentinel(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingStreamInterceptor(logger)
+	sentinel := GatewayLoggingSentinelStreamInterceptor()
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return sentinel(ctx, desc, cc, method, func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{ctx: ctx}, nil
+		}, opts...)
+	}
+
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, testFullMethod, streamer)
+	assert.NoError(t, err)
+	assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+	assert.Empty(t, out.String())
+}
+
+func TestGatewayLoggingStreamInterceptor_SentinelDebugLog(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.DebugLevel)
+	interceptor := GatewayLoggingStreamInterceptor(logger, WithSentinelDebugLog())
+	sentinel := GatewayLoggingSentinelStreamInterceptor()
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return sentinel(ctx, desc, cc, method, func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{ctx: ctx}, nil
+		}, opts...)
+	}
+
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, testFullMethod, streamer)
+	assert.NoError(t, err)
+	assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "debug", entries[0]["level"])
+		assert.Equal(t, "debug", entries[1]["level"])
+		assert.Equal(t, codes.OK.String(), entries[1][DefaultGRPCCodeKey])
+	}
+}
+
+func TestGatewayLoggingStreamInterceptor_Failed(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingStreamInterceptor(logger)
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, status.Error(codes.Unavailable, "no connection")
+	}
+
+	_, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, testFullMethod, streamer)
+	assert.Error(t, err)
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "finished client streaming call with code Unavailable", entries[0]["msg"])
+		assert.Equal(t, "rpc error: code = Unavailable desc = no connection", entries[0][logrus.ErrorKey])
+	}
+}
+
+func TestGatewayLoggingInterceptor_Sampling(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opt     GWLogOption
+		err     error
+		entries int
+	}{
+		"sampled out success":    {opt: WithSampling(0), entries: 0},
+		"sampled in success":     {opt: WithSampling(1), entries: 1},
+		"sampled out error":      {opt: WithSampling(0), err: status.Error(codes.Internal, "failed"), entries: 1},
+		"method sampler dropped": {opt: WithMethodSampler(func(m string) bool { return m != testFullMethod }), entries: 0},
+		"method sampler kept":    {opt: WithMethodSampler(func(m string) bool { return m == testFullMethod }), entries: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opt)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				// sampled out calls still propagate the request id
+				md, _ := metadata.FromOutgoingContext(ctx)
+				assert.NotEmpty(t, md.Get("X-Request-ID"))
+				return tc.err
+			}
+
+			interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+			assert.Len(t, gatewayLogEntries(t, out), tc.entries)
+		})
+	}
+}
+
+// countingHook counts the entries fired for its levels
+type countingHook struct {
+	levels []logrus.Level
+	fired  int
+}
+
+func (h *countingHook) Levels() []logrus.Level { return h.levels }
+
+func (h *countingHook) Fire(*logrus.Entry) error {
+	h.fired++
+	return nil
+}
+
+func TestCopyLoggerWithLevel(t *testing.T) {
+	logger, _ := newGatewayTestLogger(logrus.InfoLevel)
+	hook := &countingHook{levels: logrus.AllLevels}
+	logger.AddHook(hook)
+
+	copied := CopyLoggerWithLevel(logger, logrus.DebugLevel)
+	assert.Equal(t, logrus.DebugLevel, copied.Level)
+	assert.Equal(t, logger.Out, copied.Out)
+	assert.Equal(t, logger.Formatter, copied.Formatter)
+
+	// hooks added to the copy must not leak into the original logger
+	copied.AddHook(&countingHook{levels: logrus.AllLevels})
+	assert.Len(t, logger.Hooks[logrus.InfoLevel], 1)
+	assert.Len(t, copied.Hooks[logrus.InfoLevel], 2)
+}
+
+func TestGatewayLoggingInterceptor_DynamicLevelKeepsHooks(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.WarnLevel)
+	hook := &countingHook{levels: logrus.AllLevels}
+	logger.AddHook(hook)
+	interceptor := GatewayLoggingInterceptor(logger, EnableDynamicLogLevel)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(logLevelMetaKey, "debug"))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+	assert.Equal(t, 1, hook.fired)
+	// the JSON formatter of the base logger is preserved
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "info", entries[0]["level"])
+		assert.Equal(t, "debug", entries[0][effectiveLevelField])
+	}
+}
+
+func TestGatewayLoggingInterceptor_RedactedMetadata(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts     []GWLogOption
+		expected map[string]interface{}
+	}{
+		"default redaction": {
+			opts: []GWLogOption{WithMetadataDump()},
+			expected: map[string]interface{}{
+				"authorization": []interface{}{valueRedacted},
+				"cookie":        []interface{}{valueRedacted},
+				"x-secret":      []interface{}{"s3cr3t"},
+			},
+		},
+		"custom redaction": {
+			opts: []GWLogOption{WithMetadataDump(), WithRedactedMetadataKeys("X-Secret")},
+			expected: map[string]interface{}{
+				"authorization": []interface{}{valueRedacted},
+				"cookie":        []interface{}{valueRedacted},
+				"x-secret":      []interface{}{valueRedacted},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			md := metadata.Pairs(testAuthorizationHeader, testJWT, "Cookie", "session=1", "X-Secret", "s3cr3t")
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				// the metadata sent to the server is left untouched
+				md, _ := metadata.FromOutgoingContext(ctx)
+				assert.Equal(t, []string{testJWT}, md.Get(testAuthorizationHeader))
+				return nil
+			}
+
+			assert.NoError(t, interceptor(metadata.NewOutgoingContext(context.Background(), md), testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				logged, _ := entries[0][requestMetadataField].(map[string]interface{})
+				for k, v := range tc.expected {
+					assert.Equal(t, v, logged[k], k)
+				}
+				assert.NotContains(t, out.String(), testJWT)
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_RequestIDKey(t *testing.T) {
+	const correlationKey = "X-Correlation-ID"
+
+	for name, tc := range map[string]struct {
+		md       metadata.MD
+		expected string
+	}{
+		"forwarded": {md: metadata.Pairs(correlationKey, testRequestID), expected: testRequestID},
+		// the default key is not consulted once a custom key is configured
+		"generated": {md: metadata.Pairs("X-Request-ID", testRequestID)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithRequestIDKey(correlationKey))
+
+			var propagated string
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				ids := md.Get(correlationKey)
+				propagated = ids[len(ids)-1]
+				return nil
+			}
+			assert.NoError(t, interceptor(metadata.NewOutgoingContext(context.Background(), tc.md), testFullMethod, nil, nil, nil, invoker))
+
+			if tc.expected != "" {
+				assert.Equal(t, tc.expected, propagated)
+			} else {
+				assert.NotEqual(t, testRequestID, propagated)
+				assert.NotEmpty(t, propagated)
+			}
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, propagated, entries[0][correlationKey])
+				assert.NotContains(t, entries[0], "X-Request-ID")
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_RequestIDGenerator(t *testing.T) {
+	defer requestid.SetConfig(requestid.Config{})
+	requestid.SetConfig(requestid.Config{Generator: func() string { return "dc1-0001" }})
+
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "dc1-0001", entries[0][requestid.DefaultRequestIDKey])
+	}
+}
+
+func TestGatewayLoggingInterceptor_RequestIDUUIDv7(t *testing.T) {
+	defer requestid.SetConfig(requestid.Config{})
+	requestid.SetConfig(requestid.Config{Generator: requestid.UUIDv7Generator})
+
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		reqID, _ := entries[0][requestid.DefaultRequestIDKey].(string)
+		if id, err := uuid.Parse(reqID); assert.NoError(t, err) {
+			assert.Equal(t, uuid.Version(7), id.Version())
+		}
+	}
+}
+
+func TestGatewayLoggingInterceptor_ParentRequestID(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	ctx := requestid.NewContextWithParent(context.Background(), testRequestID, "parent-request-id")
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, testRequestID, entries[0][requestid.DefaultRequestIDKey])
+		assert.Equal(t, "parent-request-id", entries[0][requestid.ParentRequestIDLogKey])
+	}
+}
+
+func TestGatewayLoggingInterceptor_TraceFields(t *testing.T) {
+	traceCtx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+
+	for name, tc := range map[string]struct {
+		ctx     context.Context
+		opts    []GWLogOption
+		traceID interface{}
+		spanID  interface{}
+	}{
+		"enabled":  {ctx: traceCtx, opts: []GWLogOption{WithTraceFields()}, traceID: span.SpanContext().TraceID.String(), spanID: span.SpanContext().SpanID.String()},
+		"disabled": {ctx: traceCtx},
+		"no span":  {ctx: context.Background(), opts: []GWLogOption{WithTraceFields()}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(tc.ctx, testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.traceID, entries[0][traceIDField])
+				assert.Equal(t, tc.spanID, entries[0][spanIDField])
+				if tc.traceID == nil {
+					assert.NotContains(t, entries[0], traceIDField)
+					assert.NotContains(t, entries[0], spanIDField)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_IgnoredMethods(t *testing.T) {
+	const healthCheck = "/grpc.health.v1.Health/Check"
+
+	for name, tc := range map[string]struct {
+		opts    []GWLogOption
+		method  string
+		ignored bool
+	}{
+		"method":       {opts: []GWLogOption{WithIgnoredMethods(healthCheck)}, method: healthCheck, ignored: true},
+		"prefix":       {opts: []GWLogOption{WithIgnoredServicePrefix("/grpc.reflection.")}, method: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", ignored: true},
+		"other method": {opts: []GWLogOption{WithIgnoredMethods(healthCheck), WithIgnoredServicePrefix("/grpc.reflection.")}, method: testFullMethod},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			var reqID string
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				reqID = strings.Join(md.Get("X-Request-ID"), ",")
+				return status.Error(codes.Unavailable, "unavailable")
+			}
+			ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("X-Request-ID", testRequestID))
+			assert.Error(t, interceptor(ctx, tc.method, nil, nil, nil, invoker))
+
+			assert.Contains(t, reqID, testRequestID)
+			entries := gatewayLogEntries(t, out)
+			if tc.ignored {
+				assert.Empty(t, entries)
+			} else {
+				assert.Len(t, entries, 1)
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingStreamInterceptor_IgnoredMethods(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingStreamInterceptor(logger, WithIgnoredServicePrefix("/grpc.health.v1.Health/"))
+
+	var reqID string
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		reqID = strings.Join(md.Get("X-Request-ID"), ",")
+		return &fakeClientStream{ctx: ctx}, nil
+	}
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/grpc.health.v1.Health/Watch", streamer)
+	if assert.NoError(t, err) {
+		assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+	}
+
+	assert.NotEmpty(t, reqID)
+	assert.Empty(t, gatewayLogEntries(t, out))
+}
+
+func TestGatewayLoggingInterceptor_InvalidLogLevel(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, EnableDynamicLogLevel)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(logLevelMetaKey, "verbose"))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "warning", entries[0]["level"])
+		assert.Equal(t, "verbose", entries[0][invalidLogLevelField])
+		// the base level is kept and the finish line records the bad value
+		assert.Equal(t, "info", entries[1]["level"])
+		assert.Equal(t, "verbose", entries[1][invalidLogLevelField])
+		assert.Equal(t, "info", entries[1][effectiveLevelField])
+	}
+}
+
+func TestGatewayLoggingInterceptor_LogFlagFromContext(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts     []GWLogOption
+		header   bool
+		expected bool
+	}{
+		"flagged":          {opts: []GWLogOption{EnableDynamicLogLevel}, header: true, expected: true},
+		"not flagged":      {opts: []GWLogOption{EnableDynamicLogLevel}},
+		"dynamic disabled": {header: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, _ := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			ctx := context.Background()
+			if tc.header {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(logFlagMetaKey, "foobar"))
+			}
+			var flag string
+			var ok bool
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				flag, ok = LogFlagFromContext(ctx)
+				return nil
+			}
+			assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+			assert.Equal(t, tc.expected, ok)
+			if tc.expected {
+				assert.Equal(t, "foobar", flag)
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_LevelFromContext(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts     []GWLogOption
+		header   string
+		expected interface{}
+	}{
+		"header":           {opts: []GWLogOption{EnableDynamicLogLevel}, header: "debug", expected: logrus.DebugLevel},
+		"no header":        {opts: []GWLogOption{EnableDynamicLogLevel}, expected: logrus.InfoLevel},
+		"invalid header":   {opts: []GWLogOption{EnableDynamicLogLevel}, header: "verbose", expected: logrus.InfoLevel},
+		"dynamic disabled": {header: "debug"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, _ := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			ctx := context.Background()
+			if tc.header != "" {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(logLevelMetaKey, tc.header))
+			}
+			var lvl interface{}
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				if l, ok := LevelFromContext(ctx); ok {
+					lvl = l
+				}
+				return nil
+			}
+			assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+			assert.Equal(t, tc.expected, lvl)
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_FieldExtractors(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithFieldExtractors(
+		func(ctx context.Context) (string, interface{}, bool) { return "region", "eu-west-1", true },
+		func(ctx context.Context) (string, interface{}, bool) { return "cohort", "a", true },
+		func(ctx context.Context) (string, interface{}, bool) { panic("boom") },
+		func(ctx context.Context) (string, interface{}, bool) { return "skipped", "value", false },
+		func(ctx context.Context) (string, interface{}, bool) { return "cohort", "b", true },
+	))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "warning", entries[0]["level"])
+		assert.Equal(t, "eu-west-1", entries[1]["region"])
+		assert.Equal(t, "b", entries[1]["cohort"])
+		assert.NotContains(t, entries[1], "skipped")
+	}
+}
+
+func TestGatewayLoggingInterceptor_AccountIDClaims(t *testing.T) {
+	for name, tc := range map[string]struct {
+		claims    []string
+		accountID interface{}
+		source    interface{}
+	}{
+		"fallback":    {claims: []string{"org_id", "account_id"}, accountID: testAccID, source: "account_id"},
+		"first match": {claims: []string{"custom_field", "account_id"}, accountID: "test-custom-field", source: "custom_field"},
+		"no match":    {claims: []string{"org_id"}, accountID: valueUndefined},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithAccountIDClaims(nil, tc.claims...))
+
+			ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, testJWT))
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.NotEmpty(t, entries) {
+				finish := entries[len(entries)-1]
+				assert.Equal(t, tc.accountID, finish[auth.MultiTenancyField])
+				assert.Equal(t, tc.source, finish[accountIDSourceField])
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_AccountIDExtractor(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	extractor := auth.AccountIDExtractorFunc(func(ctx context.Context) (string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if vals := md.Get("x-legacy-token"); len(vals) > 0 {
+			return strings.TrimPrefix(vals[0], "v1."), nil
+		}
+		return "", auth.ErrNoToken
+	})
+	interceptor := GatewayLoggingInterceptor(logger, WithAccountIDExtractor(extractor))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-legacy-token", "v1.id-legacy", testAuthorizationHeader, testJWT))
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.NotEmpty(t, entries) {
+		finish := entries[len(entries)-1]
+		assert.Equal(t, "id-legacy", finish[auth.MultiTenancyField])
+		assert.NotContains(t, finish, accountIDSourceField)
+	}
+}
+
+func TestGatewayLoggingInterceptor_OutgoingAccountID(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ctx       context.Context
+		opts      []GWLogOption
+		accountID interface{}
+		source    interface{}
+	}{
+		"internal call": {
+			ctx:       auth.WithOutgoingAccountID(context.Background(), "id-internal"),
+			opts:      []GWLogOption{WithMetadataAccountID()},
+			accountID: "id-internal",
+			source:    accountIDMetadataSource,
+		},
+		// any anonymous caller can set the metadata
+		"disabled by default": {ctx: auth.WithOutgoingAccountID(context.Background(), "id-internal"), accountID: valueUndefined},
+		"token wins with metadata enabled": {
+			ctx:       auth.WithOutgoingAccountID(metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, testJWT)), "id-internal"),
+			opts:      []GWLogOption{WithMetadataAccountID()},
+			accountID: testAccID,
+		},
+		"token wins": {
+			ctx:       auth.WithOutgoingAccountID(metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, testJWT)), "id-internal"),
+			accountID: testAccID,
+		},
+		"trusted metadata": {
+			ctx:       auth.WithOutgoingAccountID(metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, testJWT)), "id-internal"),
+			opts:      []GWLogOption{WithTrustedMetadataTenancy()},
+			accountID: "id-internal",
+			source:    accountIDMetadataSource,
+		},
+		"trusted metadata without token parsing": {
+			ctx:       auth.WithOutgoingAccountID(metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, "Bearer malformed")), "id-internal"),
+			opts:      []GWLogOption{WithTrustedMetadataTenancy()},
+			accountID: "id-internal",
+			source:    accountIDMetadataSource,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, append([]GWLogOption{EnableAccountID}, tc.opts...)...)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(tc.ctx, testFullMethod, nil, nil, nil, invoker))
+
+			// the final line follows the one of the missing token, if any
+			entries := gatewayLogEntries(t, out)
+			if assert.NotEmpty(t, entries) {
+				final := entries[len(entries)-1]
+				assert.Equal(t, tc.accountID, final[auth.MultiTenancyField])
+				assert.Equal(t, tc.source, final[accountIDSourceField])
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_AccountIDErrorLevel(t *testing.T) {
+	for name, tc := range map[string]struct {
+		md    metadata.MD
+		quiet bool
+		level string
+	}{
+		"no token":              {md: metadata.Pairs(), level: "info"},
+		"malformed token":       {md: metadata.Pairs(testAuthorizationHeader, "Bearer malformed"), level: "warning"},
+		"quiet no token":        {md: metadata.Pairs(), quiet: true, level: "debug"},
+		"quiet malformed token": {md: metadata.Pairs(testAuthorizationHeader, "Bearer malformed"), quiet: true, level: "warning"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.DebugLevel)
+			opts := []GWLogOption{EnableAccountID}
+			if tc.quiet {
+				opts = append(opts, WithQuietAccountID())
+			}
+			interceptor := GatewayLoggingInterceptor(logger, opts...)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(metadata.NewOutgoingContext(context.Background(), tc.md), testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 2) {
+				assert.Equal(t, tc.level, entries[0]["level"])
+				assert.Equal(t, valueUndefined, entries[1][auth.MultiTenancyField])
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_AnonymousMethods(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.DebugLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithAccountID(nil, "/app.Auth/Login", testFullMethod))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs())
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.NotContains(t, entries[0], auth.MultiTenancyField)
+	}
+
+	// the other methods are still expected to carry a token
+	out.Reset()
+	assert.NoError(t, interceptor(ctx, "/app.Object/Read", nil, nil, nil, invoker))
+	entries = gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "info", entries[0]["level"])
+		assert.Equal(t, valueUndefined, entries[1][auth.MultiTenancyField])
+	}
+}
+
+func TestGatewayLoggingInterceptor_StaticFields(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	static := logrus.Fields{
+		"version":             "1.2.3",
+		"region":              "eu-west-1",
+		DefaultGRPCMethodKey:  "static",
+		DefaultGRPCCodeKey:    "static",
+		"X-Request-ID":        "static",
+		grpc_logrus.KindField: "static",
+	}
+	interceptor := GatewayLoggingInterceptor(logger, WithStaticFields(static), WithAlwaysLog())
+	// the fields are copied
+	static["version"] = "4.5.6"
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "1.2.3", entries[0]["version"])
+		assert.Equal(t, "eu-west-1", entries[0]["region"])
+		assert.Equal(t, testMethod, entries[0][DefaultGRPCMethodKey])
+		assert.Equal(t, codes.OK.String(), entries[0][DefaultGRPCCodeKey])
+		assert.NotEqual(t, "static", entries[0]["X-Request-ID"])
+		assert.Equal(t, "gateway", entries[0][grpc_logrus.KindField])
+	}
+}
+
+func TestGatewayLoggingInterceptor_PeerFields(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4242}
+
+	for name, tc := range map[string]struct {
+		ctx       context.Context
+		address   interface{}
+		userAgent interface{}
+	}{
+		"peer": {
+			ctx:       peer.NewContext(metadata.NewOutgoingContext(context.Background(), metadata.Pairs("grpcgateway-user-agent", "curl/7.79.1")), &peer.Peer{Addr: addr}),
+			address:   "192.0.2.1:4242",
+			userAgent: "curl/7.79.1",
+		},
+		"in-process": {ctx: context.Background()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithPeerFields())
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(tc.ctx, testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.address, entries[0][peerAddressField])
+				assert.Equal(t, tc.userAgent, entries[0][userAgentField])
+				if tc.address == nil {
+					assert.NotContains(t, entries[0], peerAddressField)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_HTTPRouteFields(t *testing.T) {
+	annotated := func(t *testing.T, opts ...runtime.AnnotateContextOption) context.Context {
+		mux := runtime.NewServeMux(runtime.WithMetadata(HTTPRouteAnnotator))
+		req := httptest.NewRequest(http.MethodGet, "/v1/users/42", nil)
+		ctx, err := runtime.AnnotateContext(context.Background(), mux, req, testFullMethod, opts...)
+		if err != nil {
+			t.Fatalf("failed to annotate context: %v", err)
+		}
+		return ctx
+	}
+
+	for name, tc := range map[string]struct {
+		ctx    func(t *testing.T) context.Context
+		opts   []GWLogOption
+		path   interface{}
+		method interface{}
+	}{
+		"pattern": {
+			ctx:    func(t *testing.T) context.Context { return annotated(t, runtime.WithHTTPPathPattern("/v1/users/{id}")) },
+			opts:   []GWLogOption{WithHTTPRouteFields()},
+			path:   "/v1/users/{id}",
+			method: http.MethodGet,
+		},
+		"path": {
+			ctx:    func(t *testing.T) context.Context { return annotated(t) },
+			opts:   []GWLogOption{WithHTTPRouteFields()},
+			path:   "/v1/users/42",
+			method: http.MethodGet,
+		},
+		"custom keys": {
+			ctx: func(t *testing.T) context.Context {
+				return metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-original-uri", "/api/users", "x-original-method", http.MethodPost))
+			},
+			opts:   []GWLogOption{WithHTTPRouteKeys("x-original-uri", "x-original-method")},
+			path:   "/api/users",
+			method: http.MethodPost,
+		},
+		"grpc": {
+			ctx:  func(t *testing.T) context.Context { return context.Background() },
+			opts: []GWLogOption{WithHTTPRouteFields()},
+		},
+		"disabled": {
+			ctx: func(t *testing.T) context.Context { return annotated(t) },
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(tc.ctx(t), testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.path, entries[0][httpPathField])
+				assert.Equal(t, tc.method, entries[0][httpMethodField])
+				if tc.path == nil {
+					assert.NotContains(t, entries[0], httpPathField)
+					assert.NotContains(t, entries[0], httpMethodField)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_ForcedLevel(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts     []GWLogOption
+		header   string
+		expected interface{}
+	}{
+		"forced over header": {opts: []GWLogOption{EnableDynamicLogLevel}, header: "error", expected: true},
+		"forced":             {opts: []GWLogOption{EnableDynamicLogLevel}, expected: true},
+		"dynamic disabled":   {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			ctx := WithForcedLevel(context.Background(), logrus.DebugLevel)
+			if tc.header != "" {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(logLevelMetaKey, tc.header))
+			}
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				ctxlogrus.Extract(ctx).Debug("debug line")
+				return nil
+			}
+			assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if tc.expected == nil {
+				if assert.Len(t, entries, 1) {
+					assert.NotContains(t, entries[0], forcedLogLevelField)
+					assert.NotContains(t, entries[0], effectiveLevelField)
+				}
+				return
+			}
+			if assert.Len(t, entries, 2) {
+				assert.Equal(t, "debug", entries[0]["level"])
+				assert.Equal(t, tc.expected, entries[1][forcedLogLevelField])
+				assert.Equal(t, "debug", entries[1][effectiveLevelField])
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_FinishMessageFunc(t *testing.T) {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "not found")
+	}
+
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger)
+	interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+	defaults := gatewayLogEntries(t, out)
+
+	var gotMethod string
+	var gotCode codes.Code
+	logger, out = newGatewayTestLogger(logrus.InfoLevel)
+	interceptor = GatewayLoggingInterceptor(logger, WithFinishMessageFunc(func(method string, code codes.Code) string {
+		gotMethod, gotCode = method, code
+		return "gateway call " + method + " done"
+	}))
+	interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+	custom := gatewayLogEntries(t, out)
+
+	assert.Equal(t, testFullMethod, gotMethod)
+	assert.Equal(t, codes.NotFound, gotCode)
+	if assert.Len(t, defaults, 1) && assert.Len(t, custom, 1) {
+		assert.Equal(t, "finished client unary call with code NotFound", defaults[0]["msg"])
+		assert.Equal(t, "gateway call "+testFullMethod+" done", custom[0]["msg"])
+		for k := range defaults[0] {
+			assert.Contains(t, custom[0], k)
+		}
+		assert.Len(t, custom[0], len(defaults[0]))
+	}
+}
+
+func TestGatewayLoggingInterceptor_StatusDetails(t *testing.T) {
+	st, _ := status.New(codes.InvalidArgument, "invalid").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "name", Description: "required"}},
+	})
+
+	for name, tc := range map[string]struct {
+		err      error
+		expected []string
+	}{
+		"details":    {err: st.Err(), expected: []string{`{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"name","description":"required"}]}`}},
+		"no details": {err: status.Error(codes.InvalidArgument, "invalid")},
+		"not status": {err: io.ErrUnexpectedEOF},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return tc.err
+			}
+			assert.Error(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				if tc.expected == nil {
+					assert.NotContains(t, entries[0], errorDetailsField)
+				}
+				details, _ := entries[0][errorDetailsField].([]interface{})
+				if assert.Len(t, details, len(tc.expected)) {
+					for i, expected := range tc.expected {
+						// protojson output is not stable, compare the decoded JSON
+						assert.JSONEq(t, expected, details[i].(string))
+					}
+				}
+				assert.Equal(t, tc.err.Error(), entries[0][logrus.ErrorKey])
+			}
+		})
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	for _, method := range []string{testFullMethod, "/app.Object/", "/TestMethod", "app.Object/TestMethod", "/a/b/c", ""} {
+		// twice to cover the cached result
+		for i := 0; i < 2; i++ {
+			service, grpcMethod := splitMethod(method)
+			assert.Equal(t, path.Dir(method)[1:], service, method)
+			assert.Equal(t, path.Base(method), grpcMethod, method)
+		}
+	}
+}
+
+func BenchmarkSplitMethod(b *testing.B) {
+	b.Run("path", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = path.Dir(testFullMethod)[1:]
+			_ = path.Base(testFullMethod)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			splitMethod(testFullMethod)
+		}
+	})
+}
+
+func TestGatewayLoggingInterceptor_LogFlag(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, EnableDynamicLogLevel)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(logFlagMetaKey, "unique-id"))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "unique-id", entries[0][logFlagFieldName])
+	}
+}
+
+func TestGatewayLoggingInterceptor_ConcurrentCalls(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	var mu sync.Mutex
+	logger.Out = &lockedWriter{mu: &mu, w: out}
+	interceptor := GatewayLoggingInterceptor(logger, WithAlwaysLog())
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, method)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			method := fmt.Sprintf("/app.Object/Method%d", i)
+			interceptor(context.Background(), method, nil, nil, nil, invoker)
+		}(i)
+	}
+	wg.Wait()
+
+	// the fields of every call are logged intact despite the reuse of the maps
+	entries := gatewayLogEntries(t, out)
+	assert.Len(t, entries, 50)
+	for _, e := range entries {
+		assert.Equal(t, e["grpc.method"], e[logrus.ErrorKey].(string)[len("rpc error: code = NotFound desc = /app.Object/"):])
+		assert.Equal(t, "NotFound", e["grpc.code"])
+		assert.Equal(t, "app.Object", e["grpc.service"])
+	}
+}
+
+// lockedWriter serializes the writes of the concurrent calls
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+func BenchmarkGatewayLoggingInterceptor(b *testing.B) {
+	logger := New(logrus.InfoLevel.String())
+	logger.Out = ioutil.Discard
+	interceptor := GatewayLoggingInterceptor(logger, WithAlwaysLog())
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+		}
+	})
+}
+
+// infoCodeToLevel logs all the codes at info level
+func infoCodeToLevel(codes.Code) logrus.Level { return logrus.InfoLevel }
+
+func TestGatewayLoggingInterceptor_Quiet(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.WarnLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithCodeFunc(infoCodeToLevel), EnableAccountID)
+
+	var reqID string
+	var sentinel bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		reqID = strings.Join(md.Get("x-request-id"), ",")
+		sentinel, _ = SentinelValueFromCtx(ctx)
+		ctxlogrus.Extract(ctx).Warn("in call")
+		return status.Error(codes.Internal, "boom")
+	}
+	sentinelInterceptor := GatewayLoggingSentinelInterceptor()
+	assert.Error(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return sentinelInterceptor(ctx, method, req, reply, cc, invoker, opts...)
+	}))
+
+	assert.NotEmpty(t, reqID)
+	assert.True(t, sentinel)
+	// only the line of the call is logged, with the request-id
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "in call", entries[0]["msg"])
+		assert.Equal(t, reqID, entries[0]["X-Request-ID"])
+		assert.NotContains(t, entries[0], auth.MultiTenancyField)
+	}
+
+	// the calls of a request-dependent level are never quiet
+	out.Reset()
+	interceptor = GatewayLoggingInterceptor(logger, WithCodeFunc(infoCodeToLevel), EnableDynamicLogLevel)
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(logLevelMetaKey, "info"))
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}))
+	assert.Len(t, gatewayLogEntries(t, out), 1)
+}
+
+func BenchmarkGatewayLoggingInterceptor_Quiet(b *testing.B) {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	for name, lvl := range map[string]logrus.Level{"logged": logrus.InfoLevel, "quiet": logrus.WarnLevel} {
+		b.Run(name, func(b *testing.B) {
+			logger := New(lvl.String())
+			logger.Out = ioutil.Discard
+			interceptor := GatewayLoggingInterceptor(logger, WithCodeFunc(infoCodeToLevel), WithAlwaysLog())
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_SingleEntry(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithSingleEntry(), WithStaticFields(logrus.Fields{"region": "eu-west-1"}))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		// the logger is not injected in the context
+		ctxlogrus.AddFields(ctx, logrus.Fields{"added": true})
+		return status.Error(codes.NotFound, "not found")
+	}
+	ctx := requestid.NewContext(context.Background(), testRequestID)
+	assert.Error(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		service, method := splitMethod(testFullMethod)
+		assert.Equal(t, service, entries[0]["grpc.service"])
+		assert.Equal(t, method, entries[0]["grpc.method"])
+		assert.Equal(t, testRequestID, entries[0][requestid.DefaultRequestIDKey])
+		assert.Equal(t, "eu-west-1", entries[0]["region"])
+		assert.Equal(t, codes.NotFound.String(), entries[0]["grpc.code"])
+		assert.NotContains(t, entries[0], "added")
+	}
+}
+
+func BenchmarkGatewayLoggingInterceptor_SingleEntry(b *testing.B) {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	for name, opts := range map[string][]GWLogOption{
+		"two phase":    {WithAlwaysLog()},
+		"single entry": {WithAlwaysLog(), WithSingleEntry()},
+	} {
+		b.Run(name, func(b *testing.B) {
+			logger := New(logrus.InfoLevel.String())
+			logger.Out = ioutil.Discard
+			interceptor := GatewayLoggingInterceptor(logger, opts...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_FieldNames(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithFieldNames(map[string]string{
+		grpc_logrus.KindField: "kind",
+		"grpc.service":        "grpc.method",
+		"grpc.method":         "rpc.method",
+		"grpc.code":           "rpc.code",
+	}))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		ctxlogrus.Extract(ctx).Info("in call")
+		return status.Error(codes.NotFound, "not found")
+	}
+	interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 2) {
+		for _, entry := range entries {
+			assert.Equal(t, "gateway", entry["kind"])
+			assert.NotContains(t, entry, grpc_logrus.KindField)
+			// the renames are not chained
+			service, method := splitMethod(testFullMethod)
+			assert.Equal(t, service, entry["grpc.method"])
+			assert.Equal(t, method, entry["rpc.method"])
+			assert.NotContains(t, entry, "grpc.service")
+			assert.Equal(t, "grpc", entry[grpc_logrus.SystemField])
+		}
+		assert.Equal(t, "NotFound", entries[1]["rpc.code"])
+		assert.NotContains(t, entries[1], "grpc.code")
+	}
+}
+
+func TestGatewayLoggingInterceptor_DurationField(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts  []GWLogOption
+		field string
+	}{
+		"default":      {field: "grpc.time_ms"},
+		"microseconds": {opts: []GWLogOption{WithDurationField(time.Microsecond)}, field: "grpc.time_us"},
+		"nanoseconds":  {opts: []GWLogOption{WithDurationField(time.Nanosecond)}, field: "grpc.time_ns"},
+		"unsupported":  {opts: []GWLogOption{WithDurationField(time.Second)}, field: "grpc.time_ms"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, tc.opts...)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				time.Sleep(time.Millisecond)
+				return nil
+			}
+			assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				for _, field := range []string{"grpc.time_ms", "grpc.time_us", "grpc.time_ns"} {
+					if field != tc.field {
+						assert.NotContains(t, entries[0], field)
+					}
+				}
+				if assert.Contains(t, entries[0], tc.field) {
+					assert.Greater(t, entries[0][tc.field].(float64), 0.0)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_SubjectField(t *testing.T) {
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": expiry.Unix(),
+	}).SignedString([]byte("secret"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for name, tc := range map[string]struct {
+		token   string
+		subject interface{}
+		expiry  interface{}
+	}{
+		"claims":         {token: "Bearer " + signed, subject: "user-1", expiry: expiry.Format(time.RFC3339)},
+		"missing claims": {token: testJWT},
+		"no token":       {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithSubjectField())
+
+			ctx := context.Background()
+			if tc.token != "" {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(testAuthorizationHeader, tc.token))
+			}
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.subject, entries[0][subjectField])
+				assert.Equal(t, tc.expiry, entries[0][tokenExpiryField])
+				if tc.subject == nil {
+					assert.NotContains(t, entries[0], subjectField)
+					assert.NotContains(t, entries[0], tokenExpiryField)
+				}
+				// independent of the account id field
+				assert.NotContains(t, entries[0], auth.MultiTenancyField)
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_CodeLevelOverrideForMethod(t *testing.T) {
+	const pollMethod = "/app.Object/Poll"
+	for name, tc := range map[string]struct {
+		method string
+		err    error
+		level  string
+	}{
+		"overridden code":     {method: pollMethod, err: status.Error(codes.Canceled, "canceled"), level: "debug"},
+		"other code":          {method: pollMethod, err: status.Error(codes.Internal, "internal"), level: "error"},
+		"other method":        {method: testFullMethod, err: status.Error(codes.Canceled, "canceled"), level: "warning"},
+		"overridden deadline": {method: pollMethod, err: status.Error(codes.DeadlineExceeded, "deadline"), level: "info"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.DebugLevel)
+			interceptor := GatewayLoggingInterceptor(logger,
+				WithCodeFunc(func(code codes.Code) logrus.Level {
+					if code == codes.Canceled {
+						return logrus.WarnLevel
+					}
+					return grpc_logrus.DefaultCodeToLevel(code)
+				}),
+				WithCodeLevelOverrideForMethod(pollMethod, codes.Canceled, logrus.DebugLevel),
+				WithCodeLevelOverrideForMethod(pollMethod, codes.DeadlineExceeded, logrus.InfoLevel),
+			)
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return tc.err
+			}
+			assert.Error(t, interceptor(context.Background(), tc.method, nil, nil, nil, invoker))
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.level, entries[0]["level"])
+			}
+		})
+	}
+}
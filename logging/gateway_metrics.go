@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// gwMetricLabels are the labels of the gateway metrics, there is no label per
+// request to keep their cardinality bounded
+var gwMetricLabels = []string{"grpc_service", "grpc_method", "grpc_code"}
+
+// gwMetrics records the outcome and the latency of the calls made by the gw
+// interceptors
+type gwMetrics struct {
+	calls   *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+// WithMetrics makes the gw interceptors count the calls and record their
+// latency, labeled by service, method and code, in the
+// grpc_gateway_client_handled_total counter and the
+// grpc_gateway_client_handling_seconds histogram registered with the
+// registerer. The calls logged by the server because of the sentinel, or not
+// logged because of the sampling, are recorded too, the ignored methods are
+// not. The metrics are disabled when the registerer is nil. It panics when
+// the metrics cannot be registered, e.g. when the registerer has a collector
+// of the same name with other labels.
+func WithMetrics(registerer prometheus.Registerer) GWLogOption {
+	return func(o *gwLogCfg) {
+		if registerer == nil {
+			o.metrics = nil
+			return
+		}
+		o.metrics = newGWMetrics(registerer)
+	}
+}
+
+func newGWMetrics(registerer prometheus.Registerer) *gwMetrics {
+	calls := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_gateway_client_handled_total",
+		Help: "Total number of calls made by the gateway, by service, method and code.",
+	}, gwMetricLabels)
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_gateway_client_handling_seconds",
+		Help:    "Latency of the calls made by the gateway, by service, method and code.",
+		Buckets: prometheus.DefBuckets,
+	}, gwMetricLabels)
+	// the unary and stream interceptors share the collectors of a registerer
+	return &gwMetrics{
+		calls:   registerCollector(registerer, calls).(*prometheus.CounterVec),
+		latency: registerCollector(registerer, latency).(*prometheus.HistogramVec),
+	}
+}
+
+// registerCollector returns the collector already registered under the same
+// name if any. It panics on the other registration errors, e.g. a collector of
+// the same name with other labels, as prometheus.MustRegister does
+func registerCollector(registerer prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := registerer.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// observe records the call of the full method finishing with code, it is a
+// no-op when the metrics are disabled
+func (m *gwMetrics) observe(fullMethod string, code codes.Code, d time.Duration) {
+	if m == nil {
+		return
+	}
+	service, method := splitMethod(fullMethod)
+	m.calls.WithLabelValues(service, method, code.String()).Inc()
+	m.latency.WithLabelValues(service, method, code.String()).Observe(d.Seconds())
+}
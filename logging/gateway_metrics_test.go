@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gatheredMetric returns the metric of the family name with the labels
+func gatheredMetric(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) *dto.Metric {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range f.GetMetric() {
+			if len(m.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, l := range m.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					continue metrics
+				}
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+func TestGatewayLoggingInterceptor_Metrics(t *testing.T) {
+	logger, _ := newGatewayTestLogger(logrus.InfoLevel)
+	reg := prometheus.NewRegistry()
+	interceptor := GatewayLoggingInterceptor(logger, WithMetrics(reg))
+	sentinel := GatewayLoggingSentinelInterceptor()
+
+	failing := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.NotFound, "not found")
+	}
+	logged := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return sentinel(ctx, method, req, reply, cc, func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		}, opts...)
+	}
+
+	assert.Error(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, failing))
+	assert.Error(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, failing))
+	// the calls logged by the server are counted too
+	assert.NoError(t, interceptor(context.Background(), testFullMethod, nil, nil, nil, logged))
+
+	labels := map[string]string{"grpc_service": "app.Object", "grpc_method": testMethod, "grpc_code": codes.NotFound.String()}
+	if m := gatheredMetric(t, reg, "grpc_gateway_client_handled_total", labels); assert.NotNil(t, m) {
+		assert.Equal(t, float64(2), m.GetCounter().GetValue())
+	}
+	if m := gatheredMetric(t, reg, "grpc_gateway_client_handling_seconds", labels); assert.NotNil(t, m) {
+		assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	}
+	labels["grpc_code"] = codes.OK.String()
+	if m := gatheredMetric(t, reg, "grpc_gateway_client_handled_total", labels); assert.NotNil(t, m) {
+		assert.Equal(t, float64(1), m.GetCounter().GetValue())
+	}
+}
+
+func TestGatewayLoggingStreamInterceptor_Metrics(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	reg := prometheus.NewRegistry()
+	// the unary and stream interceptors share the collectors
+	GatewayLoggingInterceptor(logger, WithMetrics(reg))
+	interceptor := GatewayLoggingStreamInterceptor(logger, WithMetrics(reg))
+	sentinel := GatewayLoggingSentinelStreamInterceptor()
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return sentinel(ctx, desc, cc, method, func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{ctx: ctx}, nil
+		}, opts...)
+	}
+
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, testFullMethod, streamer)
+	require.NoError(t, err)
+	assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+	// the stream is logged by the server
+	assert.Empty(t, out.String())
+
+	labels := map[string]string{"grpc_service": "app.Object", "grpc_method": testMethod, "grpc_code": codes.OK.String()}
+	if m := gatheredMetric(t, reg, "grpc_gateway_client_handled_total", labels); assert.NotNil(t, m) {
+		assert.Equal(t, float64(1), m.GetCounter().GetValue())
+	}
+}
+
+func TestWithMetrics_NilRegisterer(t *testing.T) {
+	cfg := newGWLogCfg([]GWLogOption{WithMetrics(nil)})
+	assert.Nil(t, cfg.metrics)
+}
+
+func TestWithMetrics_RegistrationConflict(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_gateway_client_handled_total",
+		Help: "Total number of calls made by the gateway, by service, method and code.",
+	}, []string{"grpc_service"}))
+
+	assert.Panics(t, func() { newGWLogCfg([]GWLogOption{WithMetrics(reg)}) })
+}
@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	requestContentField  = "grpc.request.content"
+	responseContentField = "grpc.response.content"
+	requestSizeField     = "grpc.request.size"
+	responseSizeField    = "grpc.response.size"
+
+	payloadTruncatedMarker = "…(truncated)"
+)
+
+// PayloadMode selects the messages logged by the gateway interceptor
+type PayloadMode int
+
+const (
+	// PayloadNone does not log any message
+	PayloadNone PayloadMode = iota
+	// PayloadRequest logs the request message
+	PayloadRequest
+	// PayloadResponse logs the reply message
+	PayloadResponse
+	// PayloadBoth logs both the request and the reply messages
+	PayloadBoth
+)
+
+// WithPayloadLogging logs the JSON representation of the unary request and/or
+// reply messages under the grpc.request.content and grpc.response.content
+// fields. Object keys matching the redacted metadata keys are masked.
+func WithPayloadLogging(mode PayloadMode) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.payloadMode = mode
+	}
+}
+
+// WithPayloadLimit truncates the logged payloads to limit bytes, a limit of
+// zero or less disables the truncation
+func WithPayloadLimit(limit int) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.payloadLimit = limit
+	}
+}
+
+// WithMessageSizeFields logs the size in bytes of the unary request and reply
+// messages under the grpc.request.size and grpc.response.size fields, they are
+// omitted for messages that are not proto messages
+func WithMessageSizeFields() GWLogOption {
+	return func(o *gwLogCfg) {
+		o.messageSizes = true
+	}
+}
+
+// messageFields returns the content and size fields of a unary call according
+// to the configuration, the reply is only accounted for successful calls. The
+// fields are taken from the pool, see putFields
+func (cfg *gwLogCfg) messageFields(req, reply interface{}, err error) logrus.Fields {
+	if cfg.payloadMode == PayloadNone && !cfg.messageSizes {
+		return nil
+	}
+	fields := getFields()
+	if cfg.messageSizes {
+		if pm, ok := req.(proto.Message); ok {
+			fields[requestSizeField] = proto.Size(pm)
+		}
+		if pm, ok := reply.(proto.Message); ok && err == nil {
+			fields[responseSizeField] = proto.Size(pm)
+		}
+	}
+	if cfg.payloadMode == PayloadRequest || cfg.payloadMode == PayloadBoth {
+		if content, ok := cfg.marshalPayload(req); ok {
+			fields[requestContentField] = content
+		}
+	}
+	if err == nil && (cfg.payloadMode == PayloadResponse || cfg.payloadMode == PayloadBoth) {
+		if content, ok := cfg.marshalPayload(reply); ok {
+			fields[responseContentField] = content
+		}
+	}
+	return fields
+}
+
+func (cfg *gwLogCfg) marshalPayload(msg interface{}) (string, bool) {
+	if msg == nil {
+		return "", false
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if pm, ok := msg.(proto.Message); ok {
+		data, err = protojson.Marshal(pm)
+	} else {
+		data, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return "", false
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err == nil {
+		if data, err = json.Marshal(cfg.redactPayload(decoded)); err != nil {
+			return "", false
+		}
+	}
+
+	return truncatePayload(string(data), cfg.payloadLimit), true
+}
+
+// redactPayload masks the values of the object keys matching the redacted keys
+func (cfg *gwLogCfg) redactPayload(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			if _, ok := cfg.redactedKeys[strings.ToLower(k)]; ok {
+				x[k] = valueRedacted
+				continue
+			}
+			x[k] = cfg.redactPayload(val)
+		}
+	case []interface{}:
+		for i, val := range x {
+			x[i] = cfg.redactPayload(val)
+		}
+	}
+	return v
+}
+
+func truncatePayload(payload string, limit int) string {
+	if limit <= 0 || len(payload) <= limit {
+		return payload
+	}
+	payload = payload[:limit]
+	// do not cut a multi-byte character in half
+	for len(payload) > 0 && !utf8.ValidString(payload) {
+		payload = payload[:len(payload)-1]
+	}
+	return payload + payloadTruncatedMarker
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGatewayLoggingInterceptor_PayloadLogging(t *testing.T) {
+	req, _ := structpb.NewStruct(map[string]interface{}{"name": "req", "authorization": "token"})
+	reply, _ := structpb.NewStruct(map[string]interface{}{"name": "reply"})
+
+	for name, tc := range map[string]struct {
+		mode     PayloadMode
+		err      error
+		request  interface{}
+		response interface{}
+	}{
+		"none":     {mode: PayloadNone},
+		"request":  {mode: PayloadRequest, request: `{"authorization":"***","name":"req"}`},
+		"response": {mode: PayloadResponse, response: `{"name":"reply"}`},
+		"both":     {mode: PayloadBoth, request: `{"authorization":"***","name":"req"}`, response: `{"name":"reply"}`},
+		"error":    {mode: PayloadBoth, err: status.Error(codes.Internal, "failed"), request: `{"authorization":"***","name":"req"}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithPayloadLogging(tc.mode))
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return tc.err
+			}
+			interceptor(context.Background(), testFullMethod, req, reply, nil, invoker)
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.request, entries[0][requestContentField])
+				assert.Equal(t, tc.response, entries[0][responseContentField])
+			}
+		})
+	}
+}
+
+func TestGatewayLoggingInterceptor_PayloadLimit(t *testing.T) {
+	req, _ := structpb.NewStruct(map[string]interface{}{"name": "a long request name"})
+
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, WithPayloadLogging(PayloadRequest), WithPayloadLimit(10))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	interceptor(context.Background(), testFullMethod, req, nil, nil, invoker)
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, `{"name":"a`+payloadTruncatedMarker, entries[0][requestContentField])
+	}
+}
+
+func TestTruncatePayload(t *testing.T) {
+	assert.Equal(t, "short", truncatePayload("short", 10))
+	assert.Equal(t, "unlimited", truncatePayload("unlimited", 0))
+	assert.Equal(t, "ab"+payloadTruncatedMarker, truncatePayload("abcdef", 2))
+	// the multi-byte character is not cut in half
+	assert.Equal(t, "a"+payloadTruncatedMarker, truncatePayload("aé", 2))
+}
+
+func TestGatewayLoggingInterceptor_MessageSizeFields(t *testing.T) {
+	req, _ := structpb.NewStruct(map[string]interface{}{"name": "req"})
+	reply, _ := structpb.NewStruct(map[string]interface{}{"name": "a longer reply"})
+
+	for name, tc := range map[string]struct {
+		req, reply interface{}
+		err        error
+		reqSize    interface{}
+		replySize  interface{}
+	}{
+		"proto":     {req: req, reply: reply, reqSize: float64(proto.Size(req)), replySize: float64(proto.Size(reply))},
+		"error":     {req: req, reply: reply, err: status.Error(codes.Internal, "failed"), reqSize: float64(proto.Size(req))},
+		"non-proto": {req: map[string]string{"name": "req"}, reply: "reply"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithMessageSizeFields())
+
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return tc.err
+			}
+			interceptor(context.Background(), testFullMethod, tc.req, tc.reply, nil, invoker)
+
+			entries := gatewayLogEntries(t, out)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tc.reqSize, entries[0][requestSizeField])
+				assert.Equal(t, tc.replySize, entries[0][responseSizeField])
+				assert.NotContains(t, entries[0], requestContentField)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	panicField = "panic"
+	stackField = "stack"
+)
+
+// errRecoveredPanic is returned to the caller instead of the panic value, so
+// that internal details are not exposed by the gateway
+var errRecoveredPanic = status.Error(codes.Internal, "internal error")
+
+// GatewayRecoveryInterceptor recovers the panics raised down the interceptor
+// chain and logs them at error level, with the recovered value and the stack
+// under the panic and stack fields. The service, method, request-id and
+// account-id fields are built like in GatewayLoggingInterceptor, so the same
+// options should be given to both. It returns a codes.Internal error instead.
+// Chained before the GatewayLoggingInterceptor it also covers the middlewares
+// in between, and the request-id it logs is the one forwarded by the latter.
+func GatewayRecoveryInterceptor(logger *logrus.Logger, opts ...GWLogOption) grpc.UnaryClientInterceptor {
+	return GatewayRecoveryInterceptorFor(LogrusLogger(logger), opts...)
+}
+
+// GatewayRecoveryInterceptorFor is the GatewayRecoveryInterceptor emitting its
+// logs through any Logger backend
+func GatewayRecoveryInterceptorFor(logger Logger, opts ...GWLogOption) grpc.UnaryClientInterceptor {
+	cfg := newGWLogCfg(opts)
+	return func(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		// the request-id is set beforehand so that the logging interceptor
+		// reuses the logged one
+		ctx, _ = cfg.withRequestID(ctx)
+		defer func() {
+			if p := recover(); p != nil {
+				err = cfg.logPanic(ctx, logger, method, p)
+			}
+		}()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// GatewayRecoveryStreamInterceptor is the streaming counterpart of
+// GatewayRecoveryInterceptor, it recovers the panics raised while the stream
+// is established
+func GatewayRecoveryStreamInterceptor(logger *logrus.Logger, opts ...GWLogOption) grpc.StreamClientInterceptor {
+	return GatewayRecoveryStreamInterceptorFor(LogrusLogger(logger), opts...)
+}
+
+// GatewayRecoveryStreamInterceptorFor is the GatewayRecoveryStreamInterceptor
+// emitting its logs through any Logger backend
+func GatewayRecoveryStreamInterceptorFor(logger Logger, opts ...GWLogOption) grpc.StreamClientInterceptor {
+	cfg := newGWLogCfg(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (cs grpc.ClientStream, err error) {
+		ctx, _ = cfg.withRequestID(ctx)
+		defer func() {
+			if p := recover(); p != nil {
+				cs, err = nil, cfg.logPanic(ctx, logger, method, p)
+			}
+		}()
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+func (cfg *gwLogCfg) logPanic(ctx context.Context, logger Logger, method string, p interface{}) error {
+	call := cfg.startCall(ctx, logger, method)
+	call.entryLogger().WithFields(logrus.Fields{
+		panicField: fmt.Sprintf("%v", p),
+		stackField: string(debug.Stack()),
+	}).Logf(logrus.ErrorLevel, "recovered from panic in client call")
+	return errRecoveredPanic
+}
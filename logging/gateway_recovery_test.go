@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	"github.com/armezit/atlas-app-toolkit/requestid"
+)
+
+func TestGatewayRecoveryInterceptor(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := grpc_middleware.ChainUnaryClient(
+		GatewayRecoveryInterceptor(logger, EnableAccountID),
+		GatewayLoggingInterceptor(logger, EnableAccountID),
+	)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, testJWT))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		panic("boom")
+	}
+
+	err := interceptor(ctx, testFullMethod, nil, nil, nil, invoker)
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// the panic unwinds the logging interceptor, only the recovery logs it
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "error", entries[0]["level"])
+		assert.Equal(t, "boom", entries[0][panicField])
+		assert.Contains(t, entries[0][stackField], "runtime/debug.Stack")
+		assert.Equal(t, "app.Object", entries[0][DefaultGRPCServiceKey])
+		assert.Equal(t, testMethod, entries[0][DefaultGRPCMethodKey])
+		assert.Equal(t, testAccID, entries[0][auth.MultiTenancyField])
+		assert.NotEmpty(t, entries[0][requestid.DefaultRequestIDKey])
+	}
+}
+
+func TestGatewayRecoveryInterceptor_NoPanic(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayRecoveryInterceptor(logger)
+
+	var reqID string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		reqID, _ = requestid.FromContext(ctx)
+		return status.Error(codes.NotFound, "not found")
+	}
+
+	err := interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NotEmpty(t, reqID)
+	assert.Empty(t, out.String())
+}
+
+func TestGatewayRecoveryStreamInterceptor(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayRecoveryStreamInterceptor(logger)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(requestid.DefaultRequestIDKey, testRequestID))
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		panic("boom")
+	}
+
+	cs, err := interceptor(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, testFullMethod, streamer)
+	assert.Nil(t, cs)
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "boom", entries[0][panicField])
+		assert.Equal(t, testRequestID, entries[0][requestid.DefaultRequestIDKey])
+	}
+}
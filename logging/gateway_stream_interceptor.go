@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	requestMessagesField  = "grpc.request.messages"
+	responseMessagesField = "grpc.response.messages"
+)
+
+// GatewayLoggingStreamInterceptor is the streaming counterpart of
+// GatewayLoggingInterceptor. It accepts the same options, logs once when the
+// stream is established and once more when RecvMsg returns io.EOF or an error,
+// including the number of messages sent and received on the stream.
+// The GatewayLoggingSentinelStreamInterceptor should be the last stream
+// interceptor in the chain so that the server can claim the log.
+func GatewayLoggingStreamInterceptor(logger *logrus.Logger, opts ...GWLogOption) grpc.StreamClientInterceptor {
+	return GatewayLoggingStreamInterceptorFor(LogrusLogger(logger), opts...)
+}
+
+// GatewayLoggingStreamInterceptorFor is the GatewayLoggingStreamInterceptor
+// emitting its logs through any Logger backend
+func GatewayLoggingStreamInterceptorFor(logger Logger, opts ...GWLogOption) grpc.StreamClientInterceptor {
+	cfg := newGWLogCfg(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if cfg.isIgnored(method) {
+			ctx, _ = cfg.withRequestID(ctx)
+			return streamer(cfg.withBaggage(ctx), desc, cc, method, opts...)
+		}
+		if cfg.quiet(logger) {
+			var sentinelValue bool
+			return streamer(context.WithValue(cfg.startQuietCall(ctx, logger), sentinelKey, &sentinelValue), desc, cc, method, opts...)
+		}
+
+		call := cfg.startCall(ctx, logger, method)
+
+		var sentinelValue bool
+		clientStream, err := streamer(context.WithValue(call.ctx, sentinelKey, &sentinelValue), desc, cc, method, opts...)
+
+		// if the sentinel is set, no middlewares had errors, and it is assumed the
+		// server will log the stream instead of the gateway doing so
+		if sentinelValue && !cfg.alwaysLog {
+			switch {
+			case cfg.sentinelDebug:
+				call.debug = true
+			case cfg.metrics != nil:
+				// the stream is still wrapped to record its metrics once done
+				call.silent = true
+			default:
+				return clientStream, err
+			}
+		}
+
+		if err != nil {
+			call.finish(err, "finished client streaming call with code %s", nil)
+			return clientStream, err
+		}
+
+		if call.sampled && !call.silent {
+			call.entryLogger().Logf(call.level(codes.OK), "started client streaming call")
+		}
+
+		return &gwLoggingClientStream{
+			ClientStream: clientStream,
+			call:         call,
+		}, nil
+	}
+}
+
+// GatewayLoggingSentinelStreamInterceptor is the streaming counterpart of
+// GatewayLoggingSentinelInterceptor and is meant to be the last interceptor in
+// the client stream interceptor chain.
+func GatewayLoggingSentinelStreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		succeeded, ok := ctx.Value(sentinelKey).(*bool)
+		if !ok {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		*succeeded = true
+		clientStream, err := streamer(ctx, desc, cc, method, opts...)
+		*succeeded = reachedServer(err)
+		return clientStream, err
+	}
+}
+
+// gwLoggingClientStream counts the messages passing through the stream and
+// logs the finish line once the receiving side is done
+type gwLoggingClientStream struct {
+	grpc.ClientStream
+	call     *gwCall
+	sent     int64
+	received int64
+	once     sync.Once
+}
+
+func (s *gwLoggingClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	}
+	return err
+}
+
+func (s *gwLoggingClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.received, 1)
+		return nil
+	}
+
+	s.once.Do(func() {
+		var callErr error
+		if err != io.EOF {
+			callErr = err
+		}
+		fields := logrus.Fields{
+			requestMessagesField:  atomic.LoadInt64(&s.sent),
+			responseMessagesField: atomic.LoadInt64(&s.received),
+		}
+		if len(s.call.cfg.trailerKeys) > 0 {
+			// the trailer is available once RecvMsg returned an error
+			s.call.cfg.trailerFields(fields, s.ClientStream.Trailer())
+		}
+		s.call.finish(callErr, "finished client streaming call with code %s", fields)
+	})
+	return err
+}
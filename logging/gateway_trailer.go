@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+)
+
+// trailerFieldPrefix prefixes the trailer keys logged by WithTrailerFields
+const trailerFieldPrefix = "grpc.response.trailer."
+
+// WithTrailerFields logs the values of the given keys of the trailer metadata
+// returned by the server in the final line of the call, under
+// grpc.response.trailer.<key>, e.g. grpc.response.trailer.x-served-by. The
+// other keys are not logged and the values of the redacted keys are masked.
+// Nothing is logged for a call failed before the trailer was received.
+func WithTrailerFields(keys ...string) GWLogOption {
+	return func(o *gwLogCfg) {
+		for _, k := range keys {
+			o.trailerKeys = append(o.trailerKeys, strings.ToLower(k))
+		}
+	}
+}
+
+// trailerFields adds the logged keys of the trailer to the fields, which are
+// taken from the pool when nil, see putFields
+func (cfg *gwLogCfg) trailerFields(fields logrus.Fields, trailer metadata.MD) logrus.Fields {
+	for _, k := range cfg.trailerKeys {
+		vs := trailer.Get(k)
+		if len(vs) == 0 {
+			continue
+		}
+		if fields == nil {
+			fields = getFields()
+		}
+		if _, ok := cfg.redactedKeys[k]; ok {
+			fields[trailerFieldPrefix+k] = valueRedacted
+			continue
+		}
+		fields[trailerFieldPrefix+k] = strings.Join(vs, ",")
+	}
+	return fields
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// setTrailer sets the trailer of the grpc.Trailer call option, as the client
+// transport does once the call is done
+func setTrailer(opts []grpc.CallOption, trailer metadata.MD) {
+	for _, opt := range opts {
+		if o, ok := opt.(grpc.TrailerCallOption); ok {
+			*o.TrailerAddr = trailer
+		}
+	}
+}
+
+func TestGatewayLoggingInterceptor_TrailerFields(t *testing.T) {
+	trailer := metadata.Pairs("x-served-by", "backend-2", "x-quota-remaining", "42", "x-internal", "secret", "x-api-key", "key")
+	for name, tc := range map[string]struct {
+		err      error
+		trailer  metadata.MD
+		expected map[string]interface{}
+	}{
+		"ok": {
+			trailer: trailer,
+			expected: map[string]interface{}{
+				"grpc.response.trailer.x-served-by":       "backend-2",
+				"grpc.response.trailer.x-quota-remaining": "42",
+				"grpc.response.trailer.x-api-key":         valueRedacted,
+			},
+		},
+		"failed by the server": {
+			err:     status.Error(codes.ResourceExhausted, "quota exceeded"),
+			trailer: metadata.Pairs("x-quota-remaining", "0"),
+			expected: map[string]interface{}{
+				"grpc.response.trailer.x-quota-remaining": "0",
+			},
+		},
+		"failed before the trailer": {
+			err:      status.Error(codes.Unavailable, "connection refused"),
+			expected: map[string]interface{}{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithTrailerFields("X-Served-By", "x-quota-remaining", "x-api-key"))
+
+			callerOpts := make([]grpc.CallOption, 0, 4)
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				if tc.trailer != nil {
+					setTrailer(opts, tc.trailer)
+				}
+				return tc.err
+			}
+
+			err := interceptor(context.Background(), testFullMethod, nil, nil, nil, invoker, callerOpts...)
+			assert.Equal(t, tc.err, err)
+			// the option is not appended to the slice of the caller
+			assert.Nil(t, callerOpts[:1][0])
+
+			entries := gatewayLogEntries(t, out)
+			require.Len(t, entries, 1)
+			for k, v := range tc.expected {
+				assert.Equal(t, v, entries[0][k], k)
+			}
+			for k := range entries[0] {
+				if _, ok := tc.expected[k]; !ok {
+					assert.NotContains(t, k, trailerFieldPrefix)
+				}
+			}
+		})
+	}
+}
+
+type trailerClientStream struct {
+	*fakeClientStream
+	trailer metadata.MD
+}
+
+func (s *trailerClientStream) Trailer() metadata.MD { return s.trailer }
+
+func TestGatewayLoggingStreamInterceptor_TrailerFields(t *testing.T) {
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingStreamInterceptor(logger, WithTrailerFields("x-served-by"))
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &trailerClientStream{
+			fakeClientStream: &fakeClientStream{ctx: ctx, responses: 1},
+			trailer:          metadata.Pairs("x-served-by", "backend-2", "x-other", "ignored"),
+		}, nil
+	}
+
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, testFullMethod, streamer)
+	require.NoError(t, err)
+	assert.NoError(t, cs.RecvMsg(nil))
+	assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "backend-2", entries[1]["grpc.response.trailer.x-served-by"])
+		assert.NotContains(t, entries[1], "grpc.response.trailer.x-other")
+	}
+}
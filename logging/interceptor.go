@@ -34,7 +34,9 @@ const (
 
 // LogLevelInterceptor sets the level of the logger in the context to either
 // the default or the value set in the context via grpc metadata.
-// Also sets the custom log tag if present for pseudo-tracing purposes
+// Also sets the custom log tag if present for pseudo-tracing purposes, the
+// handler reads it with LogFlagFromContext, and the level with
+// LevelFromContext
 func LogLevelInterceptor(defaultLevel logrus.Level) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
 		entry := ctxlogrus.Extract(ctx)
@@ -52,6 +54,10 @@ func LogLevelInterceptor(defaultLevel logrus.Level) grpc.UnaryServerInterceptor
 		}
 		newLogger := CopyLoggerWithLevel(entry.Logger, lvl)
 		newCtx := ctxlogrus.ToContext(ctx, newLogger.WithFields(entry.Data))
+		newCtx = context.WithValue(newCtx, logLevelKey, lvl)
+		if hasFlag {
+			newCtx = context.WithValue(newCtx, logFlagKey, logFlag)
+		}
 		res, err = handler(newCtx, req)
 
 		// propagate any new or changed fields from later interceptors back up
@@ -200,6 +206,9 @@ func addRequestIDField(ctx context.Context, fields logrus.Fields) error {
 	}
 
 	fields[DefaultRequestIDKey] = reqID
+	if parentID, ok := requestid.ParentFromContext(ctx); ok {
+		fields[requestid.ParentRequestIDLogKey] = parentID
+	}
 
 	return nil
 }
@@ -294,8 +303,8 @@ func CopyLoggerWithLevel(logger *logrus.Logger, lvl logrus.Level) *logrus.Logger
 		ExitFunc:     logger.ExitFunc,
 	}
 	// Copy hooks, so that original Logger hooks are not altered
-	for l, hook := range logger.Hooks {
-		newLogger.Hooks[l] = hook
+	for l, hooks := range logger.Hooks {
+		newLogger.Hooks[l] = append([]logrus.Hook(nil), hooks...)
 	}
 	return newLogger
 }
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelRegistry holds per-service log level overrides for the gateway
+// interceptors. It is safe for concurrent use, so that the levels can be
+// changed at runtime, e.g. from an admin endpoint. The zero value is an
+// empty registry ready to use.
+type LevelRegistry struct {
+	mu     sync.RWMutex
+	levels map[string]logrus.Level
+}
+
+// NewLevelRegistry returns an empty LevelRegistry
+func NewLevelRegistry() *LevelRegistry {
+	return &LevelRegistry{}
+}
+
+// Set overrides the log level of the service, given in the package.Service
+// form (e.g. "app.Object")
+func (r *LevelRegistry) Set(service string, lvl logrus.Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.levels == nil {
+		r.levels = make(map[string]logrus.Level)
+	}
+	r.levels[service] = lvl
+}
+
+// Get returns the log level override of the service, if any
+func (r *LevelRegistry) Get(service string) (logrus.Level, bool) {
+	if r == nil {
+		return 0, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lvl, ok := r.levels[service]
+	return lvl, ok
+}
+
+// Delete removes the log level override of the service
+func (r *LevelRegistry) Delete(service string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.levels, service)
+}
+
+// WithLevelRegistry makes the gw interceptors log the services overridden in
+// reg at their registered level. The registry is consulted before the
+// dynamic log level header and the level of the base logger.
+func WithLevelRegistry(reg *LevelRegistry) GWLogOption {
+	return func(o *gwLogCfg) {
+		o.levelRegistry = reg
+	}
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLevelRegistry(t *testing.T) {
+	reg := NewLevelRegistry()
+
+	_, ok := reg.Get("app.Object")
+	assert.False(t, ok)
+
+	reg.Set("app.Object", logrus.DebugLevel)
+	lvl, ok := reg.Get("app.Object")
+	assert.True(t, ok)
+	assert.Equal(t, logrus.DebugLevel, lvl)
+
+	reg.Delete("app.Object")
+	_, ok = reg.Get("app.Object")
+	assert.False(t, ok)
+
+	var nilReg *LevelRegistry
+	_, ok = nilReg.Get("app.Object")
+	assert.False(t, ok)
+}
+
+func TestLevelRegistry_Concurrent(t *testing.T) {
+	var reg LevelRegistry
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			reg.Set("app.Object", logrus.DebugLevel)
+		}()
+		go func() {
+			defer wg.Done()
+			reg.Get("app.Object")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGatewayLoggingInterceptor_LevelRegistry(t *testing.T) {
+	reg := NewLevelRegistry()
+	reg.Set("app.Object", logrus.ErrorLevel)
+
+	for name, tc := range map[string]struct {
+		method   string
+		header   string
+		expected int
+	}{
+		"registry":            {method: testFullMethod, expected: 0},
+		"registry and header": {method: testFullMethod, header: "debug", expected: 0},
+		"header":              {method: "/app.Other/TestMethod", header: "error", expected: 0},
+		"base level":          {method: "/app.Other/TestMethod", expected: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newGatewayTestLogger(logrus.InfoLevel)
+			interceptor := GatewayLoggingInterceptor(logger, WithLevelRegistry(reg), EnableDynamicLogLevel)
+
+			ctx := context.Background()
+			if tc.header != "" {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(logLevelMetaKey, tc.header))
+			}
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return nil
+			}
+			assert.NoError(t, interceptor(ctx, tc.method, nil, nil, nil, invoker))
+
+			assert.Len(t, gatewayLogEntries(t, out), tc.expected)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is the logging backend used by the gateway interceptors, it allows
+// them to emit the same fields through loggers other than logrus.
+type Logger interface {
+	// WithFields returns a Logger that includes the given fields in every entry.
+	// The fields must be copied, since the interceptors reuse the map
+	WithFields(fields logrus.Fields) Logger
+	// WithLevel returns a copy of the Logger that emits entries at lvl or
+	// more severe levels
+	WithLevel(lvl logrus.Level) Logger
+	// Level returns the least severe level emitted by the Logger
+	Level() logrus.Level
+	// Logf emits an entry at the given level
+	Logf(lvl logrus.Level, format string, args ...interface{})
+}
+
+// contextLogger is implemented by the Logger backends that have their own
+// way of being stored in a request context
+type contextLogger interface {
+	toContext(ctx context.Context) context.Context
+	fromContext(ctx context.Context) Logger
+}
+
+type loggerKeyType struct{}
+
+var loggerKey = loggerKeyType{}
+
+// loggerToContext stores l in the context for the middlewares down the chain
+func loggerToContext(ctx context.Context, l Logger) context.Context {
+	if cl, ok := l.(contextLogger); ok {
+		return cl.toContext(ctx)
+	}
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// loggerFromContext returns the Logger stored in the context by loggerToContext,
+// including the changes made to it down the middleware chain
+func loggerFromContext(ctx context.Context, l Logger) Logger {
+	if cl, ok := l.(contextLogger); ok {
+		return cl.fromContext(ctx)
+	}
+	if stored, ok := ctx.Value(loggerKey).(Logger); ok {
+		return stored
+	}
+	return l
+}
+
+// LogrusLogger adapts a logrus logger to the Logger interface. The request
+// scoped entry is stored in the context with ctxlogrus, so middlewares can
+// keep altering it with ctxlogrus.AddFields.
+func LogrusLogger(logger *logrus.Logger) Logger {
+	return &logrusLogger{entry: logrus.NewEntry(logger)}
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+func (l *logrusLogger) WithFields(fields logrus.Fields) Logger {
+	return &logrusLogger{entry: l.entry.WithFields(fields)}
+}
+
+func (l *logrusLogger) WithLevel(lvl logrus.Level) Logger {
+	return &logrusLogger{entry: CopyLoggerWithLevel(l.entry.Logger, lvl).WithFields(l.entry.Data)}
+}
+
+func (l *logrusLogger) Level() logrus.Level {
+	return l.entry.Logger.Level
+}
+
+func (l *logrusLogger) Logf(lvl logrus.Level, format string, args ...interface{}) {
+	levelLogf(l.entry, lvl, format, args...)
+}
+
+func (l *logrusLogger) toContext(ctx context.Context) context.Context {
+	return ctxlogrus.ToContext(ctx, l.entry)
+}
+
+func (l *logrusLogger) fromContext(ctx context.Context) Logger {
+	return &logrusLogger{entry: ctxlogrus.Extract(ctx)}
+}
@@ -51,6 +51,13 @@ func TestInterceptor(t *testing.T) {
 			if !reflect.DeepEqual(expect.Data, logger.Data) {
 				t.Errorf("Expected fields %+v != Observed fields %+v", expect.Data, logger.Data)
 			}
+			// the handler sees the log flag only when the request is flagged
+			if flag, ok := LogFlagFromContext(ctx); ok != (expect.Data[logFlagFieldName] != nil) || ok && flag != expect.Data[logFlagFieldName] {
+				t.Errorf("Expected log flag %v != Observed log flag %q", expect.Data[logFlagFieldName], flag)
+			}
+			if lvl, ok := LevelFromContext(ctx); !ok || lvl != expect.Logger.Level {
+				t.Errorf("Expected level in context %q != Observed level %q (%v)", expect.Logger.Level, lvl, ok)
+			}
 			ctxlogrus.AddFields(ctx, addFields)
 			return nil, nil
 		}
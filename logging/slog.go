@@ -0,0 +1,100 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SlogLogger adapts a log/slog logger to the Logger interface. The least
+// severe level emitted is taken from the levels enabled by the slog handler.
+func SlogLogger(logger *slog.Logger) Logger {
+	lvl := logrus.ErrorLevel
+	for _, l := range []logrus.Level{logrus.TraceLevel, logrus.DebugLevel, logrus.InfoLevel, logrus.WarnLevel} {
+		if logger.Enabled(context.Background(), slogLevel(l)) {
+			lvl = l
+			break
+		}
+	}
+	return &slogLogger{logger: logger, level: lvl}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+	level  logrus.Level
+}
+
+func (l *slogLogger) WithFields(fields logrus.Fields) Logger {
+	args := make([]interface{}, 0, len(fields))
+	for k, v := range fields {
+		args = append(args, slog.Any(k, v))
+	}
+	return &slogLogger{logger: l.logger.With(args...), level: l.level}
+}
+
+// WithLevel returns a logger whose handler emits the records of lvl and above,
+// whatever the level of the handler of l
+func (l *slogLogger) WithLevel(lvl logrus.Level) Logger {
+	handler := l.logger.Handler()
+	if h, ok := handler.(*levelHandler); ok {
+		handler = h.handler
+	}
+	return &slogLogger{logger: slog.New(&levelHandler{handler: handler, level: slogLevel(lvl)}), level: lvl}
+}
+
+func (l *slogLogger) Level() logrus.Level {
+	return l.level
+}
+
+func (l *slogLogger) Logf(lvl logrus.Level, format string, args ...interface{}) {
+	// logrus levels are ordered from the most severe to the least severe one
+	if lvl > l.level {
+		return
+	}
+	l.logger.Log(context.Background(), slogLevel(lvl), fmt.Sprintf(format, args...))
+}
+
+// levelHandler overrides the level of the records enabled by the handler, e.g.
+// for the dynamic log level of a call
+type levelHandler struct {
+	handler slog.Handler
+	level   slog.Level
+}
+
+func (h *levelHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return lvl >= h.level
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{handler: h.handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{handler: h.handler.WithGroup(name), level: h.level}
+}
+
+// slogLevel maps a logrus level to the closest slog level, the fatal and
+// panic levels are logged as errors without exiting or panicking
+func slogLevel(lvl logrus.Level) slog.Level {
+	switch lvl {
+	case logrus.TraceLevel:
+		return slog.LevelDebug - 4
+	case logrus.DebugLevel:
+		return slog.LevelDebug
+	case logrus.InfoLevel:
+		return slog.LevelInfo
+	case logrus.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
@@ -0,0 +1,103 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	"github.com/armezit/atlas-app-toolkit/requestid"
+)
+
+func TestSlogLogger_Level(t *testing.T) {
+	for input, expected := range map[slog.Level]logrus.Level{
+		slog.LevelDebug: logrus.DebugLevel,
+		slog.LevelInfo:  logrus.InfoLevel,
+		slog.LevelWarn:  logrus.WarnLevel,
+		slog.LevelError: logrus.ErrorLevel,
+	} {
+		l := SlogLogger(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: input})))
+		assert.Equal(t, expected, l.Level())
+	}
+}
+
+func TestSlogLogger_WithLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := SlogLogger(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	// the level of the logger overrides the one of the handler
+	logger.WithLevel(logrus.DebugLevel).WithFields(logrus.Fields{"key": "value"}).Logf(logrus.DebugLevel, "debug %d", 1)
+	logger.Logf(logrus.DebugLevel, "dropped")
+	logger.WithLevel(logrus.WarnLevel).Logf(logrus.InfoLevel, "dropped")
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "debug 1", entries[0]["msg"])
+		assert.Equal(t, "DEBUG", entries[0]["level"])
+		assert.Equal(t, "value", entries[0]["key"])
+	}
+}
+
+func TestGatewayLoggingInterceptorFor_Slog(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := SlogLogger(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	interceptor := GatewayLoggingInterceptorFor(logger, EnableAccountID)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(testAuthorizationHeader, testJWT, requestid.DefaultRequestIDKey, testRequestID))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		// the logrus context logger is not populated with the slog backend
+		_, ok := ctxlogrus.Extract(ctx).Data[DefaultGRPCMethodKey]
+		assert.False(t, ok)
+		return status.Error(codes.NotFound, "not found")
+	}
+
+	assert.Error(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "app.Object", entries[0][DefaultGRPCServiceKey])
+		assert.Equal(t, testMethod, entries[0][DefaultGRPCMethodKey])
+		assert.Equal(t, testRequestID, entries[0][requestid.DefaultRequestIDKey])
+		assert.Equal(t, testAccID, entries[0][auth.MultiTenancyField])
+		assert.Equal(t, codes.NotFound.String(), entries[0][DefaultGRPCCodeKey])
+		assert.Equal(t, "INFO", entries[0]["level"])
+	}
+}
+
+func TestGatewayLoggingInterceptorFor_SlogDynamicLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := SlogLogger(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	interceptor := GatewayLoggingInterceptorFor(logger.WithLevel(logrus.InfoLevel), EnableDynamicLogLevel)
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(logLevelMetaKey, "error"))
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+	assert.Empty(t, out.String())
+
+	// the log-level header can make the call more verbose than the handler
+	out.Reset()
+	logger = SlogLogger(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	interceptor = GatewayLoggingInterceptorFor(logger, EnableDynamicLogLevel)
+	ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs(logLevelMetaKey, "debug"))
+
+	assert.NoError(t, interceptor(ctx, testFullMethod, nil, nil, nil, invoker))
+	entries := gatewayLogEntries(t, out)
+	if assert.NotEmpty(t, entries) {
+		assert.Equal(t, codes.OK.String(), entries[len(entries)-1][DefaultGRPCCodeKey])
+	}
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+	"github.com/armezit/atlas-app-toolkit/requestid"
+)
+
+// StandardFields returns the grpc.service, grpc.method, request-id and
+// account_id fields the gw interceptors configured with opts log for a call
+// of the full method, e.g. for the logs of custom interceptors or background
+// jobs to match them. The request-id is read and not generated, it is omitted
+// when missing. The metadata of the context is the outgoing one, as in the gw
+// interceptors, or else the incoming one, as in a server handler.
+func StandardFields(ctx context.Context, method string, opts ...GWLogOption) logrus.Fields {
+	cfg := newGWLogCfg(opts)
+	if _, ok := metadata.FromOutgoingContext(ctx); !ok {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ctx = metadata.NewOutgoingContext(ctx, md)
+		}
+	}
+	var reqID string
+	if !cfg.noRequestID {
+		reqID, _ = cfg.requestIDFromContext(ctx)
+	}
+	fields := make(logrus.Fields, 8)
+	_ = cfg.standardFields(ctx, method, reqID, fields)
+	cfg.renameFields(fields)
+	return fields
+}
+
+// standardFields adds the fields identifying the call and its caller, the
+// returned error is the failure to read the account id, which is then logged
+// as undefined
+func (cfg *gwLogCfg) standardFields(ctx context.Context, method, reqID string, fields logrus.Fields) error {
+	service, grpcMethod := splitMethod(method)
+	fields["grpc.service"] = service
+	fields["grpc.method"] = grpcMethod
+
+	if reqID != "" {
+		fields[cfg.requestIDKey] = reqID
+		if parentID, ok := requestid.ParentFromContext(ctx); ok {
+			fields[requestid.ParentRequestIDLogKey] = parentID
+		}
+	}
+
+	// the token is read from the outgoing metadata forwarded to the server
+	md, _ := metadata.FromOutgoingContext(ctx)
+	tokenCtx := metadata.NewIncomingContext(ctx, md)
+
+	var err error
+	// Account ID retrieval -- ever so slightly hacky
+	if _, anonymous := cfg.anonymous[method]; cfg.withAcctID && !anonymous {
+		var accountID, source string
+		if accountID, source, err = cfg.accountID(tokenCtx); err == nil {
+			fields[auth.MultiTenancyField] = accountID
+			if source != "" {
+				fields[accountIDSourceField] = source
+			}
+		} else {
+			fields[auth.MultiTenancyField] = valueUndefined
+		}
+	}
+
+	if cfg.subjectFields {
+		cfg.addSubjectFields(tokenCtx, fields)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/armezit/atlas-app-toolkit/auth"
+)
+
+func TestStandardFields(t *testing.T) {
+	md := metadata.Pairs(testAuthorizationHeader, testJWT, "X-Request-ID", "abc-123")
+
+	fields := StandardFields(metadata.NewIncomingContext(context.Background(), md), testFullMethod, EnableAccountID)
+	assert.Equal(t, logrus.Fields{
+		DefaultGRPCServiceKey:  "app.Object",
+		DefaultGRPCMethodKey:   testMethod,
+		"X-Request-ID":         "abc-123",
+		auth.MultiTenancyField: testAccID,
+	}, fields)
+
+	// the gw interceptor logs the same fields
+	logger, out := newGatewayTestLogger(logrus.InfoLevel)
+	interceptor := GatewayLoggingInterceptor(logger, EnableAccountID, WithAlwaysLog())
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(metadata.NewOutgoingContext(context.Background(), md), testFullMethod, nil, nil, nil, invoker))
+	entries := gatewayLogEntries(t, out)
+	if assert.Len(t, entries, 1) {
+		for k, v := range fields {
+			assert.Equal(t, v, entries[0][k], k)
+		}
+	}
+}
+
+func TestStandardFields_Options(t *testing.T) {
+	fields := StandardFields(context.Background(), testFullMethod, EnableAccountID, WithFieldNames(map[string]string{DefaultGRPCMethodKey: "rpc"}))
+	assert.Equal(t, logrus.Fields{
+		DefaultGRPCServiceKey:  "app.Object",
+		"rpc":                  testMethod,
+		auth.MultiTenancyField: valueUndefined,
+	}, fields)
+
+	fields = StandardFields(context.Background(), testFullMethod, WithAccountID(nil, testFullMethod))
+	assert.NotContains(t, fields, auth.MultiTenancyField)
+}
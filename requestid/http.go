@@ -0,0 +1,44 @@
+package requestid
+
+import (
+	"net/http"
+)
+
+// HTTPMiddleware returns http.Handler that reads the Request-Id from the
+// request header named after the configured metadata key, generating one if
+// not present or rejected by DefaultValidator, and stores it in the request
+// context so that FromContext can extract it. The Request-Id is also set in
+// the response header.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID, ok := FromHTTPRequest(r)
+		if !ok {
+			reqID = New()
+		}
+
+		w.Header().Set(config.MetadataKey, reqID)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), reqID)))
+	})
+}
+
+// FromHTTPRequest returns the Request-Id of the request header named after the
+// configured metadata key, or of the deprecated one, if it is accepted by
+// DefaultValidator. Of a comma separated list, the first Request-Id is
+// returned, as by FromContext.
+func FromHTTPRequest(r *http.Request) (string, bool) {
+	reqID := r.Header.Get(config.MetadataKey)
+	if reqID == "" {
+		reqID = r.Header.Get(DeprecatedRequestIDKey)
+	}
+	reqID = firstRequestID(reqID)
+	if reqID == "" || !DefaultValidator(reqID) {
+		return "", false
+	}
+	return reqID, true
+}
+
+// SetOnResponse sets the Request-Id in the response header named after the
+// configured metadata key. It must be called before the header is written.
+func SetOnResponse(w http.ResponseWriter, reqID string) {
+	w.Header().Set(config.MetadataKey, reqID)
+}
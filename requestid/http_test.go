@@ -0,0 +1,86 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	dummyRequestID := New()
+	for name, tc := range map[string]struct {
+		header   string
+		expected string
+	}{
+		"client supplied": {header: DefaultRequestIDKey, expected: dummyRequestID},
+		"deprecated":      {header: DeprecatedRequestIDKey, expected: dummyRequestID},
+		"generated":       {},
+		"invalid":         {header: DefaultRequestIDKey},
+	} {
+		var handled string
+		handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqID, exists := FromContext(r.Context())
+			if !exists || reqID == "" {
+				t.Errorf("%s: requestId must be set by the middleware", name)
+			}
+			handled = reqID
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" && tc.expected == "" {
+			req.Header.Set(tc.header, "invalid request id")
+		} else if tc.header != "" {
+			req.Header.Set(tc.header, dummyRequestID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if tc.expected != "" && handled != tc.expected || handled == "invalid request id" {
+			t.Errorf("%s: expected requestID: %q, returned requestId: %q", name, tc.expected, handled)
+		}
+		if header := rec.Header().Get(DefaultRequestIDKey); header != handled {
+			t.Errorf("%s: expected response requestID: %q, returned requestId: %q", name, handled, header)
+		}
+	}
+}
+
+func TestFromHTTPRequest(t *testing.T) {
+	dummyRequestID := New()
+	for name, tc := range map[string]struct {
+		header   string
+		value    string
+		expected string
+	}{
+		"none":       {},
+		"header":     {header: DefaultRequestIDKey, value: dummyRequestID, expected: dummyRequestID},
+		"deprecated": {header: DeprecatedRequestIDKey, value: dummyRequestID, expected: dummyRequestID},
+		"invalid":    {header: DefaultRequestIDKey, value: "invalid request id"},
+		"list":       {header: DefaultRequestIDKey, value: "id-a, id-b", expected: "id-a"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		reqID, ok := FromHTTPRequest(req)
+		if ok != (tc.expected != "") || reqID != tc.expected {
+			t.Errorf("%s: expected requestID: %q, returned requestId: %q (%v)", name, tc.expected, reqID, ok)
+		}
+	}
+}
+
+func TestSetOnResponse(t *testing.T) {
+	defer SetConfig(Config{})
+	SetConfig(Config{MetadataKey: "X-Trace-ID"})
+
+	rec := httptest.NewRecorder()
+	SetOnResponse(rec, "abc-123")
+	if header := rec.Header().Get("X-Trace-ID"); header != "abc-123" {
+		t.Errorf("expected response requestID: %q, returned requestId: %q", "abc-123", header)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Trace-ID", "abc-123")
+	if reqID, ok := FromHTTPRequest(req); !ok || reqID != "abc-123" {
+		t.Errorf("expected requestID: %q, returned requestId: %q", "abc-123", reqID)
+	}
+}
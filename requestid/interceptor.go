@@ -5,6 +5,7 @@ import (
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // UnaryServerInterceptor returns grpc.UnaryServerInterceptor
@@ -14,15 +15,24 @@ import (
 //
 // Returned middleware populates Request-Id from gRPC metadata if
 // they defined in a testRequest message else creates a new one.
-func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+// The inbound Request-Id is validated, see WithValidator, and the Request-Id
+// is sent back in the response header.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
 
-		reqID := HandleRequestID(ctx)
+		ctx, reqID, parentID, err := o.handleRequestID(ctx)
+		if err != nil {
+			return nil, err
+		}
 
 		// add request id to logger
-		addRequestIDToLogger(ctx, reqID)
+		addRequestIDToLogger(ctx, reqID, parentID)
 
-		ctx = NewContext(ctx, reqID)
+		ctx = NewContextWithParent(ctx, reqID, parentID)
+
+		// echo the request id in the response header, e.g. for the gateway
+		grpc.SetHeader(ctx, metadata.Pairs(config.MetadataKey, reqID))
 
 		// returning from the request call
 		res, err = handler(ctx, req)
@@ -31,17 +41,27 @@ func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	}
 }
 
-func StreamServerInterceptor() grpc.StreamServerInterceptor {
+// StreamServerInterceptor returns grpc.StreamServerInterceptor, the streaming
+// counterpart of UnaryServerInterceptor. The Request-Id is set in the context
+// of the stream, so that it is the same for the whole life of the stream.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
 
 		ctx := stream.Context()
 
-		reqID := HandleRequestID(ctx)
+		ctx, reqID, parentID, err := o.handleRequestID(ctx)
+		if err != nil {
+			return err
+		}
 
 		// add request id to logger
-		addRequestIDToLogger(ctx, reqID)
+		addRequestIDToLogger(ctx, reqID, parentID)
+
+		ctx = NewContextWithParent(ctx, reqID, parentID)
 
-		ctx = NewContext(ctx, reqID)
+		// echo the request id in the response header, e.g. for the gateway
+		stream.SetHeader(metadata.Pairs(config.MetadataKey, reqID))
 
 		wrapped := grpc_middleware.WrapServerStream(stream)
 		wrapped.WrappedContext = ctx
@@ -51,3 +71,34 @@ func StreamServerInterceptor() grpc.StreamServerInterceptor {
 		return
 	}
 }
+
+// UnaryClientInterceptor returns grpc.UnaryClientInterceptor that forwards the
+// Request-Id of the context in the outgoing metadata, generating a new one if
+// the context has none
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withOutgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor returns grpc.StreamClientInterceptor, the streaming
+// counterpart of UnaryClientInterceptor
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withOutgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+// withOutgoingRequestID adds the Request-Id, and the parent one if any, to the
+// outgoing metadata unless they are already there, the other outgoing metadata
+// are kept
+func withOutgoingRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(config.MetadataKey)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, config.MetadataKey, HandleRequestID(ctx))
+	}
+	if parentID, ok := ParentFromContext(ctx); ok && len(md.Get(ParentRequestIDKey)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, ParentRequestIDKey, parentID)
+	}
+	return ctx
+}
@@ -29,7 +29,7 @@ func TestUnaryServerInterceptorWithoutRequestId(t *testing.T) {
 }
 
 func TestUnaryServerInterceptorWithDummyRequestId(t *testing.T) {
-	dummyRequestID := newRequestID()
+	dummyRequestID := New()
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
 		reqID, exists := FromContext(ctx)
 		if !exists || reqID != dummyRequestID {
@@ -63,7 +63,7 @@ func TestStreamServerInterceptorWithoutRequestId(t *testing.T) {
 }
 
 func TestStreamServerInterceptorWithDummyRequestId(t *testing.T) {
-	dummyRequestID := newRequestID()
+	dummyRequestID := New()
 	handler := func(srv interface{}, stream grpc.ServerStream) error {
 		reqID, exists := FromContext(stream.Context())
 		if !exists || reqID != dummyRequestID {
@@ -79,3 +79,129 @@ func TestStreamServerInterceptorWithDummyRequestId(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestStreamServerInterceptorSameRequestIdAcrossMessages(t *testing.T) {
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		first, exists := FromContext(stream.Context())
+		if !exists || first == "" {
+			t.Fatalf("requestId must be generated by interceptor")
+		}
+		for i := 0; i < 3; i++ {
+			if err := stream.RecvMsg(&testRequest{}); err != nil {
+				return err
+			}
+			if reqID, _ := FromContext(stream.Context()); reqID != first {
+				t.Errorf("expected requestID: %q, returned requestId: %q", first, reqID)
+			}
+		}
+		return nil
+	}
+	ctx := mock_transport.DummyContextWithServerTransportStream()
+	if err := StreamServerInterceptor()(testRequest{}, mock_transport.NewMockServerStream(ctx), nil, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	dummyRequestID := New()
+	for name, tc := range map[string]struct {
+		ctx      context.Context
+		expected string
+	}{
+		"generated": {ctx: metadata.AppendToOutgoingContext(context.Background(), "other", "value")},
+		"incoming":  {ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(DefaultRequestIDKey, dummyRequestID)), expected: dummyRequestID},
+		"outgoing":  {ctx: metadata.AppendToOutgoingContext(context.Background(), DefaultRequestIDKey, dummyRequestID), expected: dummyRequestID},
+	} {
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			reqIDs := md.Get(DefaultRequestIDKey)
+			if len(reqIDs) != 1 || reqIDs[0] == "" || (tc.expected != "" && reqIDs[0] != tc.expected) {
+				t.Errorf("%s: expected requestID: %q, returned requestIds: %q", name, tc.expected, reqIDs)
+			}
+			if name == "generated" && len(md.Get("other")) != 1 {
+				t.Errorf("%s: the outgoing metadata must be kept", name)
+			}
+			return nil
+		}
+		if err := UnaryClientInterceptor()(tc.ctx, "method", testRequest{}, testResponse{}, nil, invoker); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if reqID, exists := FromContext(ctx); !exists || reqID == "" {
+			t.Errorf("requestId must be generated by interceptor")
+		}
+		return nil, nil
+	}
+	if _, err := StreamClientInterceptor()(context.Background(), nil, nil, "method", streamer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnaryServerInterceptorWithParentRequestId(t *testing.T) {
+	dummyRequestID := New()
+	rootRequestID := New()
+	for name, tc := range map[string]struct {
+		opts   []Option
+		md     metadata.MD
+		parent string
+	}{
+		"forwarded":     {md: metadata.Pairs(DefaultRequestIDKey, dummyRequestID)},
+		"first hop":     {opts: []Option{WithParentRequestID()}, md: metadata.Pairs(DefaultRequestIDKey, dummyRequestID), parent: dummyRequestID},
+		"next hop":      {opts: []Option{WithParentRequestID()}, md: metadata.Pairs(DefaultRequestIDKey, dummyRequestID, ParentRequestIDKey, rootRequestID), parent: rootRequestID},
+		"no inbound id": {opts: []Option{WithParentRequestID()}, md: metadata.Pairs()},
+	} {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			reqID, _ := FromContext(ctx)
+			if tc.parent == "" && tc.md.Len() > 0 && reqID != dummyRequestID {
+				t.Errorf("%s: expected requestID: %q, returned requestId: %q", name, dummyRequestID, reqID)
+			}
+			if tc.parent != "" && (reqID == "" || reqID == dummyRequestID) {
+				t.Errorf("%s: expected a new requestID, returned requestId: %q", name, reqID)
+			}
+			parentID, _ := ParentFromContext(ctx)
+			if parentID != tc.parent {
+				t.Errorf("%s: expected parent requestID: %q, returned parent requestId: %q", name, tc.parent, parentID)
+			}
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if outgoing := md.Get(DefaultRequestIDKey); len(outgoing) != 1 || outgoing[0] != reqID {
+				t.Errorf("%s: expected outgoing requestID: %q, returned requestIds: %q", name, reqID, outgoing)
+			}
+			return &testResponse{}, nil
+		}
+		ctx := metadata.NewIncomingContext(mock_transport.DummyContextWithServerTransportStream(), tc.md)
+		if _, err := UnaryServerInterceptor(tc.opts...)(ctx, testRequest{}, nil, handler); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// headerTransportStream records the response header set by the interceptors
+type headerTransportStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerTransportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestUnaryServerInterceptorResponseHeader(t *testing.T) {
+	var handled string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handled, _ = FromContext(ctx)
+		return &testResponse{}, nil
+	}
+	stream := &headerTransportStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	if _, err := UnaryServerInterceptor()(ctx, testRequest{}, nil, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reqIDs := stream.header.Get(DefaultRequestIDKey); len(reqIDs) != 1 || reqIDs[0] != handled {
+		t.Errorf("expected response requestID: %q, returned requestIds: %q", handled, reqIDs)
+	}
+}
@@ -2,6 +2,7 @@ package requestid
 
 import (
 	"context"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
@@ -16,25 +17,106 @@ const (
 	DeprecatedRequestIDKey = "Request-Id"
 	DefaultRequestIDKey    = "X-Request-ID"
 	RequestIDLogKey        = "request_id"
+
+	// ParentRequestIDKey is the metadata key name for the parent request ID,
+	// see WithParentRequestID
+	ParentRequestIDKey = "X-Parent-Request-ID"
+	// ParentRequestIDLogKey is the log field name for the parent request ID
+	ParentRequestIDLogKey = "request_id.parent"
+	// RawRequestIDLogKey is the log field name for the inbound Request-Id header
+	// holding several values, see FromContext
+	RawRequestIDLogKey = "request_id.raw"
 )
 
+// Generator is a function generating a new Request-Id
+type Generator func() string
+
+// Config is the package-wide configuration of the Request-Id handling, see
+// SetConfig
+type Config struct {
+	// MetadataKey is the gRPC metadata and HTTP header key the Request-Id is
+	// read from and propagated with. Defaults to DefaultRequestIDKey
+	MetadataKey string
+	// ContextKey, if set, is the context key NewContext stores the Request-Id
+	// under as well, and that FromContext looks it up under first
+	ContextKey interface{}
+	// Generator generates the missing Request-Ids, e.g. UUIDv7Generator.
+	// Defaults to UUIDv4Generator
+	Generator Generator
+}
+
+var config = Config{MetadataKey: DefaultRequestIDKey, Generator: UUIDv4Generator}
+
+// SetConfig overrides the configuration used by all the interceptors and
+// helpers of the package, the zero fields keep their default. It is not safe
+// for concurrent use and should be called at startup, before the interceptors
+// are built. The metadata key is set as the one of the gateway error
+// responses too, see gateway.SetRequestIDKey.
+func SetConfig(cfg Config) {
+	if cfg.MetadataKey == "" {
+		cfg.MetadataKey = DefaultRequestIDKey
+	}
+	if cfg.Generator == nil {
+		cfg.Generator = UUIDv4Generator
+	}
+	config = cfg
+	gateway.SetRequestIDKey(cfg.MetadataKey)
+}
+
+// MetadataKey returns the configured metadata key of the Request-Id
+func MetadataKey() string {
+	return config.MetadataKey
+}
+
 // HandleRequestID either extracts a existing and valid request ID from the context or generates a new one
 func HandleRequestID(ctx context.Context) (reqID string) {
 	reqID, exists := FromContext(ctx)
 	if !exists || reqID == "" {
-		reqID := newRequestID()
+		reqID := New()
 		return reqID
 	}
 	return reqID
 }
 
-func newRequestID() string {
+// New generates a new Request-Id with the configured generator
+func New() string {
+	return config.Generator()
+}
+
+// UUIDv4Generator generates random UUIDv4 Request-Ids, the default
+func UUIDv4Generator() string {
 	return uuid.New().String()
 }
 
-// FromContext returns the Request-Id information from ctx if it exists.
+// UUIDv7Generator generates time-ordered UUIDv7 Request-Ids, so that the logs
+// of the calls sort by Request-Id in the order the calls were made
+func UUIDv7Generator() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// FromContext returns the Request-Id information from ctx if it exists. The
+// intermediaries joining repeated headers may send several comma separated
+// Request-Ids, the first non-empty one is returned, RawFromContext returns the
+// whole value.
 func FromContext(ctx context.Context) (string, bool) {
-	if reqID, ok := gateway.Header(ctx, DefaultRequestIDKey); ok {
+	raw, ok := RawFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	reqID := firstRequestID(raw)
+	return reqID, reqID != ""
+}
+
+// RawFromContext returns the Request-Id information from ctx as it was sent,
+// including all the values of a comma separated list
+func RawFromContext(ctx context.Context) (string, bool) {
+	if config.ContextKey != nil {
+		if reqID, ok := ctx.Value(config.ContextKey).(string); ok {
+			return reqID, ok
+		}
+	}
+
+	if reqID, ok := gateway.Header(ctx, config.MetadataKey); ok {
 		return reqID, ok
 	}
 
@@ -45,12 +127,45 @@ func FromContext(ctx context.Context) (string, bool) {
 	return "", false
 }
 
+// firstRequestID returns the first non-empty value of a comma separated list
+func firstRequestID(raw string) string {
+	for _, reqID := range strings.Split(raw, ",") {
+		if reqID = strings.TrimSpace(reqID); reqID != "" {
+			return reqID
+		}
+	}
+	return ""
+}
+
+// ParentFromContext returns the parent Request-Id information from ctx if it
+// exists, see WithParentRequestID
+func ParentFromContext(ctx context.Context) (string, bool) {
+	return gateway.Header(ctx, ParentRequestIDKey)
+}
+
+// NewContextWithParent is NewContext with the parent Request-Id attached as
+// well, unless it is empty
+func NewContextWithParent(ctx context.Context, reqID, parentID string) context.Context {
+	ctx = NewContext(ctx, reqID)
+	if parentID == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, ParentRequestIDKey, parentID)
+}
+
 // NewContext creates a new context with Request-Id attached if not exists.
 func NewContext(ctx context.Context, reqID string) context.Context {
-	md := metadata.Pairs(DefaultRequestIDKey, reqID)
+	if config.ContextKey != nil {
+		ctx = context.WithValue(ctx, config.ContextKey, reqID)
+	}
+	md := metadata.Pairs(config.MetadataKey, reqID)
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
-func addRequestIDToLogger(ctx context.Context, reqID string) {
-	ctxlogrus.AddFields(ctx, logrus.Fields{RequestIDLogKey: reqID})
+func addRequestIDToLogger(ctx context.Context, reqID, parentID string) {
+	fields := logrus.Fields{RequestIDLogKey: reqID}
+	if parentID != "" {
+		fields[ParentRequestIDLogKey] = parentID
+	}
+	ctxlogrus.AddFields(ctx, fields)
 }
@@ -0,0 +1,113 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/armezit/atlas-app-toolkit/gateway"
+)
+
+type testContextKey struct{}
+
+func TestSetConfig(t *testing.T) {
+	defer SetConfig(Config{})
+	SetConfig(Config{MetadataKey: "X-Request-Id", ContextKey: testContextKey{}})
+
+	dummyRequestID := New()
+	ctx := NewContext(context.Background(), dummyRequestID)
+	if reqID, _ := ctx.Value(testContextKey{}).(string); reqID != dummyRequestID {
+		t.Errorf("expected requestID in context: %q, returned requestId: %q", dummyRequestID, reqID)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if reqIDs := md.Get("x-request-id"); len(reqIDs) != 1 || reqIDs[0] != dummyRequestID {
+		t.Errorf("expected requestID in metadata: %q, returned requestIds: %q", dummyRequestID, reqIDs)
+	}
+
+	// the context key takes precedence over the metadata
+	ctx = context.WithValue(metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "from-metadata")), testContextKey{}, dummyRequestID)
+	if reqID, _ := FromContext(ctx); reqID != dummyRequestID {
+		t.Errorf("expected requestID: %q, returned requestId: %q", dummyRequestID, reqID)
+	}
+	if MetadataKey() != "X-Request-Id" {
+		t.Errorf("expected metadata key: %q, returned key: %q", "X-Request-Id", MetadataKey())
+	}
+
+	SetConfig(Config{})
+	if MetadataKey() != DefaultRequestIDKey {
+		t.Errorf("expected metadata key: %q, returned key: %q", DefaultRequestIDKey, MetadataKey())
+	}
+	if _, exists := FromContext(context.WithValue(context.Background(), testContextKey{}, dummyRequestID)); exists {
+		t.Errorf("unexpected requestID with the default config")
+	}
+}
+
+func TestSetConfigGatewayKey(t *testing.T) {
+	defer SetConfig(Config{})
+	SetConfig(Config{MetadataKey: "X-Correlation-ID"})
+
+	// the gateway error responses read the request id under the same key
+	req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Set("X-Correlation-ID", "abc-123")
+	rw := httptest.NewRecorder()
+	gateway.NewErrorHandler()(context.Background(), nil, &runtime.JSONPb{}, rw, req, status.Error(codes.NotFound, "gone"))
+	if !strings.Contains(rw.Body.String(), `"request_id":"abc-123"`) {
+		t.Errorf("expected the request id in the error response: %s", rw.Body.String())
+	}
+}
+
+func TestSetConfigGenerator(t *testing.T) {
+	defer SetConfig(Config{})
+	SetConfig(Config{Generator: func() string { return "dc1-0001" }})
+
+	if reqID := HandleRequestID(context.Background()); reqID != "dc1-0001" {
+		t.Errorf("expected requestID: %q, returned requestId: %q", "dc1-0001", reqID)
+	}
+
+	SetConfig(Config{})
+	if reqID := New(); len(reqID) != 36 {
+		t.Errorf("expected a UUID requestID, returned requestId: %q", reqID)
+	}
+}
+
+func TestSetConfigUUIDVersion(t *testing.T) {
+	defer SetConfig(Config{})
+	for expected, generator := range map[uuid.Version]Generator{4: nil, 7: UUIDv7Generator} {
+		SetConfig(Config{Generator: generator})
+		id, err := uuid.Parse(HandleRequestID(context.Background()))
+		if err != nil {
+			t.Fatalf("expected a UUID requestID: %v", err)
+		}
+		if id.Version() != expected {
+			t.Errorf("expected UUID version: %d, returned version: %d", expected, id.Version())
+		}
+	}
+}
+
+func TestFromContextMultiValue(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(DefaultRequestIDKey, "id-a, id-b"))
+	if reqID, exists := FromContext(ctx); !exists || reqID != "id-a" {
+		t.Errorf("expected requestID: %q, returned requestId: %q", "id-a", reqID)
+	}
+	if raw, exists := RawFromContext(ctx); !exists || raw != "id-a, id-b" {
+		t.Errorf("expected raw requestID: %q, returned: %q", "id-a, id-b", raw)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(DefaultRequestIDKey, " , id-b"))
+	if reqID, _ := FromContext(ctx); reqID != "id-b" {
+		t.Errorf("expected requestID: %q, returned requestId: %q", "id-b", reqID)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(DefaultRequestIDKey, ", ,"))
+	if reqID, exists := FromContext(ctx); exists {
+		t.Errorf("unexpected requestID: %q", reqID)
+	}
+}
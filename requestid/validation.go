@@ -0,0 +1,130 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/logrus/ctxlogrus"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxLength is the maximum length of the Request-Ids accepted by
+// DefaultValidator
+const DefaultMaxLength = 128
+
+var errInvalidRequestID = status.Error(codes.InvalidArgument, "invalid request id")
+
+// Option is a type of function that alters the configuration of the server
+// interceptors
+type Option func(*options)
+
+type options struct {
+	validator func(string) bool
+	strict    bool
+	parent    bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{validator: DefaultValidator}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithValidator sets the function validating the inbound Request-Ids, the
+// invalid ones are replaced by a generated Request-Id, or rejected in strict
+// mode. Defaults to DefaultValidator
+func WithValidator(validator func(string) bool) Option {
+	return func(o *options) {
+		o.validator = validator
+	}
+}
+
+// WithStrictValidation makes the interceptors reject the requests with an
+// invalid Request-Id with codes.InvalidArgument, instead of replacing it
+func WithStrictValidation() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithParentRequestID makes the interceptors generate a new Request-Id for
+// every hop. The inbound Request-Id is kept as the parent one, unless the
+// request has a parent Request-Id already, so that the parent is the Request-Id
+// of the originating request. Both are propagated in the outgoing metadata and
+// logged. By default the inbound Request-Id is forwarded unchanged.
+func WithParentRequestID() Option {
+	return func(o *options) {
+		o.parent = true
+	}
+}
+
+// DefaultValidator accepts the Request-Ids of at most DefaultMaxLength
+// letters, digits and -_.:/+= characters
+func DefaultValidator(reqID string) bool {
+	if len(reqID) > DefaultMaxLength {
+		return false
+	}
+	for i := 0; i < len(reqID); i++ {
+		switch c := reqID[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// handleRequestID is HandleRequestID validating the inbound Request-Id, and
+// returning the parent Request-Id when enabled. The returned context no longer
+// holds an inbound Request-Id that was replaced
+func (o *options) handleRequestID(ctx context.Context) (context.Context, string, string, error) {
+	reqID, exists := FromContext(ctx)
+	if !exists || reqID == "" {
+		return ctx, New(), "", nil
+	}
+	if o.validator != nil && !o.validator(reqID) {
+		if o.strict {
+			return ctx, "", "", errInvalidRequestID
+		}
+		// the invalid Request-Id itself is not logged, it may be arbitrarily long
+		ctxlogrus.Extract(ctx).WithField("request_id.length", len(reqID)).Warn("replaced invalid request id supplied by the client")
+		return dropIncomingRequestID(ctx), New(), "", nil
+	}
+	if raw, _ := RawFromContext(ctx); raw != reqID {
+		if len(raw) > DefaultMaxLength {
+			raw = raw[:DefaultMaxLength]
+		}
+		ctxlogrus.AddFields(ctx, logrus.Fields{RawRequestIDLogKey: raw})
+	}
+	if !o.parent {
+		return ctx, reqID, "", nil
+	}
+	parentID, ok := ParentFromContext(ctx)
+	if !ok || parentID == "" {
+		parentID = reqID
+	}
+	return dropIncomingRequestID(ctx), New(), parentID, nil
+}
+
+// dropIncomingRequestID removes the Request-Id from the incoming metadata, so
+// that FromContext returns the one set by NewContext
+func dropIncomingRequestID(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	md = md.Copy()
+	for _, key := range []string{config.MetadataKey, DeprecatedRequestIDKey} {
+		key = strings.ToLower(key)
+		delete(md, key)
+		delete(md, runtime.MetadataPrefix+key)
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
@@ -0,0 +1,74 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mock_transport "github.com/armezit/atlas-app-toolkit/mocks/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestDefaultValidator(t *testing.T) {
+	for reqID, valid := range map[string]bool{
+		New():                                   true,
+		"dc1:0001/a+b=c_d.e":                    true,
+		strings.Repeat("a", DefaultMaxLength):   true,
+		strings.Repeat("a", DefaultMaxLength+1): false,
+		"with space":                            false,
+		"new\nline":                             false,
+		"non-ascii-é":                           false,
+	} {
+		if DefaultValidator(reqID) != valid {
+			t.Errorf("expected validity of %q: %v", reqID, valid)
+		}
+	}
+}
+
+func TestUnaryServerInterceptorValidation(t *testing.T) {
+	invalidRequestID := strings.Repeat("x", 4096)
+	for name, tc := range map[string]struct {
+		opts     []Option
+		reqID    string
+		replaced bool
+		code     codes.Code
+	}{
+		"valid":            {reqID: "valid-id"},
+		"replaced":         {reqID: invalidRequestID, replaced: true},
+		"strict":           {reqID: invalidRequestID, opts: []Option{WithStrictValidation()}, code: codes.InvalidArgument},
+		"custom validator": {reqID: "valid-id", opts: []Option{WithValidator(func(string) bool { return false })}, replaced: true},
+		"no validator":     {reqID: invalidRequestID, opts: []Option{WithValidator(nil), WithStrictValidation()}},
+	} {
+		var handled string
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			handled, _ = FromContext(ctx)
+			return &testResponse{}, nil
+		}
+		ctx := metadata.NewIncomingContext(mock_transport.DummyContextWithServerTransportStream(), metadata.Pairs(DefaultRequestIDKey, tc.reqID))
+		_, err := UnaryServerInterceptor(tc.opts...)(ctx, testRequest{}, nil, handler)
+		if status.Code(err) != tc.code {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		if tc.code != codes.OK {
+			continue
+		}
+		if replaced := handled != tc.reqID; replaced != tc.replaced || handled == "" {
+			t.Errorf("%s: unexpected requestId: %q", name, handled)
+		}
+	}
+}
+
+func TestStreamServerInterceptorValidation(t *testing.T) {
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		t.Error("the stream must be rejected")
+		return nil
+	}
+	ctx := metadata.NewIncomingContext(mock_transport.DummyContextWithServerTransportStream(), metadata.Pairs(DefaultRequestIDKey, "with space"))
+	err := StreamServerInterceptor(WithStrictValidation())(testRequest{}, mock_transport.NewMockServerStream(ctx), nil, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unexpected error: %v", err)
+	}
+}